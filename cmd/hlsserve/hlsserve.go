@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/untangledco/streaming/m3u8"
+	"github.com/untangledco/streaming/m3u8/live"
 	"github.com/untangledco/streaming/mpegts"
 )
 
@@ -43,8 +44,10 @@ const maxTSBytes int = 7 * mpegts.PacketSize
 
 const segmentDuration = 3 * time.Second
 
+// number of segments listed in the playlist.
+const window = 8
+
 var cacheDir string
-var sequence int
 
 func removeOld(dir string, maxAge time.Duration) error {
 	ents, err := os.ReadDir(dir)
@@ -63,13 +66,14 @@ func removeOld(dir string, maxAge time.Duration) error {
 			if err := os.Remove(filepath.Join(dir, dent.Name())); err != nil {
 				return err
 			}
-			sequence++
 		}
 	}
 	return nil
 }
 
-func writeSegments(dir string, r io.Reader, ch <-chan time.Time) error {
+// writeSegments writes MPEG-TS packets read from r to a new segment
+// file in dir each time ch receives, adding each segment to playlist.
+func writeSegments(dir string, r io.Reader, ch <-chan time.Time, playlist *live.Playlist) error {
 	var segment int
 	segments := &bytes.Buffer{}
 	sc := mpegts.NewScanner(r)
@@ -81,6 +85,7 @@ func writeSegments(dir string, r io.Reader, ch <-chan time.Time) error {
 			if err := os.WriteFile(fname, segments.Bytes(), 0644); err != nil {
 				return err
 			}
+			playlist.AddSegment(m3u8.Segment{URI: s, Duration: segmentDuration})
 			segments.Reset()
 			segment++
 		default:
@@ -94,43 +99,10 @@ func writeSegments(dir string, r io.Reader, ch <-chan time.Time) error {
 			}
 		}
 	}
-	fmt.Fprintln(os.Stderr, "shouldn't really be returning here...?")
-	return nil
-}
-
-func makePlaylist(dir string) (*m3u8.Playlist, error) {
-	names, err := filepath.Glob(filepath.Join(dir, "*.ts"))
-	if err != nil {
-		return nil, fmt.Errorf("find segments: %w", err)
-	}
-	playlist := &m3u8.Playlist{
-		Version:        7,
-		TargetDuration: segmentDuration,
-		Sequence:       sequence,
-	}
-	for _, name := range names {
-		seg := m3u8.Segment{URI: path.Base(name), Duration: playlist.TargetDuration}
-		playlist.Segments = append(playlist.Segments, seg)
-	}
-	return playlist, nil
 }
 
 const usage string = "usage: hlsserve dir"
 
-func servePlaylist(dir string) http.HandlerFunc {
-	return func(w http.ResponseWriter, req *http.Request) {
-		playlist, err := makePlaylist(dir)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", m3u8.MimeType)
-		if err := m3u8.Encode(w, playlist); err != nil {
-			log.Printf("encode playlist: %v", err)
-		}
-	}
-}
-
 func setCache(seconds int, next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
 		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", seconds))
@@ -165,9 +137,17 @@ func main() {
 		log.Fatal(err)
 	}
 
+	playlist := live.New(&m3u8.Playlist{
+		Version:        9,
+		TargetDuration: segmentDuration,
+		ServerControl: &m3u8.ServerControl{
+			CanBlockReload: true,
+			CanSkipUntil:   6 * segmentDuration,
+		},
+	}, window)
 	go func() {
 		ticker := time.NewTicker(segmentDuration)
-		if err := writeSegments(cacheDir, conn, ticker.C); err != nil {
+		if err := writeSegments(cacheDir, conn, ticker.C, playlist); err != nil {
 			log.Fatalln("write segments:", err)
 		}
 	}()
@@ -184,7 +164,7 @@ func main() {
 		}
 	}()
 
-	http.Handle("/playlist.m3u8", servePlaylist(cacheDir))
+	http.Handle("/playlist.m3u8", playlist)
 	fsys := http.FileServer(http.FS(os.DirFS(cacheDir)))
 	http.Handle("/", setCache(60, fsys))
 	log.Fatal(http.ListenAndServe(":8000", nil))
@@ -0,0 +1,109 @@
+package m3u8
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// longPlaylist returns a VOD playlist with n segments,
+// similar to a 24-hour recording split into 2 second segments.
+func longPlaylist(n int) *Playlist {
+	p := &Playlist{
+		Version:        7,
+		Type:           PlaylistVOD,
+		TargetDuration: 2 * time.Second,
+		End:            true,
+		Segments:       make([]Segment, n),
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range p.Segments {
+		p.Segments[i] = Segment{
+			URI:      fmt.Sprintf("https://cdn.example.com/vod/1080p/%06d.ts", i),
+			Duration: 2002 * time.Millisecond,
+		}
+		if i%1800 == 0 {
+			p.Segments[i].DateTime = start.Add(time.Duration(i) * 2 * time.Second)
+		}
+	}
+	return p
+}
+
+func encodedLongPlaylist(b *testing.B, n int) []byte {
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, longPlaylist(n)); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data := encodedLongPlaylist(b, 40000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(p.Segments) != 40000 {
+			b.Fatalf("decoded %d segments, want %d", len(p.Segments), 40000)
+		}
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	p := longPlaylist(40000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Encode(io.Discard, p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	data := encodedLongPlaylist(b, 40000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d := NewDecoder(bytes.NewReader(data))
+		var n int
+		for d.Scan() {
+			n++
+		}
+		if err := d.Err(); err != nil {
+			b.Fatal(err)
+		}
+		if n != 40000 {
+			b.Fatalf("decoded %d segments, want %d", n, 40000)
+		}
+	}
+}
+
+func BenchmarkEncoder(b *testing.B) {
+	p := longPlaylist(40000)
+	segments := p.Segments
+	p.Segments = nil
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		enc := NewEncoder(io.Discard)
+		if err := enc.WriteHeader(p); err != nil {
+			b.Fatal(err)
+		}
+		for j := range segments {
+			if err := enc.WriteSegment(&segments[j]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := enc.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
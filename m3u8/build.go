@@ -0,0 +1,285 @@
+package m3u8
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Stream is a media playlist to be listed in a master playlist
+// generated by BuildMaster.
+type Stream struct {
+	// URI is the location of the media playlist
+	// as written in the master playlist.
+	URI      string
+	Playlist *Playlist
+	// Rendition, if set, lists the playlist as an alternative
+	// rendition in an EXT-X-MEDIA tag, rather than as a variant.
+	// Its URI is set from the URI of the Stream.
+	Rendition *Rendition
+	// Variant holds the attributes of a variant stream which cannot
+	// be determined from its media, such as the Audio and Subtitles
+	// rendition groups. The URI, Bandwidth and AverageBandwidth are
+	// always calculated. If the first segment is probed, Codecs,
+	// Resolution and FrameRate are overwritten.
+	Variant Variant
+}
+
+// BuildMaster returns a master playlist listing streams.
+//
+// Segments are read from the reader returned by calling open with the
+// segment's URI, as written in the media playlist. Readers are closed
+// once read. BANDWIDTH and AVERAGE-BANDWIDTH are calculated from the
+// size and duration of each segment, including the renditions of the
+// groups a variant refers to. If open is nil, segment sizes are taken
+// from their byte ranges or EXT-X-BITRATE tags.
+//
+// The first segment of each variant and audio or video rendition is
+// probed for its codecs and, for video, its resolution and frame rate.
+// Only MPEG-TS segments are probed; streams with an EXT-X-MAP tag,
+// such as fragmented MP4, must have their codecs set by the caller.
+//
+// Renditions are grouped by type and group ID. If no rendition in a
+// group is marked as the default, the first is. Variants are sorted
+// by bandwidth.
+func BuildMaster(streams []Stream, open func(uri string) (io.ReadCloser, error)) (*Playlist, error) {
+	type group struct {
+		typ  MediaType
+		name string
+	}
+	groups := make(map[group]*streamInfo)
+	master := &Playlist{IndependentSegments: len(streams) > 0}
+	var variants []Variant
+	var infos []*streamInfo
+	for i := range streams {
+		s := &streams[i]
+		if s.Playlist == nil {
+			return nil, fmt.Errorf("stream %s: nil playlist", s.URI)
+		}
+		if len(s.Playlist.Segments) == 0 {
+			return nil, fmt.Errorf("stream %s: no segments", s.URI)
+		}
+		probe := s.Rendition == nil || s.Rendition.Type == MediaAudio || s.Rendition.Type == MediaVideo
+		info, err := measure(s.Playlist, open, probe)
+		if err != nil {
+			return nil, fmt.Errorf("stream %s: %w", s.URI, err)
+		}
+		if !s.Playlist.IndependentSegments {
+			master.IndependentSegments = false
+		}
+
+		if s.Rendition == nil {
+			v := s.Variant
+			v.URI = s.URI
+			if info.probe != nil {
+				v.Codecs = info.probe.codecs
+				v.Resolution = info.probe.resolution
+				v.FrameRate = info.probe.frameRate
+			}
+			variants = append(variants, v)
+			infos = append(infos, info)
+			continue
+		}
+
+		r := *s.Rendition
+		r.URI = s.URI
+		if r.Group == "" {
+			return nil, fmt.Errorf("stream %s: rendition has empty group", s.URI)
+		}
+		if p := info.probe; p != nil && r.Type == MediaAudio {
+			if r.Channels == nil && p.channels > 0 {
+				r.Channels = &Channels{Count: p.channels}
+			}
+			if r.SampleRate == 0 {
+				r.SampleRate = p.sampleRate
+			}
+		}
+		for _, m := range master.Media {
+			if m.Type == r.Type && m.Group == r.Group && m.Name == r.Name {
+				return nil, fmt.Errorf("stream %s: duplicate rendition name %q in group %s", s.URI, r.Name, r.Group)
+			}
+		}
+		master.Media = append(master.Media, r)
+
+		// A group's bandwidth is that of its most demanding rendition.
+		g := group{r.Type, r.Group}
+		if groups[g] == nil {
+			groups[g] = &streamInfo{}
+		}
+		gi := groups[g]
+		if info.peak > gi.peak {
+			gi.peak = info.peak
+		}
+		if info.average > gi.average {
+			gi.average = info.average
+		}
+		if info.probe != nil {
+			gi.codecs = appendCodecs(gi.codecs, info.probe.codecs)
+		}
+	}
+
+	for i := range variants {
+		v := &variants[i]
+		v.Bandwidth = infos[i].peak
+		v.AverageBandwidth = infos[i].average
+		refs := []group{
+			{MediaAudio, v.Audio},
+			{MediaVideo, v.Video},
+			{MediaSubtitles, v.Subtitles},
+		}
+		for _, ref := range refs {
+			if ref.name == "" {
+				continue
+			}
+			gi, ok := groups[ref]
+			if !ok {
+				return nil, fmt.Errorf("variant %s: no %s renditions in group %q", v.URI, ref.typ, ref.name)
+			}
+			v.Bandwidth += gi.peak
+			v.AverageBandwidth += gi.average
+			v.Codecs = appendCodecs(v.Codecs, gi.codecs)
+		}
+	}
+	sort.SliceStable(variants, func(i, j int) bool {
+		return variants[i].Bandwidth < variants[j].Bandwidth
+	})
+	master.Variants = variants
+
+	sort.SliceStable(master.Media, func(i, j int) bool {
+		a, b := master.Media[i], master.Media[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Group < b.Group
+	})
+	if err := setDefaults(master.Media); err != nil {
+		return nil, err
+	}
+
+	// Catch anything else we can't write.
+	if err := Encode(io.Discard, master); err != nil {
+		return nil, err
+	}
+	return master, nil
+}
+
+// setDefaults marks the first rendition of each group as the default
+// if no other rendition is. Renditions must be sorted by group.
+func setDefaults(media []Rendition) error {
+	for i := 0; i < len(media); {
+		j := i
+		defaults := 0
+		for ; j < len(media) && media[j].Type == media[i].Type && media[j].Group == media[i].Group; j++ {
+			if media[j].Default {
+				defaults++
+			}
+		}
+		switch defaults {
+		case 0:
+			media[i].Default = true
+			media[i].AutoSelect = true
+		case 1:
+		default:
+			return fmt.Errorf("%s group %q: %d default renditions", media[i].Type, media[i].Group, defaults)
+		}
+		i = j
+	}
+	return nil
+}
+
+// appendCodecs appends to codecs those in add not already present.
+func appendCodecs(codecs, add []string) []string {
+	for _, c := range add {
+		var found bool
+		for _, have := range codecs {
+			if have == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			codecs = append(codecs, c)
+		}
+	}
+	return codecs
+}
+
+// streamInfo holds the bitrates, in bits per second,
+// and probed media of a stream.
+type streamInfo struct {
+	peak    int
+	average int
+	codecs  []string
+	probe   *probeInfo
+}
+
+// measure calculates the peak and average segment bitrate of p.
+// If probeFirst is true and p is not fragmented MP4, the first
+// segment is probed.
+func measure(p *Playlist, open func(uri string) (io.ReadCloser, error), probeFirst bool) (*streamInfo, error) {
+	var info streamInfo
+	var total int
+	var dur time.Duration
+	for i := range p.Segments {
+		seg := &p.Segments[i]
+		probing := i == 0 && probeFirst && open != nil && seg.Map == nil
+		var size int
+		switch {
+		case seg.Range[0] > 0 && !probing:
+			size = seg.Range[0]
+		case open != nil:
+			b, err := readSegment(seg, open)
+			if err != nil {
+				return nil, err
+			}
+			size = len(b)
+			if probing {
+				info.probe, err = probe(bytes.NewReader(b))
+				if err != nil {
+					return nil, fmt.Errorf("probe segment %s: %w", seg.URI, err)
+				}
+			}
+		case seg.Bitrate > 0:
+			// kilobits per second.
+			size = int(float64(seg.Bitrate) * 1000 / 8 * seg.Duration.Seconds())
+		default:
+			return nil, fmt.Errorf("segment %s: unknown size", seg.URI)
+		}
+		if seg.Duration <= 0 {
+			continue
+		}
+		bits := size * 8
+		if bw := int(float64(bits) / seg.Duration.Seconds()); bw > info.peak {
+			info.peak = bw
+		}
+		total += bits
+		dur += seg.Duration
+	}
+	if dur > 0 {
+		info.average = int(float64(total) / dur.Seconds())
+	}
+	return &info, nil
+}
+
+// readSegment returns the contents of seg, limited to its byte range if set.
+func readSegment(seg *Segment, open func(uri string) (io.ReadCloser, error)) ([]byte, error) {
+	rc, err := open(seg.URI)
+	if err != nil {
+		return nil, fmt.Errorf("open segment %s: %w", seg.URI, err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("read segment %s: %w", seg.URI, err)
+	}
+	if seg.Range[0] > 0 {
+		n, off := seg.Range[0], seg.Range[1]
+		if off+n > len(b) {
+			return nil, fmt.Errorf("segment %s: range %s beyond end of %d bytes", seg.URI, seg.Range, len(b))
+		}
+		b = b[off : off+n]
+	}
+	return b, nil
+}
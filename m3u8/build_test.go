@@ -0,0 +1,105 @@
+package m3u8
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildMaster(t *testing.T) {
+	const name = "../mpegts/testdata/193039199_mp4_h264_aac_hq_7.ts"
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const vtt = "WEBVTT\n\n00:00.000 --> 00:05.000\nhello\n"
+	open := func(uri string) (io.ReadCloser, error) {
+		if strings.HasSuffix(uri, ".vtt") {
+			return io.NopCloser(strings.NewReader(vtt)), nil
+		}
+		return os.Open(name)
+	}
+	media := func(uri string) *Playlist {
+		return &Playlist{
+			TargetDuration: 5 * time.Second,
+			End:            true,
+			Segments:       []Segment{{URI: uri, Duration: 5 * time.Second}},
+		}
+	}
+	streams := []Stream{
+		{
+			URI:      "high.m3u8",
+			Playlist: media("high.ts"),
+			Variant:  Variant{Audio: "aac", Subtitles: "subs"},
+		},
+		{URI: "low.m3u8", Playlist: media("low.ts")},
+		{
+			URI:       "audio.m3u8",
+			Playlist:  media("audio.ts"),
+			Rendition: &Rendition{Type: MediaAudio, Group: "aac", Name: "English", Language: "en"},
+		},
+		{
+			URI:       "subs.m3u8",
+			Playlist:  media("subs.vtt"),
+			Rendition: &Rendition{Type: MediaSubtitles, Group: "subs", Name: "English"},
+		},
+	}
+	p, err := BuildMaster(streams, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bw := int(fi.Size() * 8 / 5)
+	subsBW := len(vtt) * 8 / 5
+	if len(p.Variants) != 2 {
+		t.Fatalf("got %d variants, want 2", len(p.Variants))
+	}
+	low, high := p.Variants[0], p.Variants[1]
+	if low.URI != "low.m3u8" || high.URI != "high.m3u8" {
+		t.Errorf("variants not sorted by bandwidth: got %s then %s", low.URI, high.URI)
+	}
+	if low.Bandwidth != bw || low.AverageBandwidth != bw {
+		t.Errorf("low bandwidth %d, average %d; want %d", low.Bandwidth, low.AverageBandwidth, bw)
+	}
+	if high.Bandwidth != 2*bw+subsBW {
+		t.Errorf("high bandwidth %d does not include renditions; want %d", high.Bandwidth, 2*bw+subsBW)
+	}
+	codecs := []string{"avc1.64001f", "mp4a.40.2"}
+	if !reflect.DeepEqual(low.Codecs, codecs) {
+		t.Errorf("codecs %v, want %v", low.Codecs, codecs)
+	}
+	if low.Resolution != [2]int{848, 480} {
+		t.Errorf("resolution %v, want %v", low.Resolution, [2]int{848, 480})
+	}
+	if low.FrameRate != 60 {
+		t.Errorf("frame rate %v, want 60", low.FrameRate)
+	}
+
+	if len(p.Media) != 2 {
+		t.Fatalf("got %d renditions, want 2", len(p.Media))
+	}
+	audio := p.Media[0]
+	if audio.Type != MediaAudio || !audio.Default || audio.URI != "audio.m3u8" {
+		t.Errorf("audio rendition not first or default: %+v", audio)
+	}
+	if audio.Channels == nil || audio.Channels.Count != 2 || audio.SampleRate != 44100 {
+		t.Errorf("audio rendition: channels %v, sample rate %d; want 2, 44100", audio.Channels, audio.SampleRate)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Decode(buf); err != nil {
+		t.Errorf("decode generated playlist: %v", err)
+	}
+
+	streams[0].Variant.Audio = "missing"
+	if _, err := BuildMaster(streams, open); err == nil {
+		t.Errorf("nil error building variant referencing missing rendition group")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"path"
 	"time"
 
 	"github.com/untangledco/streaming/m3u8"
@@ -73,15 +72,9 @@ func main() {
 	if err != nil {
 		log.Fatal("parse playlist:", err)
 	}
-	link.Path = path.Dir(link.Path) + "/"
-	for i := range source.Media {
-		source.Media[i].URI = link.String() + source.Media[i].URI
-	}
-	for i := range source.Variants {
-		source.Variants[i].URI = link.String() + source.Variants[i].URI
-	}
-	for i := range source.Segments {
-		source.Segments[i].URI = link.String() + source.Segments[i].URI
+	// resolve against the final URL in case we were redirected.
+	if err := source.ResolveReferences(resp.Request.URL); err != nil {
+		log.Fatal("resolve playlist references:", err)
 	}
 	if err := injectBreak(source, 10*time.Second, 8); err != nil {
 		log.Fatalf("inject ad break: %v", err)
@@ -0,0 +1,138 @@
+// Command hlswatch polls live HLS media playlists and logs anomalies
+// between consecutive reloads, such as media sequence jumps,
+// rewritten segments and target duration changes.
+//
+// Usage:
+//
+//	hlswatch [-i interval] [-v] url ...
+//
+// When more than one url is given, each should be a rendition of the
+// same stream; hlswatch also reports renditions whose discontinuity
+// sequence numbers disagree for the same media sequence number.
+// hlswatch exits once every playlist has ended.
+//
+// The options are:
+//
+//	-i interval
+//		Reload playlists every interval, such as "2s". By default
+//		playlists are reloaded every target duration, as
+//		recommended by RFC 8216.
+//	-v
+//		Log every change, not just anomalies.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/untangledco/streaming/m3u8"
+)
+
+const usage string = "usage: hlswatch [-i interval] [-v] url ..."
+
+func init() {
+	log.SetFlags(log.LstdFlags)
+	log.SetPrefix("hlswatch: ")
+}
+
+var interval = flag.Duration("i", 0, "reload interval")
+var verbose = flag.Bool("v", false, "log all changes")
+
+func load(url string) (*m3u8.Playlist, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote status: %s", resp.Status)
+	}
+	p, warnings, err := m3u8.DecodeLenient(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range warnings {
+		log.Printf("%s: warning: %v", url, w)
+	}
+	if len(p.Variants) > 0 {
+		return nil, fmt.Errorf("master playlist; want media playlist")
+	}
+	return p, nil
+}
+
+// checkRenditions logs renditions whose discontinuity sequence number
+// differs from the others at the latest media sequence number
+// present in all of them.
+func checkRenditions(urls []string, playlists []*m3u8.Playlist) {
+	seq := -1
+	for _, p := range playlists {
+		if p == nil || len(p.Segments) == 0 {
+			return
+		}
+		last := p.Sequence + len(p.Segments) - 1
+		if seq < 0 || last < seq {
+			seq = last
+		}
+	}
+	want, ok := playlists[0].DiscontinuitySequenceAt(seq)
+	if !ok {
+		return
+	}
+	for i, p := range playlists[1:] {
+		n, ok := p.DiscontinuitySequenceAt(seq)
+		if ok && n != want {
+			log.Printf("discontinuity sequence mismatch at sequence %d: %s has %d, %s has %d", seq, urls[0], want, urls[i+1], n)
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+	urls := flag.Args()
+	if len(urls) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	playlists := make([]*m3u8.Playlist, len(urls))
+	for {
+		wait := *interval
+		ended := true
+		for i, url := range urls {
+			p, err := load(url)
+			if err != nil {
+				log.Printf("load %s: %v", url, err)
+				ended = false
+				continue
+			}
+			if playlists[i] != nil {
+				for _, c := range m3u8.Diff(playlists[i], p) {
+					if c.Kind.Anomaly() || *verbose {
+						log.Printf("%s: %s", url, c)
+					}
+				}
+			}
+			playlists[i] = p
+			if !p.End {
+				ended = false
+			}
+			if wait == 0 || (*interval == 0 && p.TargetDuration < wait) {
+				wait = p.TargetDuration
+			}
+		}
+		if len(urls) > 1 {
+			checkRenditions(urls, playlists)
+		}
+		if ended {
+			return
+		}
+		if wait <= 0 {
+			wait = time.Second
+		}
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,295 @@
+package m3u8
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// DecryptSegment decrypts data, the contents of a media segment
+// encrypted with the AES-128 method described by k.
+// The 16-byte key secret is typically retrieved from k.URI.
+// If k.IV is unset, the initialisation vector is derived from seq,
+// the media sequence number of the segment,
+// as specified in RFC 8216 section 5.2.
+func DecryptSegment(k *Key, secret []byte, seq int, data []byte) ([]byte, error) {
+	if k.Method != EncryptMethodAES128 {
+		return nil, fmt.Errorf("cannot decrypt whole segment with method %s", k.Method)
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("segment length %d not a multiple of block size %d", len(data), aes.BlockSize)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := segmentIV(k, seq)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv[:]).CryptBlocks(plain, data)
+	return unpad(plain)
+}
+
+// EncryptSegment encrypts data, the contents of a media segment
+// with media sequence number seq, using the AES-128 method described by k.
+// Data is padded using PKCS7 so the returned slice is always
+// longer than data.
+// See DecryptSegment for how the initialisation vector is chosen.
+func EncryptSegment(k *Key, secret []byte, seq int, data []byte) ([]byte, error) {
+	if k.Method != EncryptMethodAES128 {
+		return nil, fmt.Errorf("cannot encrypt whole segment with method %s", k.Method)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := segmentIV(k, seq)
+	if err != nil {
+		return nil, err
+	}
+	padded := pad(data)
+	cipher.NewCBCEncrypter(block, iv[:]).CryptBlocks(padded, padded)
+	return padded, nil
+}
+
+// segmentIV returns the initialisation vector of k. If k's IV is unset,
+// the IV is the big-endian representation of seq.
+func segmentIV(k *Key, seq int) ([16]byte, error) {
+	if k.IV != [16]byte{} {
+		return k.IV, nil
+	}
+	if seq < 0 {
+		return [16]byte{}, fmt.Errorf("negative media sequence number %d", seq)
+	}
+	var iv [16]byte
+	binary.BigEndian.PutUint64(iv[8:], uint64(seq))
+	return iv, nil
+}
+
+// pad returns a copy of b padded to a multiple of the AES block size
+// using PKCS7.
+func pad(b []byte) []byte {
+	n := aes.BlockSize - len(b)%aes.BlockSize
+	padded := make([]byte, len(b), len(b)+n)
+	copy(padded, b)
+	return append(padded, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func unpad(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, errors.New("empty buffer")
+	}
+	n := int(b[len(b)-1])
+	if n == 0 || n > aes.BlockSize || n > len(b) {
+		return nil, fmt.Errorf("bad padding length %d", n)
+	}
+	for _, c := range b[len(b)-n:] {
+		if int(c) != n {
+			return nil, errors.New("bad padding")
+		}
+	}
+	return b[:len(b)-n], nil
+}
+
+// The SAMPLE-AES method encrypts individual media samples rather
+// than whole segments, as described in Apple's "MPEG-2 Stream
+// Encryption Format for HTTP Live Streaming".
+// The following functions operate on elementary streams,
+// such as the reassembled payload of PES packets carried in MPEG-TS.
+// Re-packetising the stream is left to the caller.
+
+// EncryptSampleH264 encrypts the H.264 elementary stream es, in
+// Annex B byte stream format, using the SAMPLE-AES method described by k.
+// Only coded slice NAL units (types 1 and 5) longer than 48 bytes are encrypted.
+// See DecryptSegment for how the initialisation vector is chosen.
+func EncryptSampleH264(k *Key, secret []byte, seq int, es []byte) ([]byte, error) {
+	return cryptSampleH264(k, secret, seq, es, true)
+}
+
+// DecryptSampleH264 decrypts the H.264 elementary stream es
+// previously encrypted by EncryptSampleH264 or an equivalent packager.
+func DecryptSampleH264(k *Key, secret []byte, seq int, es []byte) ([]byte, error) {
+	return cryptSampleH264(k, secret, seq, es, false)
+}
+
+func cryptSampleH264(k *Key, secret []byte, seq int, es []byte, encrypt bool) ([]byte, error) {
+	if k.Method != EncryptMethodSampleAES {
+		return nil, fmt.Errorf("cannot encrypt samples with method %s", k.Method)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := segmentIV(k, seq)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(es))
+	var last int
+	for _, r := range nalUnits(es) {
+		out = append(out, es[last:r[0]]...)
+		out = append(out, cryptNAL(block, iv, es[r[0]:r[1]], encrypt)...)
+		last = r[1]
+	}
+	return append(out, es[last:]...), nil
+}
+
+// cryptNAL encrypts or decrypts a single NAL unit.
+// The NAL unit type byte and a leader of 31 bytes remain in the clear,
+// then every 16-byte block is encrypted followed by up to 144
+// unencrypted bytes. The CBC chain is reset for each NAL unit.
+func cryptNAL(block cipher.Block, iv [16]byte, nal []byte, encrypt bool) []byte {
+	typ := nal[0] & 0x1f
+	if typ != 1 && typ != 5 {
+		return nal
+	}
+	raw := unescapeNAL(nal)
+	if len(raw) <= 48 {
+		return nal
+	}
+	var mode cipher.BlockMode
+	if encrypt {
+		mode = cipher.NewCBCEncrypter(block, iv[:])
+	} else {
+		mode = cipher.NewCBCDecrypter(block, iv[:])
+	}
+	for i := 32; len(raw)-i > aes.BlockSize; i += aes.BlockSize + 144 {
+		mode.CryptBlocks(raw[i:i+aes.BlockSize], raw[i:i+aes.BlockSize])
+	}
+	return escapeNAL(raw)
+}
+
+// nalUnits returns the start and end offsets of each NAL unit in the
+// Annex B byte stream b, excluding start codes and trailing zero bytes.
+func nalUnits(b []byte) [][2]int {
+	startCode := []byte{0, 0, 1}
+	var units [][2]int
+	i := bytes.Index(b, startCode)
+	for i >= 0 {
+		start := i + len(startCode)
+		next := bytes.Index(b[start:], startCode)
+		end := len(b)
+		if next >= 0 {
+			end = start + next
+		}
+		// trailing zeroes belong to the next start code.
+		stop := end
+		for stop > start && b[stop-1] == 0 {
+			stop--
+		}
+		if stop > start {
+			units = append(units, [2]int{start, stop})
+		}
+		if next < 0 {
+			break
+		}
+		i = end
+	}
+	return units
+}
+
+// unescapeNAL returns a copy of nal with emulation prevention bytes removed.
+func unescapeNAL(nal []byte) []byte {
+	raw := make([]byte, 0, len(nal))
+	var zeroes int
+	for _, c := range nal {
+		if zeroes >= 2 && c == 0x03 {
+			zeroes = 0
+			continue
+		}
+		raw = append(raw, c)
+		if c == 0 {
+			zeroes++
+		} else {
+			zeroes = 0
+		}
+	}
+	return raw
+}
+
+// escapeNAL returns a copy of raw with emulation prevention bytes
+// inserted so that no start code appears within the NAL unit.
+func escapeNAL(raw []byte) []byte {
+	nal := make([]byte, 0, len(raw)+len(raw)/64)
+	var zeroes int
+	for _, c := range raw {
+		if zeroes >= 2 && c <= 0x03 {
+			nal = append(nal, 0x03)
+			zeroes = 0
+		}
+		nal = append(nal, c)
+		if c == 0 {
+			zeroes++
+		} else {
+			zeroes = 0
+		}
+	}
+	return nal
+}
+
+// EncryptSampleAAC encrypts es, a stream of AAC frames with ADTS headers,
+// using the SAMPLE-AES method described by k.
+// See DecryptSegment for how the initialisation vector is chosen.
+func EncryptSampleAAC(k *Key, secret []byte, seq int, es []byte) ([]byte, error) {
+	return cryptSampleAAC(k, secret, seq, es, true)
+}
+
+// DecryptSampleAAC decrypts es, a stream of AAC frames with ADTS headers
+// previously encrypted by EncryptSampleAAC or an equivalent packager.
+func DecryptSampleAAC(k *Key, secret []byte, seq int, es []byte) ([]byte, error) {
+	return cryptSampleAAC(k, secret, seq, es, false)
+}
+
+// cryptSampleAAC encrypts or decrypts each ADTS frame in es.
+// The ADTS header and a leader of 16 bytes remain in the clear,
+// followed by as many encrypted 16-byte blocks as fit in the frame.
+// Any trailing partial block is left unencrypted.
+// The CBC chain is reset for each frame.
+func cryptSampleAAC(k *Key, secret []byte, seq int, es []byte, encrypt bool) ([]byte, error) {
+	if k.Method != EncryptMethodSampleAES {
+		return nil, fmt.Errorf("cannot encrypt samples with method %s", k.Method)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := segmentIV(k, seq)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(es))
+	copy(out, es)
+	for buf := out; len(buf) > 0; {
+		if len(buf) < 7 {
+			return nil, fmt.Errorf("short ADTS header: %d bytes", len(buf))
+		}
+		if buf[0] != 0xff || buf[1]&0xf0 != 0xf0 {
+			return nil, fmt.Errorf("missing ADTS sync word")
+		}
+		hlen := 7
+		if buf[1]&0x01 == 0 {
+			hlen += 2 // CRC present
+		}
+		flen := int(buf[3]&0x03)<<11 | int(buf[4])<<3 | int(buf[5])>>5
+		if flen < hlen || flen > len(buf) {
+			return nil, fmt.Errorf("bad ADTS frame length %d", flen)
+		}
+		payload := buf[hlen:flen]
+		if len(payload) > aes.BlockSize {
+			payload = payload[aes.BlockSize:] // unencrypted leader
+			n := len(payload) - len(payload)%aes.BlockSize
+			if encrypt {
+				cipher.NewCBCEncrypter(block, iv[:]).CryptBlocks(payload[:n], payload[:n])
+			} else {
+				cipher.NewCBCDecrypter(block, iv[:]).CryptBlocks(payload[:n], payload[:n])
+			}
+		}
+		buf = buf[flen:]
+	}
+	return out, nil
+}
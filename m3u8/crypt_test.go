@@ -0,0 +1,176 @@
+package m3u8
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+func TestSegmentEncryption(t *testing.T) {
+	plain := []byte("an MPEG-TS segment, or something like it")
+	// openssl enc -aes-128-cbc -K 000102030405060708090a0b0c0d0e0f -iv 00000000000000000000000000000007
+	want, err := hex.DecodeString("d47e520324c7139152034ebccaba1aaf3ad5170e6bff921eebe2cb6d9933195fe0a9caaf716e96b147d6b8beddf05f38")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &Key{Method: EncryptMethodAES128, URI: "key.bin"}
+	seq := 7 // IV is unset, so derived from sequence number.
+	got, err := EncryptSegment(key, testSecret, seq, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncryptSegment() = %x, want %x", got, want)
+	}
+	decrypted, err := DecryptSegment(key, testSecret, seq, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plain) {
+		t.Errorf("DecryptSegment() = %q, want %q", decrypted, plain)
+	}
+
+	key.IV = [16]byte{15: 7}
+	decrypted, err = DecryptSegment(key, testSecret, 999, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plain) {
+		t.Errorf("decrypt with explicit IV: got %q, want %q", decrypted, plain)
+	}
+
+	if _, err := DecryptSegment(key, testSecret, seq, want[:20]); err == nil {
+		t.Errorf("nil error decrypting truncated segment")
+	}
+}
+
+func TestSampleH264(t *testing.T) {
+	slice := make([]byte, 300)
+	slice[0] = 0x65 // IDR slice
+	for i := 1; i < len(slice); i++ {
+		slice[i] = byte(i)
+	}
+	sps := []byte{0x67, 0x64, 0x00, 0x1f, 0xac, 0xd9}
+	var es []byte
+	es = append(es, 0, 0, 0, 1)
+	es = append(es, sps...)
+	es = append(es, 0, 0, 1)
+	es = append(es, slice...)
+
+	key := &Key{Method: EncryptMethodSampleAES}
+	enc, err := EncryptSampleH264(key, testSecret, 1, es)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc[:4+len(sps)+3+32], es[:4+len(sps)+3+32]) {
+		t.Errorf("parameter sets or unencrypted leader modified")
+	}
+	if bytes.Equal(enc, es) {
+		t.Fatalf("elementary stream unchanged after encryption")
+	}
+	if n := bytes.Count(enc, []byte{0, 0, 1}); n != 2 {
+		t.Errorf("found %d start codes in encrypted stream, want 2", n)
+	}
+	dec, err := DecryptSampleH264(key, testSecret, 1, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, es) {
+		t.Errorf("decrypted stream differs from source")
+		t.Logf("got:  %x", dec)
+		t.Logf("want: %x", es)
+	}
+}
+
+func TestSampleAAC(t *testing.T) {
+	frame := func(n int) []byte {
+		b := make([]byte, n)
+		copy(b, []byte{0xff, 0xf1, 0x50, 0x80, 0, 0, 0xfc})
+		b[3] |= byte(n >> 11)
+		b[4] = byte(n >> 3)
+		b[5] = byte(n<<5) | 0x1f
+		for i := 7; i < n; i++ {
+			b[i] = byte(i)
+		}
+		return b
+	}
+	es := append(frame(100), frame(71)...)
+	key := &Key{Method: EncryptMethodSampleAES, IV: [16]byte{0: 0xaa}}
+	enc, err := EncryptSampleAAC(key, testSecret, 0, es)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(enc) != len(es) {
+		t.Fatalf("encrypted length %d, want %d", len(enc), len(es))
+	}
+	// header + leader, then trailing partial block, are clear.
+	if !bytes.Equal(enc[:7+16], es[:7+16]) {
+		t.Errorf("header or leader modified")
+	}
+	if !bytes.Equal(enc[7+16+64:100], es[7+16+64:100]) {
+		t.Errorf("trailing partial block modified")
+	}
+	if bytes.Equal(enc[7+16:7+16+64], es[7+16:7+16+64]) {
+		t.Errorf("frame payload not encrypted")
+	}
+	dec, err := DecryptSampleAAC(key, testSecret, 0, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dec, es) {
+		t.Errorf("decrypted stream differs from source")
+	}
+	if _, err := DecryptSampleAAC(key, testSecret, 0, es[1:]); err == nil {
+		t.Errorf("nil error decrypting stream without sync word")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	k1 := &Key{Method: EncryptMethodAES128, URI: "1.key"}
+	k2 := &Key{Method: EncryptMethodAES128, URI: "2.key"}
+	p := &Playlist{
+		TargetDuration: 4 * time.Second,
+		Segments: []Segment{
+			{URI: "0.ts", Duration: 4 * time.Second, Key: k1},
+			{URI: "1.ts", Duration: 4 * time.Second, Key: k1},
+			{URI: "2.ts", Duration: 4 * time.Second, Key: k2},
+			{URI: "3.ts", Duration: 4 * time.Second},
+			{URI: "4.ts", Duration: 4 * time.Second, Key: k2},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, tagKey) {
+			keys = append(keys, line)
+		}
+	}
+	want := []string{
+		k1.String(),
+		k2.String(),
+		tagKey + ":METHOD=NONE",
+		k2.String(),
+	}
+	if strings.Join(keys, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected key tags")
+		t.Log("got:", keys)
+		t.Log("want:", want)
+	}
+
+	decoded, err := Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range p.Segments {
+		if !keysEqual(decoded.Segments[i].Key, p.Segments[i].Key) {
+			t.Errorf("segment %d: decoded key %v, want %v", i, decoded.Segments[i].Key, p.Segments[i].Key)
+		}
+	}
+}
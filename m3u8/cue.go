@@ -0,0 +1,508 @@
+package m3u8
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/untangledco/streaming/scte35"
+)
+
+// Tags signalling ad breaks which predate EXT-X-DATERANGE.
+// None are specified in RFC 8216, but are widely used by
+// packagers and ad decision servers.
+const (
+	tagCueOut     = "#EXT-X-CUE-OUT"
+	tagCueOutCont = "#EXT-X-CUE-OUT-CONT"
+	tagCueIn      = "#EXT-X-CUE-IN"
+	tagOATCLS     = "#EXT-OATCLS-SCTE35"
+	tagSCTE35     = "#EXT-X-SCTE35"
+)
+
+// cueTags holds the names of tags decoded into a Cue.
+var cueTags = map[string]bool{
+	tagCueOut:     true,
+	tagCueOutCont: true,
+	tagCueIn:      true,
+	tagOATCLS:     true,
+	tagSCTE35:     true,
+}
+
+// Cue represents one of the legacy tags used to signal ad breaks,
+// such as EXT-X-CUE-OUT, in place of EXT-X-DATERANGE.
+// See Playlist.CuesToDateRanges and Playlist.DateRangesToCues
+// to convert between the two.
+type Cue struct {
+	Type CueType
+	// Format is the dialect in which the cue is written.
+	Format CueFormat
+	// Duration is the expected duration of the break.
+	// A zero value indicates the duration is unknown.
+	Duration time.Duration
+	// Elapsed is the time elapsed since the start of the break.
+	// It only applies to cues of type CueOutCont.
+	Elapsed time.Duration
+	// Splice holds the SCTE-35 message signalling the cue, if any.
+	Splice *scte35.Splice
+	// ID identifies the cue. It is only written in format CueFormatSCTE35.
+	ID string
+}
+
+type CueType uint8
+
+const (
+	// CueSplice indicates a cue carrying only a splice,
+	// such as an EXT-OATCLS-SCTE35 tag on its own.
+	CueSplice CueType = iota
+	// CueOut marks the start of a break.
+	CueOut
+	// CueOutCont marks a segment in the middle of a break.
+	CueOutCont
+	// CueIn marks the end of a break.
+	CueIn
+)
+
+func (t CueType) String() string {
+	switch t {
+	case CueSplice:
+		return "splice"
+	case CueOut:
+		return "out"
+	case CueOutCont:
+		return "out continued"
+	case CueIn:
+		return "in"
+	}
+	return "invalid"
+}
+
+type CueFormat uint8
+
+const (
+	// CueFormatCueOut is the dialect of the EXT-X-CUE-OUT,
+	// EXT-X-CUE-OUT-CONT and EXT-X-CUE-IN tags. Splices are written
+	// in the EXT-OATCLS-SCTE35 tag, or the SCTE35 attribute of
+	// EXT-X-CUE-OUT-CONT.
+	CueFormatCueOut CueFormat = iota
+	// CueFormatSCTE35 is the dialect of the EXT-X-SCTE35 tag.
+	CueFormatSCTE35
+)
+
+// breakSegmentTypes maps the segmentation types which start and end
+// ad breaks to their cue type. Other paired types, such as chapters,
+// promos and overlay placement opportunities, do not leave the network.
+var breakSegmentTypes = map[uint8]CueType{
+	scte35.BreakStart:                   CueOut,
+	scte35.BreakEnd:                     CueIn,
+	scte35.ProviderAdStart:              CueOut,
+	scte35.ProviderAdEnd:                CueIn,
+	scte35.DistributorAdStart:           CueOut,
+	scte35.DistributorAdEnd:             CueIn,
+	scte35.ProviderPlacementOppStart:    CueOut,
+	scte35.ProviderPlacementOppEnd:      CueIn,
+	scte35.DistributorPlacementOppStart: CueOut,
+	scte35.DistributorPlacementOppEnd:   CueIn,
+	scte35.ProviderAdBlockStart:         CueOut,
+	scte35.ProviderAdBlockEnd:           CueIn,
+	scte35.DistributorAdBlockStart:      CueOut,
+	scte35.DistributorAdBlockEnd:        CueIn,
+}
+
+// CueFromSplice returns the cue signalled by splice.
+// Splices with an insert command or a time signal command with a
+// segmentation descriptor for an ad break are supported.
+func CueFromSplice(splice *scte35.Splice) (*Cue, error) {
+	if splice.Command == nil {
+		return nil, fmt.Errorf("nil command")
+	}
+	cue := &Cue{Splice: splice}
+	switch splice.Command.Type {
+	case scte35.SpliceInsert:
+		ins := splice.Command.Insert
+		if ins == nil {
+			return nil, fmt.Errorf("nil insert")
+		}
+		cue.Type = CueIn
+		if ins.OutOfNetwork {
+			cue.Type = CueOut
+		}
+		if ins.Duration != nil {
+			cue.Duration = ticksToDuration(ins.Duration.Duration)
+		}
+		cue.ID = strconv.FormatUint(uint64(ins.ID), 10)
+		return cue, nil
+	case scte35.TimeSignal:
+		for _, d := range splice.Descriptors {
+			seg, ok := d.(scte35.SegmentationDescriptor)
+			if !ok {
+				continue
+			}
+			typ, ok := breakSegmentTypes[seg.Type]
+			if !ok {
+				continue
+			}
+			cue.Type = typ
+			if seg.Duration != nil {
+				cue.Duration = ticksToDuration(*seg.Duration)
+			}
+			cue.ID = strconv.FormatUint(uint64(seg.EventID), 10)
+			return cue, nil
+		}
+		return nil, fmt.Errorf("no segmentation descriptor for a break")
+	}
+	return nil, fmt.Errorf("unsupported command %s", splice.Command.Type)
+}
+
+// ticksToDuration converts ticks of a 90KHz clock to a duration.
+func ticksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * time.Second / 90000
+}
+
+func (c *Cue) MarshalText() ([]byte, error) {
+	var splice string
+	if c.Splice != nil {
+		b, err := scte35.Encode(c.Splice)
+		if err != nil {
+			return nil, fmt.Errorf("encode splice: %w", err)
+		}
+		splice = base64.StdEncoding.EncodeToString(b)
+	}
+
+	if c.Format == CueFormatSCTE35 {
+		if c.Splice == nil {
+			return nil, fmt.Errorf("nil splice")
+		}
+		attrs := []string{fmt.Sprintf("CUE=%q", splice)}
+		if c.ID != "" {
+			attrs = append(attrs, fmt.Sprintf("ID=%q", c.ID))
+		}
+		if c.Duration > 0 {
+			attrs = append(attrs, "DURATION="+formatSeconds(c.Duration))
+		}
+		switch c.Type {
+		case CueOut:
+			attrs = append(attrs, "CUE-OUT=YES")
+		case CueOutCont:
+			attrs = append(attrs, "ELAPSED="+formatSeconds(c.Elapsed), "CUE-OUT=CONT")
+		case CueIn:
+			attrs = append(attrs, "CUE-IN=YES")
+		case CueSplice:
+		default:
+			return nil, fmt.Errorf("invalid cue type %d", c.Type)
+		}
+		return []byte(tagSCTE35 + ":" + strings.Join(attrs, ",")), nil
+	} else if c.Format != CueFormatCueOut {
+		return nil, fmt.Errorf("invalid cue format %d", c.Format)
+	}
+
+	var tags []string
+	if c.Splice != nil && c.Type != CueOutCont {
+		tags = append(tags, tagOATCLS+":"+splice)
+	}
+	switch c.Type {
+	case CueSplice:
+		if c.Splice == nil {
+			return nil, fmt.Errorf("nil splice")
+		}
+	case CueOut:
+		if c.Duration > 0 {
+			tags = append(tags, tagCueOut+":"+formatSeconds(c.Duration))
+		} else {
+			tags = append(tags, tagCueOut)
+		}
+	case CueOutCont:
+		if c.Elapsed > c.Duration && c.Duration > 0 {
+			return nil, fmt.Errorf("elapsed time %s longer than duration %s", c.Elapsed, c.Duration)
+		}
+		attrs := []string{
+			"ElapsedTime=" + formatSeconds(c.Elapsed),
+			"Duration=" + formatSeconds(c.Duration),
+		}
+		if c.Splice != nil {
+			attrs = append(attrs, "SCTE35="+splice)
+		}
+		tags = append(tags, tagCueOutCont+":"+strings.Join(attrs, ","))
+	case CueIn:
+		tags = append(tags, tagCueIn)
+	default:
+		return nil, fmt.Errorf("invalid cue type %d", c.Type)
+	}
+	return []byte(strings.Join(tags, "\n")), nil
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// decodeTag decodes tag into c. Values from tags of the same cue,
+// such as EXT-OATCLS-SCTE35 followed by EXT-X-CUE-OUT,
+// are merged into c.
+func (c *Cue) decodeTag(tag Tag) error {
+	var err error
+	switch tag.Name {
+	case tagOATCLS:
+		c.Splice, err = decodeSplice(tag.Value)
+		if err != nil {
+			return fmt.Errorf("decode splice: %w", err)
+		}
+	case tagCueOut:
+		c.Type = CueOut
+		v := strings.TrimPrefix(tag.Value, "DURATION=")
+		if v == "" {
+			return nil
+		}
+		c.Duration, err = parseSegmentDuration(v)
+		if err != nil {
+			return fmt.Errorf("parse duration: %w", err)
+		}
+	case tagCueOutCont:
+		c.Type = CueOutCont
+		return c.decodeCont(tag.Value)
+	case tagCueIn:
+		c.Type = CueIn
+	case tagSCTE35:
+		c.Format = CueFormatSCTE35
+		return c.decodeSCTE35(tag.Value)
+	default:
+		return fmt.Errorf("unknown cue tag %s", tag.Name)
+	}
+	return nil
+}
+
+// decodeCont decodes the value of an EXT-X-CUE-OUT-CONT tag,
+// which is either in the form "10/30" or as attributes
+// "ElapsedTime=10,Duration=30,SCTE35=...".
+func (c *Cue) decodeCont(s string) error {
+	var err error
+	if !strings.Contains(s, "=") {
+		elapsed, dur, ok := strings.Cut(s, "/")
+		if !ok {
+			return fmt.Errorf("bad value %q", s)
+		}
+		if c.Elapsed, err = parseSegmentDuration(elapsed); err != nil {
+			return fmt.Errorf("parse elapsed time: %w", err)
+		}
+		if c.Duration, err = parseSegmentDuration(dur); err != nil {
+			return fmt.Errorf("parse duration: %w", err)
+		}
+		return nil
+	}
+	for _, attr := range splitAttributes(s) {
+		k, v, _ := strings.Cut(attr, "=")
+		switch k {
+		case "ElapsedTime":
+			c.Elapsed, err = parseSegmentDuration(v)
+		case "Duration":
+			c.Duration, err = parseSegmentDuration(v)
+		case "SCTE35":
+			c.Splice, err = decodeSplice(v)
+		default:
+			err = fmt.Errorf("unknown attribute")
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// decodeSCTE35 decodes the attributes of an EXT-X-SCTE35 tag.
+// Attributes other than those held in Cue, such as UPID, are ignored.
+func (c *Cue) decodeSCTE35(s string) error {
+	var err error
+	for _, attr := range splitAttributes(s) {
+		k, v, _ := strings.Cut(attr, "=")
+		v = strings.Trim(v, `"`)
+		switch k {
+		case "CUE":
+			c.Splice, err = decodeSplice(v)
+		case "ID":
+			c.ID = v
+		case "DURATION":
+			c.Duration, err = parseSegmentDuration(v)
+		case "ELAPSED":
+			c.Elapsed, err = parseSegmentDuration(v)
+		case "CUE-OUT":
+			switch v {
+			case "YES":
+				c.Type = CueOut
+			case "CONT":
+				c.Type = CueOutCont
+			case "NO":
+			default:
+				err = fmt.Errorf("bad value %q", v)
+			}
+		case "CUE-IN":
+			if v == "YES" {
+				c.Type = CueIn
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	if c.Splice == nil {
+		return fmt.Errorf("missing CUE attribute")
+	}
+	return nil
+}
+
+// decodeSplice decodes a splice encoded in base64, or in
+// hexadecimal if prefixed with "0x".
+func decodeSplice(s string) (*scte35.Splice, error) {
+	var b []byte
+	var err error
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		b, err = hex.DecodeString(s[2:])
+	} else {
+		b, err = base64.StdEncoding.DecodeString(s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return scte35.Decode(b)
+}
+
+// splitAttributes splits s into attributes separated by commas,
+// ignoring commas in quoted strings.
+func splitAttributes(s string) []string {
+	var attrs []string
+	var quoted bool
+	start := 0
+	for i, r := range s {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				attrs = append(attrs, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(attrs, s[start:])
+}
+
+// CuesToDateRanges replaces the cues of p's segments with
+// equivalent date ranges. Each break signalled by a cue out and its
+// following cue in is represented by date ranges sharing the same ID.
+// Start times are calculated from the program date time of preceding
+// segments, so a cue before any segment with a DateTime is an error.
+func (p *Playlist) CuesToDateRanges() error {
+	var t time.Time
+	var out *DateRange
+	for i := range p.Segments {
+		seg := &p.Segments[i]
+		if !seg.DateTime.IsZero() {
+			t = seg.DateTime
+		}
+		if c := seg.Cue; c != nil {
+			if c.Type != CueOutCont && t.IsZero() {
+				return fmt.Errorf("segment %d: cue with unknown program date time", i)
+			}
+			id := c.ID
+			if id == "" {
+				id = strconv.Itoa(p.Sequence + i)
+			}
+			switch c.Type {
+			case CueSplice:
+				seg.DateRanges = append(seg.DateRanges, DateRange{ID: id, Start: t, CueCommand: c.Splice})
+			case CueOut:
+				out = &DateRange{ID: id, Start: t, Planned: c.Duration, CueOut: c.Splice}
+				seg.DateRanges = append(seg.DateRanges, *out)
+			case CueIn:
+				dr := DateRange{ID: id, Start: t, CueIn: c.Splice}
+				if out != nil {
+					dr.ID = out.ID
+					dr.Start = out.Start
+					dr.End = t
+					dr.Duration = t.Sub(out.Start)
+				}
+				seg.DateRanges = append(seg.DateRanges, dr)
+				out = nil
+			}
+			seg.Cue = nil
+		}
+		if !t.IsZero() {
+			t = t.Add(seg.Duration)
+		}
+	}
+	return nil
+}
+
+// DateRangesToCues replaces date ranges of p's segments which hold
+// SCTE-35 splices with equivalent cues in the format f.
+// Segments following a cue out are marked with cues of type
+// CueOutCont until a date range ending the break, or until the planned
+// duration of the break has elapsed, when the next segment is marked
+// with a cue in.
+// As a segment holds at most one cue, only the first such date range
+// of each segment is replaced.
+// Date ranges without splices are left unmodified.
+func (p *Playlist) DateRangesToCues(f CueFormat) error {
+	// the break in progress, if any.
+	var out *Cue
+	for i := range p.Segments {
+		seg := &p.Segments[i]
+		if seg.Cue != nil {
+			return fmt.Errorf("segment %d: segment already has cue", i)
+		}
+		var dr *DateRange
+		for j := range seg.DateRanges {
+			r := seg.DateRanges[j]
+			if r.CueOut != nil || r.CueIn != nil || r.CueCommand != nil || out != nil && r.ID == out.ID {
+				dr = &r
+				seg.DateRanges = append(seg.DateRanges[:j:j], seg.DateRanges[j+1:]...)
+				if len(seg.DateRanges) == 0 {
+					seg.DateRanges = nil
+				}
+				break
+			}
+		}
+		switch {
+		case dr != nil && dr.CueOut != nil:
+			dur := dr.Planned
+			if dur == 0 {
+				dur = dr.Duration
+			}
+			if dur == 0 {
+				if c, err := CueFromSplice(dr.CueOut); err == nil {
+					dur = c.Duration
+				}
+			}
+			seg.Cue = &Cue{Type: CueOut, Format: f, Duration: dur, Splice: dr.CueOut, ID: dr.ID}
+			out = seg.Cue
+			continue
+		case dr != nil && (dr.CueIn != nil || out != nil && dr.ID == out.ID):
+			seg.Cue = &Cue{Type: CueIn, Format: f, Splice: dr.CueIn, ID: dr.ID}
+			out = nil
+			continue
+		case dr != nil && dr.CueCommand != nil:
+			seg.Cue = &Cue{Type: CueSplice, Format: f, Splice: dr.CueCommand, ID: dr.ID}
+		}
+
+		if out == nil {
+			continue
+		}
+		elapsed := out.Elapsed + p.Segments[i-1].Duration
+		if out.Duration > 0 && elapsed >= out.Duration {
+			if seg.Cue == nil {
+				seg.Cue = &Cue{Type: CueIn, Format: f, ID: out.ID}
+				if f == CueFormatSCTE35 {
+					// EXT-X-SCTE35 requires a splice.
+					seg.Cue.Splice = out.Splice
+				}
+			}
+			out = nil
+			continue
+		}
+		if seg.Cue == nil {
+			seg.Cue = &Cue{Type: CueOutCont, Format: f, Duration: out.Duration, Elapsed: elapsed, Splice: out.Splice, ID: out.ID}
+		}
+		out = &Cue{Duration: out.Duration, Elapsed: elapsed, Splice: out.Splice, ID: out.ID}
+	}
+	return nil
+}
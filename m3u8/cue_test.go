@@ -0,0 +1,250 @@
+package m3u8
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/untangledco/streaming/scte35"
+)
+
+// spliceOut is a splice insert starting a 15 second break.
+const spliceOut = "/DAlAAAAAAAA///wFAUAAAABf+/+ANgNkv4AFJlwAAEBAQAAVnzxXw=="
+
+const testCues = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00Z
+#EXTINF:10.000
+001.ts
+#EXT-OATCLS-SCTE35:` + spliceOut + `
+#EXT-X-ASSET:CAID=0x0000000020FB6501
+#EXT-X-CUE-OUT:15
+#EXTINF:10.000
+002.ts
+#EXTINF:5.000
+#EXT-X-CUE-OUT-CONT:ElapsedTime=10,Duration=15
+003.ts
+#EXT-X-CUE-IN
+#EXTINF:10.000
+004.ts
+#EXT-X-SCTE35:CUE="` + spliceOut + `",ID="abc,123",CUE-OUT=YES,UPID="0x0F"
+#EXTINF:10.000
+005.ts
+#EXT-X-CUE-OUT-CONT:10/15
+#EXTINF:5.000
+006.ts
+#EXT-X-CUE-OUT:DURATION=30.5
+#EXTINF:10.000
+007.ts
+#EXT-X-ENDLIST
+`
+
+func TestDecodeCues(t *testing.T) {
+	p, err := Decode(strings.NewReader(testCues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Cue{
+		nil,
+		{Type: CueOut, Duration: 15 * time.Second},
+		{Type: CueOutCont, Elapsed: 10 * time.Second, Duration: 15 * time.Second},
+		{Type: CueIn},
+		{Type: CueOut, Format: CueFormatSCTE35, ID: "abc,123"},
+		{Type: CueOutCont, Elapsed: 10 * time.Second, Duration: 15 * time.Second},
+		{Type: CueOut, Duration: 30500 * time.Millisecond},
+	}
+	for i, seg := range p.Segments {
+		got := seg.Cue
+		if got != nil {
+			// compare splices separately.
+			c := *got
+			c.Splice = nil
+			got = &c
+		}
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("segment %d: cue = %+v, want %+v", i, got, want[i])
+		}
+	}
+	if p.Segments[1].Cue.Splice == nil || p.Segments[4].Cue.Splice == nil {
+		t.Errorf("cue splice not decoded")
+	}
+	if len(p.Segments[1].Tags) != 1 {
+		t.Errorf("want 1 custom tag alongside cue, got %v", p.Segments[1].Tags)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.String()
+	again, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("decode encoded playlist: %v", err)
+	}
+	if !reflect.DeepEqual(p, again) {
+		t.Errorf("cues not preserved when encoding")
+		t.Log(encoded)
+	}
+}
+
+// Malformed cues are kept as custom tags so they are not lost
+// when a playlist is decoded and encoded again.
+func TestBadCues(t *testing.T) {
+	badCRC := []byte(spliceOut)
+	badCRC[len(badCRC)-3] = 'A'
+	var cases = []string{
+		"#EXT-X-CUE-OUT:abc",
+		"#EXT-X-CUE-OUT-CONT:10",
+		"#EXT-X-CUE-OUT-CONT:Elapsed=10",
+		"#EXT-OATCLS-SCTE35:!!!",
+		"#EXT-OATCLS-SCTE35:" + string(badCRC),
+		"#EXT-X-SCTE35:ID=\"123\"",
+		"#EXT-X-SCTE35:CUE=\"" + spliceOut + "\",CUE-OUT=MAYBE",
+	}
+	for _, tag := range cases {
+		s := "#EXTM3U\n" + tag + "\n#EXTINF:10.000\n001.ts\n"
+		p, warnings, err := DecodeLenient(strings.NewReader(s))
+		if err != nil {
+			t.Errorf("decode %s: %v", tag, err)
+			continue
+		}
+		if len(warnings) != 1 {
+			t.Errorf("decode %s: want 1 warning, got %v", tag, warnings)
+		}
+		seg := p.Segments[0]
+		if seg.Cue != nil {
+			t.Errorf("decode %s: want no cue, got %+v", tag, seg.Cue)
+		}
+		buf := &bytes.Buffer{}
+		if err := Encode(buf, p); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tag+"\n") {
+			t.Errorf("%s not kept after encoding:\n%s", tag, buf)
+		}
+		if _, err := Decode(strings.NewReader(s)); err != nil {
+			t.Errorf("strict decode %s: %v", tag, err)
+		}
+	}
+
+	// Malformed tags keep their place among the tags of a cue.
+	for _, tags := range []string{
+		"#EXT-OATCLS-SCTE35:" + string(badCRC) + "\n#EXT-X-CUE-OUT:15\n",
+		"#EXT-OATCLS-SCTE35:" + spliceOut + "\n#EXT-X-CUE-OUT:abc\n",
+	} {
+		p, _, err := DecodeLenient(strings.NewReader("#EXTM3U\n" + tags + "#EXTINF:10.000\n001.ts\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Segments[0].Cue == nil {
+			t.Errorf("valid cue tag not decoded from %q", tags)
+		}
+		buf := &bytes.Buffer{}
+		if err := Encode(buf, p); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tags) {
+			t.Errorf("cue tags reordered, want %q in:\n%s", tags, buf)
+		}
+	}
+}
+
+func TestCueConversion(t *testing.T) {
+	p, err := Decode(strings.NewReader(testCues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Segments = p.Segments[:4]
+	splice := p.Segments[1].Cue.Splice
+	if err := p.CuesToDateRanges(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 10, 0, time.UTC)
+	end := start.Add(15 * time.Second)
+	want := [][]DateRange{
+		nil,
+		{{ID: "1", Start: start, Planned: 15 * time.Second, CueOut: splice}},
+		nil,
+		{{ID: "1", Start: start, End: end, Duration: 15 * time.Second}},
+	}
+	for i, seg := range p.Segments {
+		if seg.Cue != nil {
+			t.Errorf("segment %d: cue not removed", i)
+		}
+		if !reflect.DeepEqual(seg.DateRanges, want[i]) {
+			t.Errorf("segment %d: date ranges = %+v, want %+v", i, seg.DateRanges, want[i])
+		}
+	}
+
+	if err := p.DateRangesToCues(CueFormatCueOut); err != nil {
+		t.Fatal(err)
+	}
+	cues := []*Cue{
+		nil,
+		{Type: CueOut, Duration: 15 * time.Second, Splice: splice, ID: "1"},
+		{Type: CueOutCont, Elapsed: 10 * time.Second, Duration: 15 * time.Second, Splice: splice, ID: "1"},
+		{Type: CueIn, ID: "1"},
+	}
+	for i, seg := range p.Segments {
+		if seg.DateRanges != nil {
+			t.Errorf("segment %d: date range not removed", i)
+		}
+		if !reflect.DeepEqual(seg.Cue, cues[i]) {
+			t.Errorf("segment %d: cue = %+v, want %+v", i, seg.Cue, cues[i])
+		}
+	}
+}
+
+func TestCueFromSplice(t *testing.T) {
+	splice, err := decodeSplice(spliceOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cue, err := CueFromSplice(splice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Cue{Type: CueOut, Duration: 15 * time.Second, Splice: splice, ID: "1"}
+	if !reflect.DeepEqual(cue, want) {
+		t.Errorf("CueFromSplice() = %+v, want %+v", cue, want)
+	}
+}
+
+func TestCueFromSegmentation(t *testing.T) {
+	var tests = []struct {
+		typ  uint8
+		want CueType
+		err  bool
+	}{
+		{scte35.ProviderPlacementOppStart, CueOut, false},
+		{scte35.DistributorAdBlockEnd, CueIn, false},
+		{scte35.BreakEnd, CueIn, false},
+		{0x24, 0, true}, // opening credit start
+		{0x3c, 0, true}, // provider promo start
+		{scte35.ProviderOverlayPlacementOppStart, 0, true},
+		{scte35.ChapterStart, 0, true},
+	}
+	for _, tt := range tests {
+		pts := uint64(0)
+		splice := &scte35.Splice{
+			Command:     &scte35.Command{Type: scte35.TimeSignal, TimeSignal: &pts},
+			Descriptors: []scte35.SpliceDescriptor{scte35.SegmentationDescriptor{EventID: 1, Type: tt.typ}},
+		}
+		cue, err := CueFromSplice(splice)
+		if tt.err {
+			if err == nil {
+				t.Errorf("segmentation type %#x: want error, got cue %s", tt.typ, cue.Type)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("segmentation type %#x: %v", tt.typ, err)
+		} else if cue.Type != tt.want {
+			t.Errorf("segmentation type %#x: want cue %s, got %s", tt.typ, tt.want, cue.Type)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package m3u8
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const tagDefine = "#EXT-X-DEFINE" // RFC 8216bis, 4.4.2.3
+
+// Define represents the EXT-X-DEFINE tag.
+// It defines a variable which may be referenced as "{$name}"
+// in URI lines and quoted-string attribute values.
+type Define struct {
+	Name string
+	// Value holds the value of the variable. For imported or query
+	// parameter variables, Value is empty until set by Resolve.
+	Value string
+	// Type indicates where the variable's value comes from.
+	Type DefineType
+}
+
+type DefineType uint8
+
+const (
+	// The variable's value is set in the playlist itself,
+	// with the NAME and VALUE attributes.
+	DefineValue DefineType = iota
+	// The variable is imported from the multivariant playlist which
+	// loaded this playlist, with the IMPORT attribute.
+	DefineImport
+	// The variable's value is taken from the query parameter of the
+	// same name in the playlist's URL, with the QUERYPARAM attribute.
+	DefineQueryParam
+)
+
+func (t DefineType) String() string {
+	switch t {
+	case DefineValue:
+		return "value"
+	case DefineImport:
+		return "import"
+	case DefineQueryParam:
+		return "query parameter"
+	}
+	return "invalid"
+}
+
+func (d Define) String() string {
+	switch d.Type {
+	case DefineImport:
+		return fmt.Sprintf("%s:IMPORT=%q", tagDefine, d.Name)
+	case DefineQueryParam:
+		return fmt.Sprintf("%s:QUERYPARAM=%q", tagDefine, d.Name)
+	}
+	return fmt.Sprintf("%s:NAME=%q,VALUE=%q", tagDefine, d.Name, d.Value)
+}
+
+func parseDefine(l *lexer) (Define, error) {
+	var def Define
+	var hasValue bool
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return def, errors.New(it.val)
+		case itemComma:
+			continue
+		case itemNewline:
+			if def.Name == "" {
+				return def, fmt.Errorf("missing variable name")
+			}
+			if def.Type == DefineValue && !hasValue {
+				return def, fmt.Errorf("missing value for %s", def.Name)
+			} else if def.Type != DefineValue && hasValue {
+				return def, fmt.Errorf("value set for %s variable %s", def.Type, def.Name)
+			}
+			return def, nil
+		}
+		if it.typ != itemAttrName {
+			return def, fmt.Errorf("unexpected %s %q", it.typ, it.val)
+		}
+		attr := it.val
+		it = l.nextItem()
+		if it.typ != itemEquals {
+			return def, fmt.Errorf("expected %q after %s, got %q", "=", attr, it.val)
+		}
+		it = l.nextItem()
+		if it.typ != itemString {
+			return def, fmt.Errorf("parse %s: unexpected %s", attr, it)
+		}
+		val := strings.Trim(it.val, `"`)
+		switch attr {
+		case "NAME", "IMPORT", "QUERYPARAM":
+			if def.Name != "" {
+				return def, fmt.Errorf("%s: variable name already set", attr)
+			}
+			if !isVariableName(val) {
+				return def, fmt.Errorf("invalid variable name %q", val)
+			}
+			def.Name = val
+			if attr == "IMPORT" {
+				def.Type = DefineImport
+			} else if attr == "QUERYPARAM" {
+				def.Type = DefineQueryParam
+			}
+		case "VALUE":
+			def.Value = val
+			hasValue = true
+		default:
+			return def, fmt.Errorf("unexpected attribute %q", attr)
+		}
+	}
+	return def, fmt.Errorf("unexpected end of tag")
+}
+
+func isVariableName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve sets the value of every variable defined in p, then
+// substitutes all variable references in p's URIs and quoted-string
+// attribute values.
+// Imported variables are looked up in parent, the multivariant
+// playlist which references p. Parent should already be resolved.
+// Variables defined by query parameter are looked up in u, the URL
+// from which p was retrieved.
+// Either parent or u may be nil if p has no such definitions.
+func (p *Playlist) Resolve(parent *Playlist, u *url.URL) error {
+	vars := make(map[string]string)
+	for i := range p.Defines {
+		def := &p.Defines[i]
+		if _, ok := vars[def.Name]; ok {
+			return fmt.Errorf("variable %s already defined", def.Name)
+		}
+		switch def.Type {
+		case DefineImport:
+			if parent == nil {
+				return fmt.Errorf("import %s: no parent playlist", def.Name)
+			}
+			var found bool
+			for _, pdef := range parent.Defines {
+				if pdef.Name == def.Name {
+					def.Value = pdef.Value
+					found = true
+				}
+			}
+			if !found {
+				return fmt.Errorf("import %s: not defined in parent playlist", def.Name)
+			}
+		case DefineQueryParam:
+			if u == nil {
+				return fmt.Errorf("query parameter %s: no playlist URL", def.Name)
+			}
+			q := u.Query()
+			if !q.Has(def.Name) {
+				return fmt.Errorf("query parameter %s not in playlist URL", def.Name)
+			}
+			def.Value = q.Get(def.Name)
+		}
+		vars[def.Name] = def.Value
+	}
+	return p.substitute(vars)
+}
+
+func (p *Playlist) substitute(vars map[string]string) error {
+	var err error
+	expand := func(s *string) {
+		if err != nil {
+			return
+		}
+		*s, err = expandVariables(*s, vars)
+	}
+	expandKey := func(k *Key) {
+		if k == nil {
+			return
+		}
+		expand(&k.URI)
+		expand(&k.Format)
+	}
+	expandDateRanges := func(ranges []DateRange) {
+		for i := range ranges {
+			dr := &ranges[i]
+			expand(&dr.ID)
+			expand(&dr.Class)
+			for name, v := range dr.Custom {
+				if s, ok := v.(string); ok {
+					expand(&s)
+					dr.Custom[name] = s
+				}
+			}
+			if in := dr.Interstitial; in != nil {
+				expand(&in.AssetURI)
+				expand(&in.AssetList)
+			}
+		}
+	}
+
+	for i := range p.Segments {
+		seg := &p.Segments[i]
+		expand(&seg.URI)
+		expandKey(seg.Key)
+		if seg.Map != nil {
+			expand(&seg.Map.URI)
+		}
+		expandDateRanges(seg.DateRanges)
+	}
+	expandDateRanges(p.DateRanges)
+	for i := range p.Media {
+		r := &p.Media[i]
+		expand(&r.URI)
+		expand(&r.Group)
+		expand(&r.Language)
+		expand(&r.AssocLanguage)
+		expand(&r.Name)
+		expand(&r.PathwayID)
+		expand(&r.StableRenditionID)
+		for j := range r.Characteristics {
+			expand(&r.Characteristics[j])
+		}
+	}
+	for i := range p.Variants {
+		v := &p.Variants[i]
+		expand(&v.URI)
+		for j := range v.Codecs {
+			expand(&v.Codecs[j])
+		}
+		expand(&v.Audio)
+		expand(&v.Video)
+		expand(&v.Subtitles)
+		expand(&v.ClosedCaptions)
+		expand(&v.PathwayID)
+		expand(&v.StableVariantID)
+		for j := range v.SupplementalCodecs {
+			expand(&v.SupplementalCodecs[j])
+		}
+	}
+	for i := range p.IFrames {
+		f := &p.IFrames[i]
+		expand(&f.URI)
+		for j := range f.Codecs {
+			expand(&f.Codecs[j])
+		}
+		expand(&f.Video)
+		expand(&f.PathwayID)
+		expand(&f.StableVariantID)
+		for j := range f.SupplementalCodecs {
+			expand(&f.SupplementalCodecs[j])
+		}
+	}
+	for i := range p.SessionData {
+		sd := &p.SessionData[i]
+		expand(&sd.ID)
+		expand(&sd.Value)
+		expand(&sd.URI)
+		expand(&sd.Language)
+	}
+	expandKey(p.SessionKey)
+	if p.ContentSteering != nil {
+		expand(&p.ContentSteering.ServerURI)
+		expand(&p.ContentSteering.PathwayID)
+	}
+	return err
+}
+
+// expandVariables replaces each variable reference in s,
+// such as "{$token}", with the corresponding value in vars.
+func expandVariables(s string, vars map[string]string) (string, error) {
+	if !strings.Contains(s, "{$") {
+		return s, nil
+	}
+	sb := &strings.Builder{}
+	for {
+		before, after, found := strings.Cut(s, "{$")
+		sb.WriteString(before)
+		if !found {
+			break
+		}
+		name, rest, found := strings.Cut(after, "}")
+		if !found || !isVariableName(name) {
+			// not a reference; keep it as-is.
+			sb.WriteString("{$")
+			s = after
+			continue
+		}
+		v, ok := vars[name]
+		if !ok {
+			return "", fmt.Errorf("undefined variable %s", name)
+		}
+		sb.WriteString(v)
+		s = rest
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,129 @@
+package m3u8
+
+import (
+	"bytes"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMultivariant = `#EXTM3U
+#EXT-X-DEFINE:NAME="cdn",VALUE="https://cdn1.example.com"
+#EXT-X-DEFINE:QUERYPARAM="token"
+#EXT-X-DEFINE:NAME="codec",VALUE="dvh1.08.07"
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,AUDIO="{$cdn}",STABLE-VARIANT-ID="low-{$token}",SUPPLEMENTAL-CODECS="{$codec}/db4h"
+{$cdn}/low.m3u8?token={$token}
+`
+
+const testMedia = `#EXTM3U
+#EXT-X-DEFINE:IMPORT="cdn"
+#EXT-X-DEFINE:IMPORT="token"
+#EXT-X-DEFINE:NAME="dir",VALUE="segments"
+#EXT-X-TARGETDURATION:4
+#EXT-X-KEY:METHOD=AES-128,URI="{$cdn}/key?token={$token}"
+#EXT-X-DATERANGE:ID="ad",START-DATE="2024-01-01T00:00:00Z",X-BEACON="{$cdn}/beacon",X-COUNT=1
+#EXTINF:4.000
+{$cdn}/{$dir}/001.ts
+`
+
+func TestResolve(t *testing.T) {
+	master, err := Decode(strings.NewReader(testMultivariant))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Define{
+		{Name: "cdn", Value: "https://cdn1.example.com"},
+		{Name: "token", Type: DefineQueryParam},
+		{Name: "codec", Value: "dvh1.08.07"},
+	}
+	if !reflect.DeepEqual(master.Defines, want) {
+		t.Errorf("decoded defines %v, want %v", master.Defines, want)
+	}
+	if err := master.Resolve(nil, nil); err == nil {
+		t.Errorf("nil error resolving query parameter without URL")
+	}
+	u, err := url.Parse("https://example.com/master.m3u8?token=abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := master.Resolve(nil, u); err != nil {
+		t.Fatalf("resolve master playlist: %v", err)
+	}
+	if master.Defines[1].Value != "abc123" {
+		t.Errorf("query parameter value %q, want %q", master.Defines[1].Value, "abc123")
+	}
+	wantURI := "https://cdn1.example.com/low.m3u8?token=abc123"
+	v := master.Variants[0]
+	if v.URI != wantURI {
+		t.Errorf("variant URI %q, want %q", v.URI, wantURI)
+	}
+	if v.StableVariantID != "low-abc123" {
+		t.Errorf("stable variant ID %q, want %q", v.StableVariantID, "low-abc123")
+	}
+	if len(v.SupplementalCodecs) != 1 || v.SupplementalCodecs[0] != "dvh1.08.07/db4h" {
+		t.Errorf("supplemental codecs %q, want %q", v.SupplementalCodecs, "dvh1.08.07/db4h")
+	}
+
+	media, err := Decode(strings.NewReader(testMedia))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := media.Resolve(master, nil); err != nil {
+		t.Fatalf("resolve media playlist: %v", err)
+	}
+	seg := media.Segments[0]
+	if seg.URI != "https://cdn1.example.com/segments/001.ts" {
+		t.Errorf("unexpected segment URI %q", seg.URI)
+	}
+	if seg.Key.URI != "https://cdn1.example.com/key?token=abc123" {
+		t.Errorf("unexpected key URI %q", seg.Key.URI)
+	}
+	custom := map[string]any{"X-BEACON": "https://cdn1.example.com/beacon", "X-COUNT": 1.0}
+	if !reflect.DeepEqual(seg.DateRanges[0].Custom, custom) {
+		t.Errorf("date range attributes %v, want %v", seg.DateRanges[0].Custom, custom)
+	}
+}
+
+func TestExpandVariables(t *testing.T) {
+	vars := map[string]string{"a": "1", "b-c": "2"}
+	var tests = []struct {
+		in    string
+		want  string
+		valid bool
+	}{
+		{"no refs", "no refs", true},
+		{"{$a}/{$b-c}", "1/2", true},
+		{"{$a}{$a}", "11", true},
+		{"{$", "{$", true},
+		{"{$not valid}", "{$not valid}", true},
+		{"{$missing}", "", false},
+	}
+	for _, tt := range tests {
+		got, err := expandVariables(tt.in, vars)
+		if err != nil && tt.valid {
+			t.Errorf("expand %q: %v", tt.in, err)
+		} else if err == nil && !tt.valid {
+			t.Errorf("expand %q: nil error", tt.in)
+		}
+		if got != tt.want {
+			t.Errorf("expand %q = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeDefines(t *testing.T) {
+	p, err := Decode(strings.NewReader(testMedia))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(testMedia, "\n") {
+		if strings.HasPrefix(line, tagDefine) && !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("encoded playlist missing %s", line)
+		}
+	}
+}
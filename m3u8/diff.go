@@ -0,0 +1,218 @@
+package m3u8
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Change describes a difference between two loads of a media playlist.
+type Change struct {
+	Kind ChangeKind
+	// Sequence is the media sequence number of the first
+	// segment affected by the change, if any.
+	Sequence int
+	// Count is the number of segments affected.
+	Count int
+	// Old and New describe the value before and after the change.
+	Old, New string
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case SegmentsAdded, SegmentsRemoved, SequenceJump:
+		return fmt.Sprintf("%s: %d segments from sequence %d", c.Kind, c.Count, c.Sequence)
+	case SegmentChanged:
+		return fmt.Sprintf("%s: sequence %d: %s -> %s", c.Kind, c.Sequence, c.Old, c.New)
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Kind, c.Old, c.New)
+}
+
+type ChangeKind uint8
+
+const (
+	// Segments were appended to the playlist.
+	SegmentsAdded ChangeKind = iota
+	// Segments were removed from the start of the playlist
+	// as the live window moved forward.
+	SegmentsRemoved
+	// The media sequence number advanced beyond the end of the
+	// previous playlist, so segments were never listed to clients.
+	SequenceJump
+	// The media sequence number decreased.
+	SequenceRegression
+	// A segment with the same media sequence number in both
+	// playlists has a different URI, duration or other attribute.
+	SegmentChanged
+	// The discontinuity sequence number does not account for the
+	// discontinuities in the segments removed from the playlist.
+	DiscontinuityMismatch
+	TargetDurationChanged
+	// The playlist type changed.
+	TypeChanged
+	// The EXT-X-ENDLIST tag was added.
+	Ended
+	// The EXT-X-ENDLIST tag was removed.
+	Unended
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case SegmentsAdded:
+		return "segments added"
+	case SegmentsRemoved:
+		return "segments removed"
+	case SequenceJump:
+		return "media sequence jump"
+	case SequenceRegression:
+		return "media sequence regression"
+	case SegmentChanged:
+		return "segment changed"
+	case DiscontinuityMismatch:
+		return "discontinuity sequence mismatch"
+	case TargetDurationChanged:
+		return "target duration changed"
+	case TypeChanged:
+		return "playlist type changed"
+	case Ended:
+		return "ended"
+	case Unended:
+		return "end list removed"
+	}
+	return "unknown"
+}
+
+// Anomaly reports whether changes of kind k violate the rules of
+// RFC 8216 section 6.2.1 for updating live playlists, as opposed to
+// the expected changes of a playlist as it is updated.
+func (k ChangeKind) Anomaly() bool {
+	switch k {
+	case SegmentsAdded, SegmentsRemoved, Ended:
+		return false
+	}
+	return true
+}
+
+// Diff returns the changes from the media playlist a to b,
+// where b is a later reload of a. Segments are matched by
+// their media sequence number.
+func Diff(a, b *Playlist) []Change {
+	var changes []Change
+	if a.TargetDuration != b.TargetDuration {
+		changes = append(changes, Change{
+			Kind: TargetDurationChanged,
+			Old:  a.TargetDuration.String(),
+			New:  b.TargetDuration.String(),
+		})
+	}
+	if a.Type != b.Type {
+		changes = append(changes, Change{Kind: TypeChanged, Old: a.Type.String(), New: b.Type.String()})
+	}
+	if b.Sequence < a.Sequence {
+		changes = append(changes, Change{
+			Kind: SequenceRegression,
+			Old:  fmt.Sprint(a.Sequence),
+			New:  fmt.Sprint(b.Sequence),
+		})
+	}
+
+	aend := a.Sequence + len(a.Segments)
+	bend := b.Sequence + len(b.Segments)
+	if removed := b.Sequence - a.Sequence; removed > 0 {
+		if removed > len(a.Segments) {
+			removed = len(a.Segments)
+		}
+		changes = append(changes, Change{Kind: SegmentsRemoved, Sequence: a.Sequence, Count: removed})
+	}
+	// We can't know how many discontinuities were in segments
+	// we never saw, so only check when there's no jump.
+	if b.Sequence >= a.Sequence && b.Sequence <= aend && len(b.Segments) > 0 {
+		want, ok := a.DiscontinuitySequenceAt(b.Sequence)
+		if !ok && len(a.Segments) > 0 {
+			// b starts immediately after the end of a.
+			want, _ = a.DiscontinuitySequenceAt(aend - 1)
+		} else if ok && b.Segments[0].Discontinuity {
+			// b's discontinuity sequence number precedes
+			// the discontinuity on its first segment.
+			want--
+		}
+		if (ok || len(a.Segments) > 0) && b.DiscontinuitySequence != want {
+			changes = append(changes, Change{
+				Kind: DiscontinuityMismatch,
+				Old:  fmt.Sprint(want),
+				New:  fmt.Sprint(b.DiscontinuitySequence),
+			})
+		}
+	}
+	if b.Sequence > aend {
+		changes = append(changes, Change{Kind: SequenceJump, Sequence: aend, Count: b.Sequence - aend})
+	}
+
+	// compare segments present in both playlists.
+	for seq := b.Sequence; seq < aend && seq < bend; seq++ {
+		if seq < a.Sequence {
+			continue
+		}
+		sa := &a.Segments[seq-a.Sequence]
+		sb := &b.Segments[seq-b.Sequence]
+		if was, now, ok := segmentsEqual(sa, sb); !ok {
+			changes = append(changes, Change{Kind: SegmentChanged, Sequence: seq, Old: was, New: now})
+		}
+	}
+
+	if bend > aend {
+		start := aend
+		if b.Sequence > start {
+			start = b.Sequence
+		}
+		changes = append(changes, Change{Kind: SegmentsAdded, Sequence: start, Count: bend - start})
+	}
+	if !a.End && b.End {
+		changes = append(changes, Change{Kind: Ended})
+	} else if a.End && !b.End {
+		changes = append(changes, Change{Kind: Unended})
+	}
+	return changes
+}
+
+// segmentsEqual reports whether a and b are the same segment.
+// If not, it returns descriptions of the first differing attribute.
+// Tags such as EXT-X-MAP and EXT-X-PROGRAM-DATE-TIME are often only
+// written before the first segment of a playlist, so they are only
+// compared when present in both.
+func segmentsEqual(a, b *Segment) (was, now string, ok bool) {
+	switch {
+	case a.URI != b.URI:
+		return a.URI, b.URI, false
+	case a.Duration != b.Duration:
+		return a.Duration.String(), b.Duration.String(), false
+	case a.Range != b.Range:
+		return a.Range.String(), b.Range.String(), false
+	case a.Discontinuity != b.Discontinuity:
+		return fmt.Sprintf("discontinuity %t", a.Discontinuity), fmt.Sprintf("discontinuity %t", b.Discontinuity), false
+	case !a.DateTime.IsZero() && !b.DateTime.IsZero() && !a.DateTime.Equal(b.DateTime):
+		return a.DateTime.Format(rfc3339Milli), b.DateTime.Format(rfc3339Milli), false
+	case !keysEqual(a.Key, b.Key):
+		return fmt.Sprint(a.Key), fmt.Sprint(b.Key), false
+	case a.Map != nil && b.Map != nil && !reflect.DeepEqual(a.Map, b.Map):
+		return fmt.Sprint(a.Map), fmt.Sprint(b.Map), false
+	}
+	return "", "", true
+}
+
+// DiscontinuitySequenceAt returns the discontinuity sequence number
+// of the segment with media sequence number seq, as used to
+// synchronise renditions. The boolean is false if p does not
+// contain the segment.
+func (p *Playlist) DiscontinuitySequenceAt(seq int) (int, bool) {
+	i := seq - p.Sequence
+	if i < 0 || i >= len(p.Segments) {
+		return 0, false
+	}
+	n := p.DiscontinuitySequence
+	for _, seg := range p.Segments[:i+1] {
+		if seg.Discontinuity {
+			n++
+		}
+	}
+	return n, true
+}
@@ -0,0 +1,150 @@
+package m3u8
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testReload0 = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:100
+#EXT-X-DISCONTINUITY-SEQUENCE:2
+#EXTINF:6.000
+100.ts
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000
+101.ts
+#EXTINF:6.000
+102.ts
+`
+
+func TestDiff(t *testing.T) {
+	a, err := Decode(strings.NewReader(testReload0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases = []struct {
+		name   string
+		reload string
+		want   []Change
+	}{
+		{
+			"unchanged",
+			testReload0,
+			nil,
+		},
+		{
+			"sliding window",
+			`#EXTM3U
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:102
+#EXT-X-DISCONTINUITY-SEQUENCE:3
+#EXTINF:6.000
+102.ts
+#EXTINF:6.000
+103.ts
+#EXT-X-ENDLIST
+`,
+			[]Change{
+				{Kind: SegmentsRemoved, Sequence: 100, Count: 2},
+				{Kind: SegmentsAdded, Sequence: 103, Count: 1},
+				{Kind: Ended},
+			},
+		},
+		{
+			"discontinuity first",
+			`#EXTM3U
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:101
+#EXT-X-DISCONTINUITY-SEQUENCE:2
+#EXT-X-DISCONTINUITY
+#EXTINF:6.000
+101.ts
+#EXTINF:6.000
+102.ts
+`,
+			[]Change{{Kind: SegmentsRemoved, Sequence: 100, Count: 1}},
+		},
+		{
+			"anomalies",
+			`#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:101
+#EXT-X-DISCONTINUITY-SEQUENCE:2
+#EXTINF:6.000
+101.ts
+#EXTINF:6.000
+102b.ts
+`,
+			[]Change{
+				{Kind: TargetDurationChanged, Old: "6s", New: "10s"},
+				{Kind: SegmentsRemoved, Sequence: 100, Count: 1},
+				{Kind: DiscontinuityMismatch, Old: "3", New: "2"},
+				{Kind: SegmentChanged, Sequence: 101, Old: "discontinuity true", New: "discontinuity false"},
+				{Kind: SegmentChanged, Sequence: 102, Old: "102.ts", New: "102b.ts"},
+			},
+		},
+		{
+			"jump",
+			`#EXTM3U
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:110
+#EXT-X-DISCONTINUITY-SEQUENCE:9
+#EXTINF:6.000
+110.ts
+`,
+			[]Change{
+				{Kind: SegmentsRemoved, Sequence: 100, Count: 3},
+				{Kind: SequenceJump, Sequence: 103, Count: 7},
+				{Kind: SegmentsAdded, Sequence: 110, Count: 1},
+			},
+		},
+		{
+			"regression",
+			`#EXTM3U
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:6.000
+000.ts
+`,
+			[]Change{{Kind: SequenceRegression, Old: "100", New: "0"}},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Decode(strings.NewReader(tt.reload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			changes := Diff(a, b)
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("Diff() = %v, want %v", changes, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscontinuitySequenceAt(t *testing.T) {
+	p, err := Decode(strings.NewReader(testReload0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seq, want := range map[int]int{100: 2, 101: 3, 102: 3} {
+		if n, ok := p.DiscontinuitySequenceAt(seq); !ok || n != want {
+			t.Errorf("DiscontinuitySequenceAt(%d) = %d, %t, want %d", seq, n, ok, want)
+		}
+	}
+	if _, ok := p.DiscontinuitySequenceAt(103); ok {
+		t.Errorf("found discontinuity sequence of segment not in playlist")
+	}
+
+	// a discontinuity on the first segment counts too.
+	p.Segments[0].Discontinuity = true
+	for seq, want := range map[int]int{100: 3, 101: 4, 102: 4} {
+		if n, ok := p.DiscontinuitySequenceAt(seq); !ok || n != want {
+			t.Errorf("first segment discontinuous: DiscontinuitySequenceAt(%d) = %d, %t, want %d", seq, n, ok, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package m3u8
+
+import "fmt"
+
+// ParseError describes a problem decoding a playlist
+// at a particular position in the input.
+type ParseError struct {
+	// Line and Column, counting from 1, of the problem.
+	// Columns count bytes rather than characters.
+	Line   int
+	Column int
+	// Tag is the name of the tag being decoded, if any,
+	// such as "#EXT-X-STREAM-INF".
+	Tag string
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Tag == "" {
+		return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %d: %s: %v", e.Line, e.Column, e.Tag, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// ErrorList is a list of errors returned when decoding in Strict mode.
+type ErrorList []*ParseError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Mode controls how a Decoder handles malformed input.
+type Mode uint8
+
+const (
+	// FailFast stops decoding at the first error. This is the default.
+	FailFast Mode = iota
+	// Strict continues decoding after an error by skipping the
+	// malformed line, so that every error is reported together
+	// in an ErrorList.
+	Strict
+	// Lenient skips malformed lines as in Strict mode, but reports
+	// the problems as warnings rather than errors so that a
+	// best-effort playlist may be used.
+	Lenient
+)
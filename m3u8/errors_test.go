@@ -0,0 +1,139 @@
+package m3u8
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testMalformed = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:abc
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000
+001.ts
+#EXT-X-BYTERANGE:xyz
+#EXTINF:10.000
+002.ts
+#EXT-X-STREAM-INF:BANDWIDTH="fast"
+#EXTINF:10.000
+003.ts
+#EXT-X-ENDLIST
+`
+
+func TestParseErrorPosition(t *testing.T) {
+	_, err := Decode(strings.NewReader(testMalformed))
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("error %v is not a ParseError", err)
+	}
+	want := ParseError{Line: 3, Column: 23, Tag: tagTargetDuration}
+	if perr.Line != want.Line || perr.Column != want.Column || perr.Tag != want.Tag {
+		t.Errorf("error at line %d, column %d, tag %s; want line %d, column %d, tag %s", perr.Line, perr.Column, perr.Tag, want.Line, want.Column, want.Tag)
+	}
+	t.Log(err)
+
+	_, err = Decode(strings.NewReader("#EXT-X-VERSION:3\n"))
+	if !errors.As(err, &perr) || perr.Line != 1 {
+		t.Errorf("missing header: want error on line 1, got %v", err)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	_, err := DecodeStrict(strings.NewReader(testMalformed))
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("error %v is not an ErrorList", err)
+	}
+	var lines []int
+	for _, e := range list {
+		lines = append(lines, e.Line)
+	}
+	want := []int{3, 7, 10}
+	if len(lines) != len(want) {
+		t.Fatalf("errors on lines %v, want %v", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("errors on lines %v, want %v", lines, want)
+			break
+		}
+	}
+}
+
+func TestDecodeLenient(t *testing.T) {
+	p, warnings, err := DecodeLenient(strings.NewReader(testMalformed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 3 {
+		t.Errorf("want 3 warnings, got %d: %v", len(warnings), warnings)
+	}
+	if len(p.Segments) != 3 {
+		t.Fatalf("want 3 segments, got %d", len(p.Segments))
+	}
+	for i, seg := range p.Segments {
+		if seg.Duration != 10*time.Second {
+			t.Errorf("segment %d: duration %s, want %s", i, seg.Duration, 10*time.Second)
+		}
+	}
+	if p.Segments[1].URI != "002.ts" || p.Segments[1].Range != (ByteRange{}) {
+		t.Errorf("malformed byte range not skipped: %+v", p.Segments[1])
+	}
+	if !p.End || p.Version != 3 {
+		t.Errorf("playlist tags after malformed lines not decoded")
+	}
+
+	_, _, err = DecodeLenient(strings.NewReader("not a playlist\n"))
+	if err == nil {
+		t.Errorf("nil error decoding input without a playlist header")
+	}
+}
+
+// Errors from the lexer are reported as is, rather than as a
+// failure to parse the illegal item.
+func TestLexError(t *testing.T) {
+	for _, tag := range []string{"#EXT-X-BYTERANGE:^", "#EXT-X-BITRATE:!"} {
+		_, err := Decode(strings.NewReader("#EXTM3U\n" + tag + "\n#EXTINF:10.000\n001.ts\n"))
+		if err == nil {
+			t.Errorf("%s: nil error", tag)
+		} else if !strings.Contains(err.Error(), "illegal character") || strings.Contains(err.Error(), "strconv") {
+			t.Errorf("%s: want lexer error, got %v", tag, err)
+		}
+	}
+}
+
+func TestDecodeLenientDuration(t *testing.T) {
+	var tests = []struct {
+		name     string
+		input    string
+		segments int
+	}{
+		{
+			"target duration",
+			"#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:abc\n001.ts\n#EXTINF:5.000\n002.ts\n",
+			2,
+		},
+		{
+			"no target duration",
+			"#EXTM3U\n#EXTINF:-1\n001.ts\n#EXTINF:5.000\n002.ts\n",
+			1,
+		},
+	}
+	for _, tt := range tests {
+		p, warnings, err := DecodeLenient(strings.NewReader(tt.input))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(warnings) == 0 {
+			t.Errorf("%s: no warnings", tt.name)
+		}
+		if len(p.Segments) != tt.segments {
+			t.Errorf("%s: want %d segments, got %d", tt.name, tt.segments, len(p.Segments))
+		}
+		if err := Encode(&strings.Builder{}, p); err != nil {
+			t.Errorf("%s: encode leniently decoded playlist: %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package m3u8
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/untangledco/streaming/mpegts"
+)
+
+// IFramePlaylist returns an I-frame playlist of the MPEG-TS segments
+// in the media playlist p, as specified in RFC 8216 section 4.3.3.6.
+// The contents of each segment are read from the reader returned by
+// calling open with the segment's URI. Readers are closed once read.
+//
+// Each segment of the returned playlist is a sub-range of a segment
+// in p holding a single keyframe. Its duration is the time until the
+// next keyframe, or until the end of p for the final keyframe.
+// Keyframes are identified by the random access indicator, or
+// failing that by H.264 IDR and sequence parameter set NAL units.
+// Any packets preceding the first PES packet of each segment,
+// such as program tables, are referenced by an EXT-X-MAP tag.
+func IFramePlaylist(p *Playlist, open func(uri string) (io.ReadCloser, error)) (*Playlist, error) {
+	var frames []iframe
+	var end uint64 // PTS at the end of the last segment read
+	var maps []*Map
+	// set from a discontinuity until the next keyframe.
+	var discontinuity bool
+	for i := range p.Segments {
+		seg := &p.Segments[i]
+		if seg.Discontinuity {
+			discontinuity = true
+			if n := len(frames); n > 0 {
+				// timestamps restart, so the last keyframe ends
+				// with the segments preceding the discontinuity.
+				e := end
+				frames[n-1].end = &e
+			}
+		}
+		rc, err := open(seg.URI)
+		if err != nil {
+			return nil, fmt.Errorf("open segment %s: %w", seg.URI, err)
+		}
+		sf, err := scanIFrames(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("scan segment %s: %w", seg.URI, err)
+		}
+		var m *Map
+		if sf.header > 0 {
+			m = &Map{URI: seg.URI, ByteRange: ByteRange{sf.header, 0}}
+		}
+		for j := range sf.frames {
+			sf.frames[j].segment = seg
+			if j == 0 {
+				maps = append(maps, m)
+			} else {
+				maps = append(maps, nil)
+			}
+		}
+		if len(sf.frames) > 0 {
+			sf.frames[0].discontinuity = discontinuity
+			discontinuity = false
+		}
+		frames = append(frames, sf.frames...)
+		if sf.start != nil {
+			end = *sf.start + uint64(seg.Duration*ticksPerSecond/time.Second)
+		}
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no keyframes found")
+	}
+
+	iframes := &Playlist{
+		// EXT-X-MAP in an I-frame playlist requires version 5.
+		Version:     5,
+		Sequence:    p.Sequence,
+		Type:        p.Type,
+		End:         p.End,
+		IFramesOnly: true,
+		Segments:    make([]Segment, len(frames)),
+	}
+	for i, f := range frames {
+		next := end
+		if f.end != nil {
+			next = *f.end
+		} else if i < len(frames)-1 {
+			next = frames[i+1].pts
+		}
+		dur := ticksToDuration(ptsSub(next, f.pts))
+		if dur <= 0 {
+			return nil, fmt.Errorf("keyframe at offset %d of %s: non-positive duration %s", f.offset, f.segment.URI, dur)
+		}
+		iframes.Segments[i] = Segment{
+			URI:           f.segment.URI,
+			Duration:      dur,
+			Range:         ByteRange{f.length, f.offset},
+			Key:           f.segment.Key,
+			Discontinuity: f.discontinuity,
+			Map:           maps[i],
+		}
+		if dur > iframes.TargetDuration {
+			iframes.TargetDuration = dur
+		}
+	}
+	iframes.TargetDuration = iframes.TargetDuration.Round(time.Second)
+	if iframes.TargetDuration < time.Second {
+		iframes.TargetDuration = time.Second
+	}
+	return iframes, nil
+}
+
+// NewIFrameInfo returns the EXT-X-I-FRAME-STREAM-INF entry of a master
+// playlist for the I-frame playlist p, located at uri, generated from
+// the segments of the variant v. See IFramePlaylist.
+// Only video codecs are retained from v; the bandwidths are calculated
+// from the byte ranges and durations of the segments in p.
+func NewIFrameInfo(v *Variant, p *Playlist, uri string) IFrameInfo {
+	info := IFrameInfo{
+		URI:        uri,
+		Resolution: v.Resolution,
+		HDCP:       v.HDCP,
+		Video:      v.Video,
+		VideoRange: v.VideoRange,
+		PathwayID:  v.PathwayID,
+	}
+	for _, codec := range v.Codecs {
+		if isVideoCodec(codec) {
+			info.Codecs = append(info.Codecs, codec)
+		}
+	}
+
+	var total int
+	var dur time.Duration
+	for _, seg := range p.Segments {
+		bits := seg.Range[0] * 8
+		if bw := int(float64(bits) / seg.Duration.Seconds()); bw > info.Bandwidth {
+			info.Bandwidth = bw
+		}
+		total += bits
+		dur += seg.Duration
+	}
+	if dur > 0 {
+		info.AverageBandwidth = int(float64(total) / dur.Seconds())
+	}
+	return info
+}
+
+// isVideoCodec reports whether the RFC 6381 codec identifier codec
+// names a video codec.
+func isVideoCodec(codec string) bool {
+	for _, prefix := range []string{"avc1", "avc3", "hvc1", "hev1", "dvh1", "dvhe", "dva1", "dvav", "av01", "vp09"} {
+		if strings.HasPrefix(codec, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+const ticksPerSecond = 90000
+
+// iframe is a keyframe located in a MPEG-TS segment.
+type iframe struct {
+	segment *Segment
+	// offset and length in bytes of the packets carrying the frame.
+	offset int
+	length int
+	// presentation timestamp of the frame.
+	pts uint64
+	// end is the timestamp at which the frame's duration ends
+	// if the timeline restarts before the next keyframe.
+	end *uint64
+	// discontinuity is set on the first keyframe
+	// following a discontinuity.
+	discontinuity bool
+}
+
+type segmentFrames struct {
+	frames []iframe
+	// length in bytes of the packets preceding the first PES packet.
+	header int
+	// earliest video presentation timestamp, if any.
+	start *uint64
+}
+
+// scanIFrames reads a MPEG-TS stream from r and returns the
+// keyframes of the first video elementary stream.
+func scanIFrames(r io.Reader) (*segmentFrames, error) {
+	var sf segmentFrames
+	sc := mpegts.NewScanner(r)
+	video := mpegts.PacketNull
+	seenPES := false
+	var offset int
+	// byte offset just past the last packet of the video stream.
+	var videoEnd int
+	var current *iframe
+	for ; sc.Scan(); offset += mpegts.PacketSize {
+		packet := sc.Packet()
+		if packet.PES != nil && !seenPES {
+			seenPES = true
+			sf.header = offset
+		}
+		if video == mpegts.PacketNull && packet.PES != nil && isVideoStream(packet.PES.ID) {
+			video = packet.PID
+		}
+		if packet.PID != video {
+			continue
+		}
+
+		if packet.PayloadStart {
+			if current != nil {
+				current.length = videoEnd - current.offset
+				sf.frames = append(sf.frames, *current)
+				current = nil
+			}
+			var pts *uint64
+			if packet.PES != nil && packet.PES.Header != nil && packet.PES.Header.Presentation != nil {
+				pts = &packet.PES.Header.Presentation.Ticks
+				// Frames are not in presentation order,
+				// so find the earliest, allowing for wrap around.
+				if sf.start == nil || earlier(*pts, *sf.start) {
+					t := *pts
+					sf.start = &t
+				}
+			}
+			if pts != nil && isKeyframe(packet) {
+				current = &iframe{offset: offset, pts: *pts}
+			}
+		}
+		videoEnd = offset + mpegts.PacketSize
+	}
+	if sc.Err() != nil {
+		return nil, sc.Err()
+	}
+	if current != nil {
+		current.length = videoEnd - current.offset
+		sf.frames = append(sf.frames, *current)
+	}
+	return &sf, nil
+}
+
+// isVideoStream reports whether the PES stream id identifies
+// a MPEG video stream.
+func isVideoStream(id byte) bool {
+	return id&0xf0 == 0xe0
+}
+
+func isKeyframe(p *mpegts.Packet) bool {
+	if p.Adaptation != nil && p.Adaptation.RandomAccess {
+		return true
+	}
+	if p.PES == nil {
+		return false
+	}
+	data := p.PES.Data
+	for _, u := range nalUnits(data) {
+		switch data[u[0]] & 0x1f {
+		case 5, 7: // IDR slice, sequence parameter set
+			return true
+		}
+	}
+	return false
+}
+
+// earlier reports whether the timestamp a is before b.
+func earlier(a, b uint64) bool {
+	d := ptsSub(b, a)
+	return d > 0 && d < 1<<32
+}
+
+// ptsSub returns the number of ticks from b to a,
+// accounting for the 33-bit timestamp wrapping around.
+func ptsSub(a, b uint64) uint64 {
+	const wrap = 1 << 33
+	return (a + wrap - b) % wrap
+}
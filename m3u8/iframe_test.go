@@ -0,0 +1,198 @@
+package m3u8
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIFramePlaylist(t *testing.T) {
+	const name = "../mpegts/testdata/193039199_mp4_h264_aac_hq_7.ts"
+	media := &Playlist{
+		End:      true,
+		Segments: []Segment{{URI: "hq_7.ts", Duration: 5 * time.Second}},
+	}
+	open := func(uri string) (io.ReadCloser, error) {
+		if uri != "hq_7.ts" {
+			t.Fatalf("opened unexpected segment %s", uri)
+		}
+		return os.Open(name)
+	}
+	p, err := IFramePlaylist(media, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Segment{
+		{
+			URI:      "hq_7.ts",
+			Duration: 4450 * time.Millisecond,
+			Range:    ByteRange{1128, 564},
+			Map:      &Map{URI: "hq_7.ts", ByteRange: ByteRange{564, 0}},
+		},
+		{URI: "hq_7.ts", Duration: 550 * time.Millisecond, Range: ByteRange{5076, 180292}},
+	}
+	if !reflect.DeepEqual(p.Segments, want) {
+		t.Errorf("IFramePlaylist() segments = %+v, want %+v", p.Segments, want)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.String()
+	again, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("decode encoded playlist: %v", err)
+	}
+	if !again.IFramesOnly {
+		t.Errorf("I-frames only not preserved when encoding")
+	}
+	for i := range again.Segments {
+		if again.Segments[i].Range != want[i].Range {
+			t.Errorf("segment %d: range %s not preserved when encoding", i, want[i].Range)
+			t.Log(encoded)
+		}
+	}
+
+	v := &Variant{
+		URI:        "hq_7.m3u8",
+		Bandwidth:  2149280,
+		Codecs:     []string{"mp4a.40.2", "avc1.64001f"},
+		Resolution: [2]int{1280, 720},
+	}
+	info := NewIFrameInfo(v, p, "hq_7_iframe.m3u8")
+	wantInfo := IFrameInfo{
+		URI:              "hq_7_iframe.m3u8",
+		Bandwidth:        73832,
+		AverageBandwidth: 9926,
+		Codecs:           []string{"avc1.64001f"},
+		Resolution:       [2]int{1280, 720},
+	}
+	if !reflect.DeepEqual(info, wantInfo) {
+		t.Errorf("NewIFrameInfo() = %+v, want %+v", info, wantInfo)
+	}
+}
+
+// Discontinuities are kept on segments without packets preceding
+// the first keyframe, and so without a map.
+func TestIFrameDiscontinuity(t *testing.T) {
+	b, err := os.ReadFile("../mpegts/testdata/193039199_mp4_h264_aac_hq_7.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// drop the program tables.
+	b = b[564:]
+	media := &Playlist{
+		End:      true,
+		Segments: []Segment{{URI: "hq_7.ts", Duration: 5 * time.Second, Discontinuity: true}},
+	}
+	open := func(uri string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	p, err := IFramePlaylist(media, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Segments) != 2 {
+		t.Fatalf("want 2 segments, got %d", len(p.Segments))
+	}
+	if p.Segments[0].Map != nil {
+		t.Errorf("want no map, got %+v", p.Segments[0].Map)
+	}
+	if !p.Segments[0].Discontinuity {
+		t.Errorf("discontinuity dropped from first keyframe")
+	}
+	if p.Segments[1].Discontinuity {
+		t.Errorf("discontinuity repeated on second keyframe")
+	}
+}
+
+// Timestamps restart after a discontinuity, so must not be
+// subtracted across one.
+func TestIFrameTimeline(t *testing.T) {
+	const name = "../mpegts/testdata/193039199_mp4_h264_aac_hq_7.ts"
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	media := &Playlist{
+		End: true,
+		Segments: []Segment{
+			{URI: "a.ts", Duration: 5 * time.Second},
+			// no keyframes, only program tables.
+			{URI: "tables.ts", Duration: time.Second, Discontinuity: true},
+			// the same timestamps as a.ts.
+			{URI: "b.ts", Duration: 5 * time.Second},
+		},
+	}
+	open := func(uri string) (io.ReadCloser, error) {
+		if uri == "tables.ts" {
+			return io.NopCloser(bytes.NewReader(b[:564])), nil
+		}
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	p, err := IFramePlaylist(media, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Segments) != 4 {
+		t.Fatalf("want 4 segments, got %d", len(p.Segments))
+	}
+	for i, want := range []time.Duration{4450 * time.Millisecond, 550 * time.Millisecond, 4450 * time.Millisecond, 550 * time.Millisecond} {
+		if p.Segments[i].Duration != want {
+			t.Errorf("segment %d: duration %s, want %s", i, p.Segments[i].Duration, want)
+		}
+	}
+	if p.TargetDuration != 4*time.Second {
+		t.Errorf("target duration %s, want 4s", p.TargetDuration)
+	}
+	for i, seg := range p.Segments {
+		if want := i == 2; seg.Discontinuity != want {
+			t.Errorf("segment %d: discontinuity %t, want %t", i, seg.Discontinuity, want)
+		}
+	}
+}
+
+func TestDecodeIFrameInfo(t *testing.T) {
+	f, err := os.Open("testdata/master_end_list.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []IFrameInfo{
+		{URI: "0_hd_hls/0_hd_600_IFRAME.m3u8", Bandwidth: 25000},
+		{URI: "0_hd_hls/0_hd_1000_IFRAME.m3u8", Bandwidth: 41666},
+		{URI: "0_hd_hls/0_hd_2000_IFRAME.m3u8", Bandwidth: 83333},
+	}
+	if !reflect.DeepEqual(p.IFrames, want) {
+		t.Errorf("decoded i-frame variants = %+v, want %+v", p.IFrames, want)
+	}
+	if len(p.Tags) > 0 {
+		t.Errorf("i-frame variants decoded as custom tags: %v", p.Tags)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=25000,URI="0_hd_hls/0_hd_600_IFRAME.m3u8"`) {
+		t.Errorf("i-frame variant not encoded")
+		t.Log(buf.String())
+	}
+
+	bad := IFrameInfo{URI: "iframe.m3u8", Bandwidth: 1000, Audio: "aac"}
+	if _, err := writeIFrameInfo(io.Discard, &bad); err == nil {
+		t.Errorf("nil error writing i-frame variant with audio")
+	}
+	if _, err := Decode(strings.NewReader("#EXTM3U\n#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=1000\n")); err == nil {
+		t.Errorf("nil error decoding i-frame variant without URI")
+	}
+}
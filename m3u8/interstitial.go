@@ -0,0 +1,263 @@
+package m3u8
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ClassInterstitial is the class of date ranges which schedule
+// interstitial content, such as advertisements, as specified in
+// HLS Interstitials (draft-pantos-hls-rfc8216bis, appendix D).
+const ClassInterstitial = "com.apple.hls.interstitial"
+
+// Interstitial holds the attributes of a DateRange of class
+// ClassInterstitial. Players pause the primary content at the start of
+// the date range and play the interstitial assets instead, rather than
+// the assets being spliced in as segments of the primary playlist.
+type Interstitial struct {
+	// AssetURI is the URI of a playlist of a single interstitial asset.
+	// Exactly one of AssetURI or AssetList must be set.
+	AssetURI string
+	// AssetList is the URI of a JSON list of assets to play.
+	// See DecodeAssetList.
+	AssetList string
+	// ResumeOffset is how far into the primary content playback
+	// resumes after the interstitial. If nil, playback resumes
+	// at the date range's duration for live content, or the start
+	// of the date range for VOD.
+	ResumeOffset *time.Duration
+	// PlayoutLimit limits the duration of interstitial playback.
+	PlayoutLimit time.Duration
+	Snap         Snap
+	Restrict     Restrict
+	Cue          InterstitialCue
+}
+
+// Snap specifies whether the player may move the start or end of
+// an interstitial to the nearest segment boundary.
+type Snap uint8
+
+const (
+	SnapOut Snap = 1 << iota
+	SnapIn
+)
+
+func (s Snap) String() string {
+	return joinFlags(uint8(s), []string{"OUT", "IN"})
+}
+
+// Restrict specifies the ways a viewer may not navigate
+// while an interstitial is playing.
+type Restrict uint8
+
+const (
+	// Seeking forward through the interstitial is not allowed.
+	RestrictSkip Restrict = 1 << iota
+	// Seeking past the interstitial in the primary content
+	// without playing it is not allowed.
+	RestrictJump
+)
+
+func (r Restrict) String() string {
+	return joinFlags(uint8(r), []string{"SKIP", "JUMP"})
+}
+
+// InterstitialCue specifies when to trigger an interstitial,
+// overriding the date range's start date.
+type InterstitialCue uint8
+
+const (
+	// Play before the primary content, like a pre-roll.
+	InterstitialPre InterstitialCue = 1 << iota
+	// Play after the primary content, like a post-roll.
+	InterstitialPost
+	// Only play the interstitial once, even if the viewer seeks back.
+	InterstitialOnce
+)
+
+func (c InterstitialCue) String() string {
+	return joinFlags(uint8(c), []string{"PRE", "POST", "ONCE"})
+}
+
+// joinFlags returns the names of each bit set in flags
+// as a comma separated list.
+func joinFlags(flags uint8, names []string) string {
+	var ss []string
+	for i, name := range names {
+		if flags&(1<<i) > 0 {
+			ss = append(ss, name)
+		}
+	}
+	return strings.Join(ss, ",")
+}
+
+// parseFlags is the inverse of joinFlags.
+func parseFlags(s string, names []string) (uint8, error) {
+	var flags uint8
+Loop:
+	for _, v := range strings.Split(s, ",") {
+		for i, name := range names {
+			if v == name {
+				flags |= 1 << i
+				continue Loop
+			}
+		}
+		return 0, fmt.Errorf("unknown value %q", v)
+	}
+	return flags, nil
+}
+
+// attributes returns the interstitial's attributes in the order
+// in which they are written to an EXT-X-DATERANGE tag.
+func (in *Interstitial) attributes() ([]string, error) {
+	if in.AssetURI == "" && in.AssetList == "" {
+		return nil, fmt.Errorf("one of asset URI or asset list required")
+	} else if in.AssetURI != "" && in.AssetList != "" {
+		return nil, fmt.Errorf("only one of asset URI or asset list may be set")
+	}
+	var attrs []string
+	if in.AssetURI != "" {
+		attrs = append(attrs, fmt.Sprintf("X-ASSET-URI=%q", in.AssetURI))
+	} else {
+		attrs = append(attrs, fmt.Sprintf("X-ASSET-LIST=%q", in.AssetList))
+	}
+	if in.ResumeOffset != nil {
+		if *in.ResumeOffset < 0 {
+			return nil, fmt.Errorf("negative resume offset %s", *in.ResumeOffset)
+		}
+		attrs = append(attrs, "X-RESUME-OFFSET="+formatSeconds(*in.ResumeOffset))
+	}
+	if in.PlayoutLimit < 0 {
+		return nil, fmt.Errorf("negative playout limit %s", in.PlayoutLimit)
+	} else if in.PlayoutLimit > 0 {
+		attrs = append(attrs, "X-PLAYOUT-LIMIT="+formatSeconds(in.PlayoutLimit))
+	}
+	if in.Snap != 0 {
+		attrs = append(attrs, fmt.Sprintf("X-SNAP=%q", in.Snap))
+	}
+	if in.Restrict != 0 {
+		attrs = append(attrs, fmt.Sprintf("X-RESTRICT=%q", in.Restrict))
+	}
+	if in.Cue != 0 {
+		if in.Cue&InterstitialPre > 0 && in.Cue&InterstitialPost > 0 {
+			return nil, fmt.Errorf("cue both %s and %s", InterstitialPre, InterstitialPost)
+		}
+		attrs = append(attrs, fmt.Sprintf("X-CUE=%q", in.Cue))
+	}
+	return attrs, nil
+}
+
+// isInterstitialAttr reports whether name is the name of an attribute
+// held in Interstitial.
+func isInterstitialAttr(name string) bool {
+	switch name {
+	case "X-ASSET-URI", "X-ASSET-LIST", "X-RESUME-OFFSET", "X-PLAYOUT-LIMIT", "X-SNAP", "X-RESTRICT", "X-CUE":
+		return true
+	}
+	return false
+}
+
+// parseAttribute sets the interstitial attribute name to value.
+func (in *Interstitial) parseAttribute(name, value string) error {
+	value = strings.Trim(value, `"`)
+	switch name {
+	case "X-ASSET-URI":
+		in.AssetURI = value
+	case "X-ASSET-LIST":
+		in.AssetList = value
+	case "X-RESUME-OFFSET":
+		dur, err := parseSegmentDuration(value)
+		if err != nil {
+			return err
+		}
+		in.ResumeOffset = &dur
+	case "X-PLAYOUT-LIMIT":
+		dur, err := parseSegmentDuration(value)
+		if err != nil {
+			return err
+		}
+		in.PlayoutLimit = dur
+	case "X-SNAP":
+		flags, err := parseFlags(value, []string{"OUT", "IN"})
+		if err != nil {
+			return err
+		}
+		in.Snap = Snap(flags)
+	case "X-RESTRICT":
+		flags, err := parseFlags(value, []string{"SKIP", "JUMP"})
+		if err != nil {
+			return err
+		}
+		in.Restrict = Restrict(flags)
+	case "X-CUE":
+		flags, err := parseFlags(value, []string{"PRE", "POST", "ONCE"})
+		if err != nil {
+			return err
+		}
+		in.Cue = InterstitialCue(flags)
+	default:
+		return fmt.Errorf("unknown attribute")
+	}
+	return nil
+}
+
+// AssetList represents the JSON document referenced by
+// Interstitial.AssetList.
+type AssetList struct {
+	Assets []Asset `json:"ASSETS"`
+}
+
+// Asset is an interstitial asset in an AssetList.
+type Asset struct {
+	// URI of the asset's playlist.
+	URI      string
+	Duration time.Duration
+}
+
+type jsonAsset struct {
+	URI      string  `json:"URI"`
+	Duration float64 `json:"DURATION"`
+}
+
+func (a Asset) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonAsset{a.URI, a.Duration.Seconds()})
+}
+
+func (a *Asset) UnmarshalJSON(b []byte) error {
+	var ja jsonAsset
+	if err := json.Unmarshal(b, &ja); err != nil {
+		return err
+	}
+	a.URI = ja.URI
+	a.Duration = time.Duration(ja.Duration * float64(time.Second))
+	return nil
+}
+
+// DecodeAssetList reads an asset list from r.
+func DecodeAssetList(r io.Reader) (*AssetList, error) {
+	var list AssetList
+	if err := json.NewDecoder(r).Decode(&list); err != nil {
+		return nil, err
+	}
+	for i, a := range list.Assets {
+		if a.URI == "" {
+			return nil, fmt.Errorf("asset %d: empty URI", i)
+		}
+		if a.Duration < 0 {
+			return nil, fmt.Errorf("asset %d: negative duration %s", i, a.Duration)
+		}
+	}
+	return &list, nil
+}
+
+// Duration returns the total duration of all assets in the list.
+func (l *AssetList) Duration() time.Duration {
+	var d time.Duration
+	for _, a := range l.Assets {
+		d += a.Duration
+	}
+	return d
+}
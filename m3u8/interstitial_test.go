@@ -0,0 +1,155 @@
+package m3u8
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testInterstitials = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:6
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00Z
+#EXTINF:6.000
+001.ts
+#EXT-X-DATERANGE:ID="ad1",CLASS="com.apple.hls.interstitial",START-DATE="2024-01-01T00:00:06.000Z",DURATION=30.5,X-ASSET-LIST="https://ads.example.com/list.json?break=1",X-RESUME-OFFSET=0,X-PLAYOUT-LIMIT=60,X-SNAP="OUT,IN",X-RESTRICT="SKIP,JUMP",X-CUE="ONCE",X-COM-EXAMPLE-AD-ID="1234",X-COM-EXAMPLE-BEACON=0xDEADBEEF
+#EXT-X-DATERANGE:ID="ad1-tracking",CLASS="com.example.tracking",START-DATE="2024-01-01T00:00:06.000Z",X-ASSET-URI="tracking.m3u8",X-CUE="ONCE"
+#EXTINF:6.000
+002.ts
+#EXT-X-DATERANGE:ID="preroll",CLASS="com.apple.hls.interstitial",START-DATE="2024-01-01T00:00:00Z",X-ASSET-URI="preroll.m3u8",X-CUE="PRE"
+#EXTINF:6.000
+003.ts
+#EXT-X-DATERANGE:ID="postroll",CLASS="com.apple.hls.interstitial",START-DATE="2024-01-01T00:00:18.000Z",X-ASSET-URI="postroll.m3u8",X-CUE="POST"
+#EXT-X-ENDLIST
+`
+
+func TestDecodeInterstitial(t *testing.T) {
+	p, err := Decode(strings.NewReader(testInterstitials))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero := time.Duration(0)
+	want := &DateRange{
+		ID:       "ad1",
+		Class:    ClassInterstitial,
+		Start:    time.Date(2024, 1, 1, 0, 0, 6, 0, time.UTC),
+		Duration: 30500 * time.Millisecond,
+		Custom: map[string]any{
+			"X-COM-EXAMPLE-AD-ID":  "1234",
+			"X-COM-EXAMPLE-BEACON": []byte{0xde, 0xad, 0xbe, 0xef},
+		},
+		Interstitial: &Interstitial{
+			AssetList:    "https://ads.example.com/list.json?break=1",
+			ResumeOffset: &zero,
+			PlayoutLimit: time.Minute,
+			Snap:         SnapOut | SnapIn,
+			Restrict:     RestrictSkip | RestrictJump,
+			Cue:          InterstitialOnce,
+		},
+	}
+	ranges := p.Segments[1].DateRanges
+	if len(ranges) != 2 {
+		t.Fatalf("want 2 date ranges on segment 1, got %d", len(ranges))
+	}
+	if !reflect.DeepEqual(&ranges[0], want) {
+		t.Errorf("date range = %+v, want %+v", ranges[0], want)
+		t.Logf("interstitial = %+v", ranges[0].Interstitial)
+	}
+	// Interstitial attributes of other classes are client-defined.
+	tracking := &DateRange{
+		ID:    "ad1-tracking",
+		Class: "com.example.tracking",
+		Start: time.Date(2024, 1, 1, 0, 0, 6, 0, time.UTC),
+		Custom: map[string]any{
+			"X-ASSET-URI": "tracking.m3u8",
+			"X-CUE":       "ONCE",
+		},
+	}
+	if !reflect.DeepEqual(&ranges[1], tracking) {
+		t.Errorf("date range = %+v, want %+v", ranges[1], tracking)
+	}
+	pre := p.Segments[2].DateRanges[0].Interstitial
+	if pre == nil || pre.AssetURI != "preroll.m3u8" || pre.Cue != InterstitialPre {
+		t.Errorf("preroll interstitial = %+v", pre)
+	}
+	if len(p.DateRanges) != 1 || p.DateRanges[0].ID != "postroll" {
+		t.Errorf("want postroll date range following last segment, got %+v", p.DateRanges)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.String()
+	again, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("decode encoded playlist: %v", err)
+	}
+	if !reflect.DeepEqual(p, again) {
+		t.Errorf("date ranges not preserved when encoding")
+		t.Log(encoded)
+	}
+}
+
+func TestWriteBadInterstitial(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var cases = []struct {
+		name string
+		dr   DateRange
+	}{
+		{"no asset", DateRange{ID: "1", Class: ClassInterstitial, Start: start, Interstitial: &Interstitial{}}},
+		{
+			"both assets",
+			DateRange{ID: "1", Class: ClassInterstitial, Start: start, Interstitial: &Interstitial{AssetURI: "a.m3u8", AssetList: "a.json"}},
+		},
+		{"wrong class", DateRange{ID: "1", Start: start, Interstitial: &Interstitial{AssetURI: "a.m3u8"}}},
+		{
+			"pre and post",
+			DateRange{ID: "1", Class: ClassInterstitial, Start: start, Interstitial: &Interstitial{AssetURI: "a.m3u8", Cue: InterstitialPre | InterstitialPost}},
+		},
+		{
+			"reserved custom attribute",
+			DateRange{ID: "1", Class: ClassInterstitial, Start: start, Interstitial: &Interstitial{AssetURI: "a.m3u8"}, Custom: map[string]any{"X-CUE": "PRE"}},
+		},
+		{"bad custom value", DateRange{ID: "1", Start: start, Custom: map[string]any{"X-COUNT": 1}}},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := writeDateRange(&bytes.Buffer{}, &tt.dr); err == nil {
+				t.Errorf("nil error writing invalid date range")
+			}
+		})
+	}
+}
+
+func TestDecodeAssetList(t *testing.T) {
+	s := `{
+	"ASSETS": [
+		{"URI": "https://ads.example.com/1/index.m3u8", "DURATION": 15.0},
+		{"URI": "https://ads.example.com/2/index.m3u8", "DURATION": 15.5}
+	]
+}`
+	list, err := DecodeAssetList(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &AssetList{
+		Assets: []Asset{
+			{URI: "https://ads.example.com/1/index.m3u8", Duration: 15 * time.Second},
+			{URI: "https://ads.example.com/2/index.m3u8", Duration: 15500 * time.Millisecond},
+		},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("DecodeAssetList() = %+v, want %+v", list, want)
+	}
+	if list.Duration() != 30500*time.Millisecond {
+		t.Errorf("asset list duration = %s, want %s", list.Duration(), 30500*time.Millisecond)
+	}
+
+	if _, err := DecodeAssetList(strings.NewReader(`{"ASSETS": [{"DURATION": 10}]}`)); err == nil {
+		t.Errorf("nil error decoding asset with no URI")
+	}
+}
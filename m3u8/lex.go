@@ -13,6 +13,10 @@ import (
 type item struct {
 	typ itemType
 	val string
+	// line and column, counting from 1, at which the item starts.
+	// Columns count bytes rather than runes.
+	line int
+	col  int
 }
 
 func (it item) String() string {
@@ -65,16 +69,64 @@ func (t itemType) String() string {
 
 const tagStart = "#EXT"
 
+// lexedTags holds the names of tags whose attributes are lexed
+// into individual items. The values of all other tags, such as
+// vendor-specific tags, are emitted as a single string item.
+var lexedTags = map[string]bool{
+	tagHead:                true,
+	tagVersion:             true,
+	tagVariant:             true,
+	tagIFrameVariant:       true,
+	tagRendition:           true,
+	tagPlaylistType:        true,
+	tagTargetDuration:      true,
+	tagMediaSequence:       true,
+	tagEndList:             true,
+	tagIndependentSegments: true,
+	tagIFramesOnly:         true,
+	tagSessionData:         true,
+	tagSessionKey:          true,
+	tagStartPoint:          true,
+	tagDiscontinuitySeq:    true,
+	tagDefine:              true,
+	tagContentSteering:     true,
+	tagSegmentDuration:     true,
+	tagByteRange:           true,
+	tagDiscontinuity:       true,
+	tagKey:                 true,
+	tagMap:                 true,
+	tagDateTime:            true,
+	tagDateRange:           true,
+	tagGap:                 true,
+	tagBitrate:             true,
+	tagPart:                true,
+	tagServerControl:       true,
+	tagPartInf:             true,
+	tagSkip:                true,
+	tagPreloadHint:         true,
+	tagRenditionReport:     true,
+}
+
 // A lexer... TODO
 // The design is described in "Lexical Scanning in Go" by Rob Pike:
 // https://www.youtube.com/watch?v=HxaD_trXwRE
+// Rather than running in its own goroutine, the lexer runs only
+// enough state functions to produce the next item requested by the
+// parser, so that input is read line by line.
 type lexer struct {
 	sc    *bufio.Scanner
 	input string
 	start int
 	pos   int
 	width int
-	items chan item
+	state stateFn
+	// items holds lexed items not yet returned from nextItem,
+	// starting from head.
+	items []item
+	head  int
+	// the line number of input, and the last item returned by nextItem.
+	line int
+	last item
 
 	// if enabled, emitted items are printed to standard error.
 	debug bool
@@ -105,21 +157,55 @@ func (l *lexer) peek() rune {
 
 func (l *lexer) errorf(format string, a ...any) stateFn {
 	err := fmt.Sprintf(format, a...)
-	l.items <- item{itemError, err}
+	l.items = append(l.items, item{typ: itemError, val: err, line: l.line, col: l.pos + 1})
 	return nil
 }
 
-func (l *lexer) run() {
-	for state := lexStart; state != nil; {
-		state = state(l)
+// nextItem returns the next item from the input.
+// Once the input is exhausted or an error item has been returned,
+// nextItem returns items of type itemEOF.
+func (l *lexer) nextItem() item {
+	for l.head == len(l.items) {
+		if l.state == nil {
+			return item{typ: itemEOF}
+		}
+		// all items consumed; reuse the queue.
+		l.items = l.items[:0]
+		l.head = 0
+		l.state = l.state(l)
+	}
+	it := l.items[l.head]
+	l.head++
+	l.last = it
+	return it
+}
+
+// unread pushes the last item returned by nextItem back,
+// so that it is returned again by the next call to nextItem.
+// Only one item may be unread at a time.
+func (l *lexer) unread() {
+	l.head--
+}
+
+// skipLine discards the remainder of the line holding the last item
+// returned by nextItem, so that lexing resumes from the next line.
+// Lexing stops entirely if the input could not be read.
+func (l *lexer) skipLine() {
+	if l.last.typ == itemNewline || l.sc.Err() != nil {
+		return
 	}
-	close(l.items)
+	l.items = l.items[:0]
+	l.head = 0
+	l.state = lexStart
+	// don't skip the next line too.
+	l.last = item{typ: itemNewline, line: l.last.line}
 }
 
 func (l *lexer) emit(t itemType) {
-	l.items <- item{t, l.input[l.start:l.pos]}
+	it := item{typ: t, val: l.input[l.start:l.pos], line: l.line, col: l.start + 1}
+	l.items = append(l.items, it)
 	if l.debug {
-		fmt.Fprintln(os.Stderr, item{t, l.input[l.start:l.pos]})
+		fmt.Fprintln(os.Stderr, it)
 	}
 	l.start = l.pos
 }
@@ -127,13 +213,14 @@ func (l *lexer) emit(t itemType) {
 func newLexer(r io.Reader) *lexer {
 	return &lexer{
 		sc:    bufio.NewScanner(r),
-		items: make(chan item),
+		state: lexStart,
 		debug: false,
 	}
 }
 
 func lexStart(l *lexer) stateFn {
 	for l.sc.Scan() {
+		l.line++
 		if l.sc.Text() == "" {
 			continue // ignore blank lines
 		}
@@ -144,7 +231,7 @@ func lexStart(l *lexer) stateFn {
 		l.pos = 0
 		l.start = 0
 		if strings.HasPrefix(l.input, tagStart) {
-			return lexTag(l)
+			return lexTag
 		} else if strings.HasPrefix(l.input, "#") {
 			continue // ignore comments
 		}
@@ -153,9 +240,10 @@ func lexStart(l *lexer) stateFn {
 		l.pos = len(text)
 		l.emit(itemURL)
 		l.emit(itemNewline)
+		return lexStart
 	}
 	if err := l.sc.Err(); err != nil {
-		panic(err)
+		return l.errorf("read line: %v", err)
 	}
 	return nil
 }
@@ -165,7 +253,7 @@ func lexTag(l *lexer) stateFn {
 	if r != '#' {
 		return l.errorf("missing starting #")
 	}
-	return lexTagName(l)
+	return lexTagName
 }
 
 func lexTagName(l *lexer) stateFn {
@@ -180,17 +268,32 @@ func lexTagName(l *lexer) stateFn {
 			l.emit(itemTag)
 			l.next()
 			l.emit(itemNewline)
-			return lexStart(l)
+			return lexStart
 		case ':':
+			name := l.input[l.start:l.pos]
 			l.emit(itemTag)
 			l.next()
 			l.ignore() // ignore ':' after tag name
-			return lexAttrs(l)
+			if !lexedTags[name] {
+				return lexTagValue
+			}
+			return lexAttrs
 		}
 		return l.errorf("illegal tag character %q", r)
 	}
 }
 
+// lexTagValue emits the remainder of the line as a single string.
+func lexTagValue(l *lexer) stateFn {
+	l.pos = len(l.input) - 1 // up to the newline
+	if l.pos > l.start {
+		l.emit(itemString)
+	}
+	l.next()
+	l.emit(itemNewline)
+	return lexStart
+}
+
 func isTagNameChar(r rune) bool {
 	if r >= 'A' && r <= 'Z' {
 		return true
@@ -216,25 +319,25 @@ func lexAttrs(l *lexer) stateFn {
 			}
 			l.next()
 			l.emit(itemNewline)
-			return lexStart(l)
+			return lexStart
 		case r == '=':
 			l.emit(itemAttrName)
 			l.next()
 			l.emit(itemEquals)
-			return lexAttrValue(l)
+			return lexAttrValue
 		case r == ',':
 			l.next()
 			l.emit(itemComma)
-			return lexAttrs(l)
+			return lexAttrs
 		case r == '.':
-			return lexAttrValue(l)
+			return lexAttrValue
 		case r == '@':
-			return lexAttrValue(l)
+			return lexAttrValue
 		case r == ':':
-			return lexAttrValue(l)
+			return lexAttrValue
 		case r == '"':
 			l.next()
-			return lexQString(l)
+			return lexQString
 		default:
 			return l.errorf("illegal character %q in attribute name", r)
 		}
@@ -244,13 +347,13 @@ func lexAttrs(l *lexer) stateFn {
 func lexAttrValue(l *lexer) stateFn {
 	r := l.next()
 	switch r {
-	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '.', ':':
-		return lexNumber(l)
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '.', ':', '@':
+		return lexNumber
 	case '"':
-		return lexQString(l)
+		return lexQString
 	}
 	if isTagNameChar(r) {
-		return lexRawString(l)
+		return lexRawString
 	}
 	return l.errorf("unquoted string starting with illegal character %q", r)
 }
@@ -262,7 +365,7 @@ Loop:
 		case 'x', '@':
 			// are we lexing a resolution? e.g. 640x480
 			// or a byte range? e.g. 69@3000
-			return lexRawString(l)
+			return lexRawString
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '.':
 			l.next()
 			continue
@@ -272,7 +375,7 @@ Loop:
 			if !unicode.IsDigit(l.peek()) {
 				return l.errorf("expected digit after timestamp character %c, got %c", r, l.peek())
 			}
-			return lexRawString(l)
+			return lexRawString
 		default:
 			l.emit(itemNumber)
 			break Loop
@@ -289,10 +392,10 @@ Loop:
 		if l.peek() == ',' {
 			l.next()
 			l.emit(itemComma)
-			return lexSegmentTitle(l)
+			return lexSegmentTitle
 		}
 	}
-	return lexAttrs(l)
+	return lexAttrs
 }
 
 
@@ -301,7 +404,7 @@ func lexQString(l *lexer) stateFn {
 		r := l.next()
 		if r == '"' {
 			l.emit(itemString)
-			return lexAttrs(l)
+			return lexAttrs
 		} else if r == '\n' {
 			return l.errorf("unterminated quoted string")
 		}
@@ -316,7 +419,7 @@ func lexRawString(l *lexer) stateFn {
 		l.next()
 	}
 	l.emit(itemString)
-	return lexAttrs(l)
+	return lexAttrs
 }
 
 func lexSegmentTitle(l *lexer) stateFn {
@@ -327,5 +430,5 @@ func lexSegmentTitle(l *lexer) stateFn {
 		l.next()
 	}
 	l.emit(itemString)
-	return lexStart(l)
+	return lexStart
 }
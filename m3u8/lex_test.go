@@ -17,8 +17,7 @@ func TestLex(t *testing.T) {
 			}
 			defer f.Close()
 			lexer := newLexer(f)
-			go lexer.run()
-			for it := range lexer.items {
+			for it := lexer.nextItem(); it.typ != itemEOF; it = lexer.nextItem() {
 				t.Log(it)
 				if it.typ == itemError {
 					t.Error(it.val)
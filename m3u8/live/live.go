@@ -0,0 +1,359 @@
+// Package live serves live HLS media playlists, including the
+// low-latency delivery directives of blocking playlist reloads
+// and playlist delta updates.
+//
+// A Playlist holds a sliding window of the most recent segments of a
+// stream. As an encoder or packager produces media, new partial
+// segments and segments are added to the Playlist. Clients requesting
+// the playlist with the _HLS_msn and _HLS_part query parameters are
+// held until the requested segment or partial segment is available.
+// Clients requesting _HLS_skip receive a delta update, where older
+// segments are replaced by an EXT-X-SKIP tag.
+//
+// See section 6.2.5 of draft-pantos-hls-rfc8216bis.
+package live
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/untangledco/streaming/m3u8"
+)
+
+// Playlist is a live media playlist which may be served to clients
+// while being updated. It is safe for concurrent use.
+type Playlist struct {
+	mu     sync.Mutex
+	p      *m3u8.Playlist
+	window int
+	// closed and replaced whenever p changes,
+	// waking any blocked requests.
+	changed chan struct{}
+	// date ranges of segments removed from the window.
+	removed []removedRange
+}
+
+type removedRange struct {
+	id   string
+	when time.Time
+}
+
+// New returns a Playlist using p as a template. The header of p,
+// such as its target duration and server control, is served to clients
+// along with at most window segments. If window is zero or negative,
+// segments are never removed.
+//
+// To support blocking reloads, p.ServerControl.CanBlockReload must be
+// true. Delta updates are likewise served only if
+// p.ServerControl.CanSkipUntil is set.
+func New(p *m3u8.Playlist, window int) *Playlist {
+	return &Playlist{p: p, window: window, changed: make(chan struct{})}
+}
+
+// AddPart appends a partial segment to the segment currently being
+// produced. The part is included in that segment by the next call
+// to AddSegment.
+func (pl *Playlist) AddPart(part m3u8.Part) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.p.Parts = append(pl.p.Parts, part)
+	pl.notify()
+}
+
+// AddSegment appends seg to the playlist, removing the oldest
+// segments to keep within the playlist's window. If seg has no Parts,
+// it is given the parts added by AddPart since the last segment.
+func (pl *Playlist) AddSegment(seg m3u8.Segment) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if seg.Parts == nil {
+		seg.Parts = pl.p.Parts
+	}
+	pl.p.Parts = nil
+	pl.p.Segments = append(pl.p.Segments, seg)
+	for pl.window > 0 && len(pl.p.Segments) > pl.window {
+		pl.removeFirst()
+	}
+	pl.trimParts()
+	pl.notify()
+}
+
+// removeFirst removes the first segment in the playlist,
+// updating the media and discontinuity sequence numbers.
+// Date ranges of the segment are remembered for delta updates.
+func (pl *Playlist) removeFirst() {
+	p := pl.p
+	if p.Segments[0].Discontinuity {
+		p.DiscontinuitySequence++
+	}
+	if sc := p.ServerControl; sc != nil && sc.CanSkipUntil > 0 {
+		pl.expireRemoved(sc.CanSkipUntil)
+		for _, dr := range p.Segments[0].DateRanges {
+			pl.removed = append(pl.removed, removedRange{dr.ID, time.Now()})
+		}
+	} else {
+		// delta updates are not served.
+		pl.removed = nil
+	}
+	p.Segments = p.Segments[1:]
+	p.Sequence++
+}
+
+// trimParts removes the partial segments of segments more than
+// three target durations from the end of the playlist,
+// as recommended by the specification.
+func (pl *Playlist) trimParts() {
+	var d time.Duration
+	for i := len(pl.p.Segments) - 1; i >= 0; i-- {
+		seg := &pl.p.Segments[i]
+		if d > 3*pl.p.TargetDuration {
+			if seg.Parts == nil {
+				return
+			}
+			seg.Parts = nil
+		}
+		d += seg.Duration
+	}
+}
+
+// Update calls fn with the underlying playlist, such as to set
+// preload hints or rendition reports. Blocked requests are
+// rechecked once fn returns.
+func (pl *Playlist) Update(fn func(p *m3u8.Playlist)) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	fn(pl.p)
+	pl.notify()
+}
+
+// End marks the playlist as complete by adding the EXT-X-ENDLIST tag.
+// Any blocked requests are served immediately.
+func (pl *Playlist) End() {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.p.End = true
+	pl.p.PreloadHints = nil
+	pl.notify()
+}
+
+func (pl *Playlist) notify() {
+	close(pl.changed)
+	pl.changed = make(chan struct{})
+}
+
+// request holds the delivery directives of a playlist request.
+type request struct {
+	// block is true if the client requested a blocking reload
+	// of segment msn or, if part >= 0, that segment's part.
+	block bool
+	msn   int
+	part  int
+	// skip is "YES" or "v2" if the client requested a delta update.
+	skip string
+}
+
+func parseRequest(req *http.Request) (*request, error) {
+	q := req.URL.Query()
+	r := &request{part: -1}
+	if q.Has("_HLS_msn") {
+		n, err := strconv.Atoi(q.Get("_HLS_msn"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("bad _HLS_msn %q", q.Get("_HLS_msn"))
+		}
+		r.block = true
+		r.msn = n
+	}
+	if q.Has("_HLS_part") {
+		if !r.block {
+			return nil, fmt.Errorf("_HLS_part without _HLS_msn")
+		}
+		n, err := strconv.Atoi(q.Get("_HLS_part"))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("bad _HLS_part %q", q.Get("_HLS_part"))
+		}
+		r.part = n
+	}
+	if q.Has("_HLS_skip") {
+		r.skip = q.Get("_HLS_skip")
+		if r.skip != "YES" && r.skip != "v2" {
+			return nil, fmt.Errorf("bad _HLS_skip %q", r.skip)
+		}
+	}
+	return r, nil
+}
+
+// lastSequence returns the media sequence number
+// of the last complete segment in the playlist.
+func (pl *Playlist) lastSequence() int {
+	return pl.p.Sequence + len(pl.p.Segments) - 1
+}
+
+// has reports whether the playlist holds the segment
+// or partial segment requested by r.
+func (pl *Playlist) has(r *request) bool {
+	last := pl.lastSequence()
+	if r.msn <= last {
+		return true
+	}
+	return r.part >= 0 && r.msn == last+1 && r.part < len(pl.p.Parts)
+}
+
+func (pl *Playlist) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r, err := parseRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pl.mu.Lock()
+	sc := pl.p.ServerControl
+	if sc == nil || !sc.CanBlockReload {
+		// Servers not advertising support must ignore the request.
+		r.block = false
+	}
+	if r.block && r.msn > pl.lastSequence()+2 {
+		pl.mu.Unlock()
+		http.Error(w, fmt.Sprintf("media sequence %d too far ahead of playlist", r.msn), http.StatusBadRequest)
+		return
+	}
+	// The specification recommends failing after
+	// three target durations.
+	var timeout <-chan time.Time
+	if r.block && pl.p.TargetDuration > 0 {
+		t := time.NewTimer(3 * pl.p.TargetDuration)
+		defer t.Stop()
+		timeout = t.C
+	}
+	for r.block && !pl.p.End && !pl.has(r) {
+		changed := pl.changed
+		pl.mu.Unlock()
+		select {
+		case <-changed:
+		case <-timeout:
+			http.Error(w, "requested segment not available", http.StatusServiceUnavailable)
+			return
+		case <-req.Context().Done():
+			return
+		}
+		pl.mu.Lock()
+	}
+	buf := &bytes.Buffer{}
+	err = m3u8.Encode(buf, pl.delta(r.skip))
+	maxAge := pl.maxAge(r.block)
+	pl.mu.Unlock()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("encode playlist: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", m3u8.MimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", maxAge/time.Second))
+	w.Write(buf.Bytes())
+}
+
+// delta returns the playlist to serve for the _HLS_skip value skip.
+// If delta updates are supported and requested, older segments are
+// replaced by an EXT-X-SKIP tag.
+func (pl *Playlist) delta(skip string) *m3u8.Playlist {
+	sc := pl.p.ServerControl
+	if skip == "" || sc == nil || sc.CanSkipUntil <= 0 || pl.p.End {
+		return pl.p
+	}
+	dateRanges := skip == "v2" && sc.CanSkipDateRanges
+
+	// Segments may be skipped if they start further than
+	// CanSkipUntil from the end of the playlist.
+	var d time.Duration
+	for _, seg := range pl.p.Segments {
+		d += seg.Duration
+	}
+	n := 0
+	for n < len(pl.p.Segments) && d > sc.CanSkipUntil {
+		d -= pl.p.Segments[n].Duration
+		n++
+	}
+	if !dateRanges {
+		// clients not supporting skipped date ranges still
+		// need them, so stop at the first segment with one.
+		for i := 0; i < n; i++ {
+			if len(pl.p.Segments[i].DateRanges) > 0 {
+				n = i
+				break
+			}
+		}
+	}
+	if n == 0 {
+		return pl.p
+	}
+
+	p := *pl.p
+	p.Segments = append([]m3u8.Segment(nil), p.Segments[n:]...)
+	p.Skip = &m3u8.Skip{Segments: n}
+	// The map and key may only be declared on skipped segments,
+	// so declare them again on the first segment served.
+	first := &p.Segments[0]
+	for i := n - 1; i >= 0 && (first.Map == nil || first.Key == nil); i-- {
+		skipped := pl.p.Segments[i]
+		if first.Map == nil {
+			first.Map = skipped.Map
+		}
+		if first.Key == nil {
+			first.Key = skipped.Key
+		}
+	}
+	if dateRanges {
+		pl.expireRemoved(sc.CanSkipUntil)
+		for _, r := range pl.removed {
+			p.Skip.RemovedDateRanges = append(p.Skip.RemovedDateRanges, r.id)
+		}
+	}
+	// EXT-X-SKIP requires protocol version 9, or 10 with
+	// the RECENTLY-REMOVED-DATERANGES attribute.
+	version := 9
+	if dateRanges {
+		version = 10
+	}
+	if p.Version < version {
+		p.Version = version
+	}
+	return &p
+}
+
+// expireRemoved forgets date ranges removed longer than d ago,
+// which clients able to use delta updates will have already seen.
+func (pl *Playlist) expireRemoved(d time.Duration) {
+	i := 0
+	for i < len(pl.removed) && time.Since(pl.removed[i].when) > d {
+		i++
+	}
+	pl.removed = pl.removed[i:]
+}
+
+// maxAge returns how long clients and caches may reuse a response.
+// Responses to blocking requests are for a unique URL which will
+// never change, so may be cached for long. Other responses change as
+// soon as a new part or segment is added.
+func (pl *Playlist) maxAge(block bool) time.Duration {
+	if pl.p.End {
+		return 24 * time.Hour
+	} else if block {
+		return 6 * pl.p.TargetDuration
+	}
+	d := pl.p.PartTarget
+	if d == 0 {
+		d = pl.p.TargetDuration / 2
+	}
+	if d < time.Second {
+		d = time.Second
+	}
+	return d
+}
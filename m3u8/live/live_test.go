@@ -0,0 +1,249 @@
+package live
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/untangledco/streaming/m3u8"
+)
+
+func newTestPlaylist(segments int) *Playlist {
+	pl := New(&m3u8.Playlist{
+		Version:        6,
+		TargetDuration: 4 * time.Second,
+		PartTarget:     time.Second,
+		ServerControl: &m3u8.ServerControl{
+			CanBlockReload: true,
+			CanSkipUntil:   12 * time.Second,
+			PartHoldBack:   3 * time.Second,
+		},
+	}, 6)
+	for i := 0; i < segments; i++ {
+		pl.AddSegment(m3u8.Segment{URI: "segment.ts", Duration: 4 * time.Second})
+	}
+	return pl
+}
+
+func get(t *testing.T, h http.Handler, target string) (*httptest.ResponseRecorder, *m3u8.Playlist) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK {
+		return rec, nil
+	}
+	p, err := m3u8.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return rec, p
+}
+
+func TestWindow(t *testing.T) {
+	pl := newTestPlaylist(5)
+	pl.AddSegment(m3u8.Segment{URI: "a.ts", Duration: 4 * time.Second, Discontinuity: true})
+	pl.AddSegment(m3u8.Segment{URI: "b.ts", Duration: 4 * time.Second})
+	_, p := get(t, pl, "/playlist.m3u8")
+	if len(p.Segments) != 6 {
+		t.Errorf("got %d segments, want 6", len(p.Segments))
+	}
+	if p.Sequence != 1 || p.DiscontinuitySequence != 0 {
+		t.Errorf("got media sequence %d, discontinuity sequence %d; want 1, 0", p.Sequence, p.DiscontinuitySequence)
+	}
+	for i := 0; i < 4; i++ {
+		pl.AddSegment(m3u8.Segment{URI: "c.ts", Duration: 4 * time.Second})
+	}
+	// the discontinuity is now on the first segment,
+	// so is not yet counted in the discontinuity sequence.
+	_, p = get(t, pl, "/playlist.m3u8")
+	if p.Sequence != 5 || p.DiscontinuitySequence != 0 || !p.Segments[0].Discontinuity {
+		t.Errorf("got media sequence %d, discontinuity sequence %d; want 5, 0 with discontinuity on first segment", p.Sequence, p.DiscontinuitySequence)
+	}
+	pl.AddSegment(m3u8.Segment{URI: "c.ts", Duration: 4 * time.Second})
+	_, p = get(t, pl, "/playlist.m3u8")
+	if p.Sequence != 6 || p.DiscontinuitySequence != 1 {
+		t.Errorf("got media sequence %d, discontinuity sequence %d; want 6, 1", p.Sequence, p.DiscontinuitySequence)
+	}
+}
+
+// The map and key of skipped segments still apply
+// to the segments of a delta update.
+func TestDeltaMap(t *testing.T) {
+	pl := New(&m3u8.Playlist{
+		Version:        6,
+		TargetDuration: 4 * time.Second,
+		ServerControl:  &m3u8.ServerControl{CanSkipUntil: 12 * time.Second},
+	}, 0)
+	m := &m3u8.Map{URI: "init.mp4"}
+	key := &m3u8.Key{Method: m3u8.EncryptMethodAES128, URI: "key"}
+	pl.AddSegment(m3u8.Segment{URI: "0.mp4", Duration: 4 * time.Second, Map: m, Key: key})
+	for i := 1; i < 6; i++ {
+		pl.AddSegment(m3u8.Segment{URI: fmt.Sprintf("%d.mp4", i), Duration: 4 * time.Second})
+	}
+	_, p := get(t, pl, "/playlist.m3u8?_HLS_skip=YES")
+	if p.Skip == nil || p.Skip.Segments != 3 {
+		t.Fatalf("want 3 skipped segments, got skip %+v", p.Skip)
+	}
+	first := p.Segments[0]
+	if first.Map == nil || *first.Map != *m {
+		t.Errorf("map of first segment = %+v, want %+v", first.Map, m)
+	}
+	if first.Key == nil || first.Key.URI != key.URI {
+		t.Errorf("key of first segment = %+v, want %+v", first.Key, key)
+	}
+	// the playlist itself is unchanged.
+	if pl.p.Segments[3].Map != nil {
+		t.Errorf("map set on segment of live playlist")
+	}
+}
+
+func TestRemovedDateRanges(t *testing.T) {
+	pl := newTestPlaylist(5)
+	pl.p.ServerControl.CanSkipDateRanges = true
+	pl.AddSegment(m3u8.Segment{URI: "ad.ts", Duration: 4 * time.Second, DateRanges: []m3u8.DateRange{{ID: "ad", Start: time.Now()}}})
+	for i := 0; i < 6; i++ {
+		pl.AddSegment(m3u8.Segment{URI: "segment.ts", Duration: 4 * time.Second})
+	}
+	_, p := get(t, pl, "/playlist.m3u8?_HLS_skip=v2")
+	if p.Skip == nil || len(p.Skip.RemovedDateRanges) != 1 || p.Skip.RemovedDateRanges[0] != "ad" {
+		t.Fatalf("want removed date range ad, got skip %+v", p.Skip)
+	}
+	if p.Version < 10 {
+		t.Errorf("delta update with removed date ranges has version %d, want at least 10", p.Version)
+	}
+
+	// removed date ranges are forgotten once clients have seen them,
+	// even without requests for delta updates.
+	pl.removed[0].when = time.Now().Add(-time.Minute)
+	pl.AddSegment(m3u8.Segment{URI: "segment.ts", Duration: 4 * time.Second})
+	if len(pl.removed) != 0 {
+		t.Errorf("removed date ranges not expired: %v", pl.removed)
+	}
+}
+
+func TestBlockingReload(t *testing.T) {
+	pl := newTestPlaylist(3)
+	var tests = []struct {
+		name   string
+		target string
+		add    func()
+		want   func(p *m3u8.Playlist) bool
+	}{
+		{
+			"segment",
+			"/playlist.m3u8?_HLS_msn=3",
+			func() { pl.AddSegment(m3u8.Segment{URI: "3.ts", Duration: 4 * time.Second}) },
+			func(p *m3u8.Playlist) bool { return p.Segments[len(p.Segments)-1].URI == "3.ts" },
+		},
+		{
+			"part",
+			"/playlist.m3u8?_HLS_msn=4&_HLS_part=1",
+			func() {
+				pl.AddPart(m3u8.Part{URI: "4.0.ts", Duration: time.Second, Independent: true})
+				pl.AddPart(m3u8.Part{URI: "4.1.ts", Duration: time.Second})
+			},
+			func(p *m3u8.Playlist) bool { return len(p.Parts) == 2 },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done := make(chan *m3u8.Playlist)
+			go func() {
+				_, p := get(t, pl, tt.target)
+				done <- p
+			}()
+			select {
+			case <-done:
+				t.Fatal("request returned before playlist updated")
+			case <-time.After(50 * time.Millisecond):
+			}
+			tt.add()
+			select {
+			case p := <-done:
+				if p == nil || !tt.want(p) {
+					t.Errorf("response does not hold requested update: %+v", p)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("request still blocked after playlist updated")
+			}
+		})
+	}
+
+	rec, _ := get(t, pl, "/playlist.m3u8?_HLS_msn=3")
+	if rec.Code != http.StatusOK {
+		t.Errorf("request for existing segment: status %d", rec.Code)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "max-age=24" {
+		t.Errorf("blocking response Cache-Control %q, want %q", got, "max-age=24")
+	}
+	rec, _ = get(t, pl, "/playlist.m3u8")
+	if got := rec.Header().Get("Cache-Control"); got != "max-age=1" {
+		t.Errorf("Cache-Control %q, want %q", got, "max-age=1")
+	}
+}
+
+func TestBadRequest(t *testing.T) {
+	pl := newTestPlaylist(3)
+	for _, target := range []string{
+		"/playlist.m3u8?_HLS_part=1",
+		"/playlist.m3u8?_HLS_msn=abc",
+		"/playlist.m3u8?_HLS_msn=3&_HLS_part=-1",
+		"/playlist.m3u8?_HLS_msn=99",
+		"/playlist.m3u8?_HLS_skip=NO",
+	} {
+		rec, _ := get(t, pl, target)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want %d", target, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestEndUnblocks(t *testing.T) {
+	pl := newTestPlaylist(1)
+	done := make(chan int)
+	go func() {
+		rec, _ := get(t, pl, "/playlist.m3u8?_HLS_msn=2")
+		done <- rec.Code
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pl.End()
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("status %d, want %d", code, http.StatusOK)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request still blocked after playlist ended")
+	}
+}
+
+func TestDeltaUpdate(t *testing.T) {
+	pl := newTestPlaylist(6)
+	_, p := get(t, pl, "/playlist.m3u8?_HLS_skip=YES")
+	if p.Skip == nil {
+		t.Fatal("no skip tag in delta update")
+	}
+	// 6 segments of 4 seconds; those starting more than
+	// 12 seconds from the end may be skipped.
+	if p.Skip.Segments != 3 || len(p.Segments) != 3 {
+		t.Errorf("skipped %d segments leaving %d, want 3 and 3", p.Skip.Segments, len(p.Segments))
+	}
+	if p.Version < 9 {
+		t.Errorf("delta update has version %d, want at least 9", p.Version)
+	}
+
+	pl.Update(func(p *m3u8.Playlist) {
+		p.Segments[1].DateRanges = []m3u8.DateRange{{ID: "ad", Start: time.Now()}}
+	})
+	_, p = get(t, pl, "/playlist.m3u8?_HLS_skip=YES")
+	if p.Skip == nil || p.Skip.Segments != 1 {
+		t.Errorf("date range skipped without v2 support: skip %+v", p.Skip)
+	}
+
+	_, p = get(t, pl, "/playlist.m3u8")
+	if p.Skip != nil || len(p.Segments) != 6 {
+		t.Errorf("full playlist request returned delta update")
+	}
+}
@@ -0,0 +1,443 @@
+package m3u8
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Low-latency HLS tags specified in draft-pantos-hls-rfc8216bis.
+const (
+	tagServerControl   = "#EXT-X-SERVER-CONTROL"   // 4.4.3.8
+	tagPartInf         = "#EXT-X-PART-INF"         // 4.4.3.7
+	tagSkip            = "#EXT-X-SKIP"             // 4.4.5.2
+	tagPreloadHint     = "#EXT-X-PRELOAD-HINT"     // 4.4.5.3
+	tagRenditionReport = "#EXT-X-RENDITION-REPORT" // 4.4.5.4
+)
+
+// Part represents the EXT-X-PART tag.
+// A Part is a partial segment: a subset of a segment which may be
+// published before the whole segment is available.
+type Part struct {
+	URI      string
+	Duration time.Duration
+	// Independent is true if the part starts with an independent
+	// frame, such as a keyframe.
+	Independent bool
+	// Range, if set, is the sub-range of the resource at URI
+	// holding the part.
+	Range ByteRange
+	// Gap indicates the part is not available.
+	Gap bool
+}
+
+func (p Part) String() string {
+	attrs := []string{
+		"DURATION=" + formatSeconds(p.Duration),
+		fmt.Sprintf("URI=%q", p.URI),
+	}
+	if p.Independent {
+		attrs = append(attrs, "INDEPENDENT=YES")
+	}
+	if p.Range != [2]int{0, 0} {
+		attrs = append(attrs, fmt.Sprintf("BYTERANGE=%q", p.Range))
+	}
+	if p.Gap {
+		attrs = append(attrs, "GAP=YES")
+	}
+	return tagPart + ":" + strings.Join(attrs, ",")
+}
+
+// ServerControl represents the EXT-X-SERVER-CONTROL tag,
+// which advertises the delivery directives supported by the server.
+type ServerControl struct {
+	// CanSkipUntil is the age of the oldest segments which may be
+	// skipped in delta updates. Zero means delta updates are unsupported.
+	CanSkipUntil time.Duration
+	// CanSkipDateRanges is true if delta updates may also skip
+	// EXT-X-DATERANGE tags.
+	CanSkipDateRanges bool
+	// HoldBack is the minimum distance from the end of the playlist
+	// at which clients should start playback.
+	HoldBack time.Duration
+	// PartHoldBack is like HoldBack when playing partial segments.
+	PartHoldBack time.Duration
+	// CanBlockReload is true if the server supports blocking
+	// playlist reloads.
+	CanBlockReload bool
+}
+
+func (c ServerControl) String() string {
+	var attrs []string
+	if c.CanBlockReload {
+		attrs = append(attrs, "CAN-BLOCK-RELOAD=YES")
+	}
+	if c.CanSkipUntil > 0 {
+		attrs = append(attrs, "CAN-SKIP-UNTIL="+formatSeconds(c.CanSkipUntil))
+	}
+	if c.CanSkipDateRanges {
+		attrs = append(attrs, "CAN-SKIP-DATERANGES=YES")
+	}
+	if c.HoldBack > 0 {
+		attrs = append(attrs, "HOLD-BACK="+formatSeconds(c.HoldBack))
+	}
+	if c.PartHoldBack > 0 {
+		attrs = append(attrs, "PART-HOLD-BACK="+formatSeconds(c.PartHoldBack))
+	}
+	return tagServerControl + ":" + strings.Join(attrs, ",")
+}
+
+// Skip represents the EXT-X-SKIP tag, which replaces segments
+// omitted from a playlist delta update.
+type Skip struct {
+	// Segments is the number of segments skipped.
+	Segments int
+	// RemovedDateRanges lists the IDs of date ranges
+	// removed from the playlist since the last update.
+	RemovedDateRanges []string
+}
+
+func (s Skip) String() string {
+	tag := fmt.Sprintf("%s:SKIPPED-SEGMENTS=%d", tagSkip, s.Segments)
+	if len(s.RemovedDateRanges) > 0 {
+		tag += fmt.Sprintf(",RECENTLY-REMOVED-DATERANGES=%q", strings.Join(s.RemovedDateRanges, "\t"))
+	}
+	return tag
+}
+
+// PreloadHint represents the EXT-X-PRELOAD-HINT tag,
+// informing clients of a resource which will soon be available.
+type PreloadHint struct {
+	Type PreloadType
+	URI  string
+	// Start and Length specify the byte range of the hinted
+	// resource. A zero Length means the range continues to the
+	// end of the resource.
+	Start  int
+	Length int
+}
+
+type PreloadType uint8
+
+const (
+	PreloadPart PreloadType = iota
+	PreloadMap
+)
+
+func (t PreloadType) String() string {
+	switch t {
+	case PreloadPart:
+		return "PART"
+	case PreloadMap:
+		return "MAP"
+	}
+	return "invalid"
+}
+
+func (h PreloadHint) String() string {
+	attrs := []string{"TYPE=" + h.Type.String(), fmt.Sprintf("URI=%q", h.URI)}
+	if h.Start > 0 {
+		attrs = append(attrs, fmt.Sprintf("BYTERANGE-START=%d", h.Start))
+	}
+	if h.Length > 0 {
+		attrs = append(attrs, fmt.Sprintf("BYTERANGE-LENGTH=%d", h.Length))
+	}
+	return tagPreloadHint + ":" + strings.Join(attrs, ",")
+}
+
+// RenditionReport represents the EXT-X-RENDITION-REPORT tag,
+// which reports the most recent segment and part of another
+// rendition so clients may switch to it without reloading its playlist.
+type RenditionReport struct {
+	URI string
+	// LastSequence is the media sequence number of
+	// the last segment in the rendition.
+	LastSequence int
+	// LastPart is the index of the last part of that segment.
+	LastPart int
+}
+
+func (r RenditionReport) String() string {
+	return fmt.Sprintf("%s:URI=%q,LAST-MSN=%d,LAST-PART=%d", tagRenditionReport, r.URI, r.LastSequence, r.LastPart)
+}
+
+func parseYesNo(s string) (bool, error) {
+	switch s {
+	case "YES":
+		return true, nil
+	case "NO":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected YES or NO, got %q", s)
+}
+
+func parsePart(l *lexer) (Part, error) {
+	var part Part
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return Part{}, errors.New(it.val)
+		case itemComma:
+			continue
+		case itemNewline:
+			if part.URI == "" || part.Duration == 0 {
+				return Part{}, fmt.Errorf("missing URI or DURATION attribute")
+			}
+			return part, nil
+		case itemAttrName:
+		default:
+			return Part{}, fmt.Errorf("expected %s, got %s", itemAttrName, it)
+		}
+		attr := it.val
+		if it = l.nextItem(); it.typ != itemEquals {
+			return Part{}, fmt.Errorf("missing equals after %s", attr)
+		}
+		it = l.nextItem()
+		if it.typ == itemError {
+			return Part{}, errors.New(it.val)
+		}
+		value := strings.Trim(it.val, `"`)
+		var err error
+		switch attr {
+		case "URI":
+			part.URI = value
+		case "DURATION":
+			part.Duration, err = parseSegmentDuration(value)
+		case "INDEPENDENT":
+			part.Independent, err = parseYesNo(value)
+		case "BYTERANGE":
+			part.Range, err = parseByteRange(value)
+		case "GAP":
+			part.Gap, err = parseYesNo(value)
+		default:
+			err = fmt.Errorf("unknown attribute")
+		}
+		if err != nil {
+			return Part{}, fmt.Errorf("%s: %w", attr, err)
+		}
+	}
+	return Part{}, fmt.Errorf("unexpected end of tag")
+}
+
+func parseServerControl(l *lexer) (*ServerControl, error) {
+	var c ServerControl
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return nil, errors.New(it.val)
+		case itemComma:
+			continue
+		case itemNewline:
+			return &c, nil
+		case itemAttrName:
+		default:
+			return nil, fmt.Errorf("expected %s, got %s", itemAttrName, it)
+		}
+		attr := it.val
+		if it = l.nextItem(); it.typ != itemEquals {
+			return nil, fmt.Errorf("missing equals after %s", attr)
+		}
+		it = l.nextItem()
+		if it.typ == itemError {
+			return nil, errors.New(it.val)
+		}
+		value := strings.Trim(it.val, `"`)
+		var err error
+		switch attr {
+		case "CAN-SKIP-UNTIL":
+			c.CanSkipUntil, err = parseSegmentDuration(value)
+		case "CAN-SKIP-DATERANGES":
+			c.CanSkipDateRanges, err = parseYesNo(value)
+		case "HOLD-BACK":
+			c.HoldBack, err = parseSegmentDuration(value)
+		case "PART-HOLD-BACK":
+			c.PartHoldBack, err = parseSegmentDuration(value)
+		case "CAN-BLOCK-RELOAD":
+			c.CanBlockReload, err = parseYesNo(value)
+		default:
+			err = fmt.Errorf("unknown attribute")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", attr, err)
+		}
+	}
+	return nil, fmt.Errorf("unexpected end of tag")
+}
+
+func parsePartInf(l *lexer) (time.Duration, error) {
+	var target time.Duration
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return 0, errors.New(it.val)
+		case itemComma:
+			continue
+		case itemNewline:
+			if target == 0 {
+				return 0, fmt.Errorf("missing PART-TARGET attribute")
+			}
+			return target, nil
+		case itemAttrName:
+		default:
+			return 0, fmt.Errorf("expected %s, got %s", itemAttrName, it)
+		}
+		attr := it.val
+		if it = l.nextItem(); it.typ != itemEquals {
+			return 0, fmt.Errorf("missing equals after %s", attr)
+		}
+		it = l.nextItem()
+		if it.typ == itemError {
+			return 0, errors.New(it.val)
+		}
+		value := strings.Trim(it.val, `"`)
+		var err error
+		switch attr {
+		case "PART-TARGET":
+			target, err = parseSegmentDuration(value)
+		default:
+			err = fmt.Errorf("unknown attribute")
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", attr, err)
+		}
+	}
+	return 0, fmt.Errorf("unexpected end of tag")
+}
+
+func parseSkip(l *lexer) (*Skip, error) {
+	var skip Skip
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return nil, errors.New(it.val)
+		case itemComma:
+			continue
+		case itemNewline:
+			return &skip, nil
+		case itemAttrName:
+		default:
+			return nil, fmt.Errorf("expected %s, got %s", itemAttrName, it)
+		}
+		attr := it.val
+		if it = l.nextItem(); it.typ != itemEquals {
+			return nil, fmt.Errorf("missing equals after %s", attr)
+		}
+		it = l.nextItem()
+		if it.typ == itemError {
+			return nil, errors.New(it.val)
+		}
+		value := strings.Trim(it.val, `"`)
+		var err error
+		switch attr {
+		case "SKIPPED-SEGMENTS":
+			skip.Segments, err = strconv.Atoi(value)
+		case "RECENTLY-REMOVED-DATERANGES":
+			skip.RemovedDateRanges = strings.Split(value, "\t")
+		default:
+			err = fmt.Errorf("unknown attribute")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", attr, err)
+		}
+	}
+	return nil, fmt.Errorf("unexpected end of tag")
+}
+
+func parsePreloadHint(l *lexer) (PreloadHint, error) {
+	var hint PreloadHint
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return PreloadHint{}, errors.New(it.val)
+		case itemComma:
+			continue
+		case itemNewline:
+			if hint.URI == "" {
+				return PreloadHint{}, fmt.Errorf("missing URI attribute")
+			}
+			return hint, nil
+		case itemAttrName:
+		default:
+			return PreloadHint{}, fmt.Errorf("expected %s, got %s", itemAttrName, it)
+		}
+		attr := it.val
+		if it = l.nextItem(); it.typ != itemEquals {
+			return PreloadHint{}, fmt.Errorf("missing equals after %s", attr)
+		}
+		it = l.nextItem()
+		if it.typ == itemError {
+			return PreloadHint{}, errors.New(it.val)
+		}
+		value := strings.Trim(it.val, `"`)
+		var err error
+		switch attr {
+		case "TYPE":
+			switch value {
+			case "PART":
+				hint.Type = PreloadPart
+			case "MAP":
+				hint.Type = PreloadMap
+			default:
+				err = fmt.Errorf("unknown type %q", value)
+			}
+		case "URI":
+			hint.URI = value
+		case "BYTERANGE-START":
+			hint.Start, err = strconv.Atoi(value)
+		case "BYTERANGE-LENGTH":
+			hint.Length, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("unknown attribute")
+		}
+		if err != nil {
+			return PreloadHint{}, fmt.Errorf("%s: %w", attr, err)
+		}
+	}
+	return PreloadHint{}, fmt.Errorf("unexpected end of tag")
+}
+
+func parseRenditionReport(l *lexer) (RenditionReport, error) {
+	var r RenditionReport
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return RenditionReport{}, errors.New(it.val)
+		case itemComma:
+			continue
+		case itemNewline:
+			if r.URI == "" {
+				return RenditionReport{}, fmt.Errorf("missing URI attribute")
+			}
+			return r, nil
+		case itemAttrName:
+		default:
+			return RenditionReport{}, fmt.Errorf("expected %s, got %s", itemAttrName, it)
+		}
+		attr := it.val
+		if it = l.nextItem(); it.typ != itemEquals {
+			return RenditionReport{}, fmt.Errorf("missing equals after %s", attr)
+		}
+		it = l.nextItem()
+		if it.typ == itemError {
+			return RenditionReport{}, errors.New(it.val)
+		}
+		value := strings.Trim(it.val, `"`)
+		var err error
+		switch attr {
+		case "URI":
+			r.URI = value
+		case "LAST-MSN":
+			r.LastSequence, err = strconv.Atoi(value)
+		case "LAST-PART":
+			r.LastPart, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("unknown attribute")
+		}
+		if err != nil {
+			return RenditionReport{}, fmt.Errorf("%s: %w", attr, err)
+		}
+	}
+	return RenditionReport{}, fmt.Errorf("unexpected end of tag")
+}
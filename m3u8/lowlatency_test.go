@@ -0,0 +1,72 @@
+package m3u8
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeLowLatency(t *testing.T) {
+	f, err := os.Open("testdata/low_latency.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := ServerControl{CanBlockReload: true, CanSkipUntil: 24 * time.Second, PartHoldBack: 1500 * time.Millisecond}
+	if p.ServerControl == nil || *p.ServerControl != sc {
+		t.Errorf("server control: got %+v, want %+v", p.ServerControl, sc)
+	}
+	if p.PartTarget != 500*time.Millisecond {
+		t.Errorf("part target: got %s, want %s", p.PartTarget, 500*time.Millisecond)
+	}
+	if len(p.Segments) != 3 {
+		t.Fatalf("got %d segments, want 3", len(p.Segments))
+	}
+	parts := p.Segments[2].Parts
+	if len(parts) != 8 {
+		t.Fatalf("got %d parts in last segment, want 8", len(parts))
+	}
+	want := Part{URI: "filePart268.4.mp4", Duration: 500 * time.Millisecond, Independent: true}
+	if parts[4] != want {
+		t.Errorf("got part %+v, want %+v", parts[4], want)
+	}
+	if len(p.Parts) != 2 || p.Parts[1].URI != "filePart269.1.mp4" {
+		t.Errorf("parts of incomplete segment not decoded: %+v", p.Parts)
+	}
+	hints := []PreloadHint{{Type: PreloadPart, URI: "filePart269.2.mp4"}}
+	if !reflect.DeepEqual(p.PreloadHints, hints) {
+		t.Errorf("preload hints: got %+v, want %+v", p.PreloadHints, hints)
+	}
+	report := RenditionReport{URI: "../4M/waitForMSN.php", LastSequence: 269, LastPart: 1}
+	if len(p.RenditionReports) != 2 || p.RenditionReports[1] != report {
+		t.Errorf("rendition reports: got %+v, want second %+v", p.RenditionReports, report)
+	}
+}
+
+func TestDecodeSkip(t *testing.T) {
+	const delta = `#EXTM3U
+#EXT-X-VERSION:9
+#EXT-X-TARGETDURATION:4
+#EXT-X-MEDIA-SEQUENCE:266
+#EXT-X-SKIP:SKIPPED-SEGMENTS=3,RECENTLY-REMOVED-DATERANGES="ad1	ad2"
+#EXTINF:4.000,
+fileSequence269.mp4
+`
+	p, err := Decode(strings.NewReader(delta))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Skip{Segments: 3, RemovedDateRanges: []string{"ad1", "ad2"}}
+	if !reflect.DeepEqual(p.Skip, want) {
+		t.Errorf("got skip %+v, want %+v", p.Skip, want)
+	}
+	if len(p.Segments) != 1 {
+		t.Errorf("got %d segments, want 1", len(p.Segments))
+	}
+}
@@ -21,6 +21,9 @@ type Playlist struct {
 	Segments            []Segment
 	IndependentSegments bool
 	Start               *StartPoint
+	// Defines holds variables which may be referenced in URIs and
+	// attribute values. See Resolve.
+	Defines []Define
 
 	// Media playlist
 	// RFC 8216, 4.4.3.1
@@ -31,11 +34,40 @@ type Playlist struct {
 	Type                  PlaylistType
 	IFramesOnly           bool
 
+	// Low-latency media playlist
+	// draft-pantos-hls-rfc8216bis, 4.4.3.7 and 4.4.5
+	ServerControl *ServerControl
+	// PartTarget is the maximum duration of any Part,
+	// from the EXT-X-PART-INF tag.
+	PartTarget time.Duration
+	// Skip is set in playlist delta updates, where Segments
+	// excludes the segments skipped by the server.
+	Skip *Skip
+	// Parts holds the partial segments of the segment currently
+	// being produced, which has no URI yet.
+	Parts            []Part
+	PreloadHints     []PreloadHint
+	RenditionReports []RenditionReport
+	// DateRanges holds any date ranges following the last segment.
+	DateRanges []DateRange
+
 	// Master playlist
-	Media       []Rendition
-	Variants    []Variant
+	Media    []Rendition
+	Variants []Variant
+	// IFrames lists the I-frame playlists of the variants,
+	// used by clients for fast-forward and scrubbing.
+	IFrames     []IFrameInfo
 	SessionData []SessionData
 	SessionKey  *Key
+	// ContentSteering, if set, points clients to a server
+	// determining which pathway, such as a CDN, to stream from.
+	ContentSteering *ContentSteering
+
+	// Tags holds any custom tags which do not belong to a particular
+	// segment and precede the first segment.
+	Tags []Tag
+	// TrailingTags holds any custom tags following the last segment.
+	TrailingTags []Tag
 }
 
 type Segment struct {
@@ -59,6 +91,11 @@ type Segment struct {
 
 	// Holds information on how to decrypt this segment.
 	// If nil, the segment is not encrypted.
+	// Decode sets Key on every segment to which an EXT-X-KEY tag
+	// applies, not just the segment immediately following the tag.
+	// Likewise Encode only writes the tag when the key differs from
+	// the previous segment's, so keys may be rotated by setting a
+	// different Key on some segment.
 	Key *Key
 
 	Map *Map
@@ -68,7 +105,29 @@ type Segment struct {
 	// millisecond accuracy.
 	DateTime time.Time
 
-	DateRange *DateRange
+	// Gap indicates that the segment is missing, such as when
+	// an encoder has failed. Clients must not load it.
+	Gap bool
+
+	// Bitrate is the approximate bitrate of the segment in kilobits
+	// per second from the EXT-X-BITRATE tag. Like Key, Decode sets
+	// Bitrate on every segment to which the tag applies, and Encode
+	// only writes the tag when the bitrate changes.
+	Bitrate int
+
+	// DateRanges holds any EXT-X-DATERANGE tags preceding the segment.
+	DateRanges []DateRange
+	// Cue holds any legacy ad break signalling tags, such as
+	// EXT-X-CUE-OUT, preceding the segment.
+	Cue *Cue
+
+	// Parts holds the partial segments making up the segment,
+	// listed in low-latency playlists.
+	Parts []Part
+
+	// Tags holds any custom tags preceding the segment's URI,
+	// and any cue tags which could not be decoded into Cue.
+	Tags []Tag
 }
 
 // Key represents the EXT-X-KEY tag specified in RFC 8216 seciton 4.3.2.3.
@@ -83,15 +142,22 @@ type Key struct {
 	// version; subsequent values are minor versions.
 	FormatVersions []uint32
 	// IV is a 128-bit unsigned integer holding the key's
-	// initialisation vector.
+	// initialisation vector. If unset, the media sequence number
+	// of each segment is used instead. See DecryptSegment.
 	IV [16]byte
 }
 
 func (k Key) String() string {
 	var attrs []string
 	attrs = append(attrs, fmt.Sprintf("METHOD=%s", k.Method))
+	if k.Method == EncryptMethodNone {
+		// no other attributes are allowed.
+		return tagKey + ":" + attrs[0]
+	}
 	attrs = append(attrs, fmt.Sprintf("URI=%q", k.URI))
-	attrs = append(attrs, fmt.Sprintf("IV=0x%s", hex.EncodeToString(k.IV[:])))
+	if k.IV != [16]byte{} {
+		attrs = append(attrs, fmt.Sprintf("IV=0x%s", hex.EncodeToString(k.IV[:])))
+	}
 	if k.Format != "" {
 		attrs = append(attrs, fmt.Sprintf("KEYFORMAT=%q", k.Format))
 	}
@@ -150,13 +216,19 @@ type Map struct {
 
 func (m Map) String() string {
 	if m.ByteRange != [2]int{0, 0} {
-		return fmt.Sprintf("%s:URI=%q,BYTERANGE=%s", tagMap, m.URI, m.ByteRange)
+		// A map is not preceded by a sub-range,
+		// so always write the offset.
+		r := fmt.Sprintf("%d@%d", m.ByteRange[0], m.ByteRange[1])
+		return fmt.Sprintf("%s:URI=%q,BYTERANGE=%q", tagMap, m.URI, r)
 	}
 	return fmt.Sprintf("%s:URI=%q", tagMap, m.URI)
 }
 
-// ByteRange represents...
-// The first entry is an offset, the second...?
+// ByteRange represents a sub-range of a resource as written in the
+// EXT-X-BYTERANGE tag. The first entry is the length of the sub-range
+// in bytes, the second is the offset from the start of the resource.
+// A zero offset is omitted when encoded, in which case the sub-range
+// begins immediately after that of the previous segment.
 type ByteRange [2]int
 
 func (r ByteRange) String() string {
@@ -176,7 +248,11 @@ type DateRange struct {
 	End      time.Time
 	Duration time.Duration
 	Planned  time.Duration
-	// value must be a string, float or hex sequence (int?)
+	// Custom holds client-defined attributes, whose names must
+	// start with "X-". Attributes of interstitials are held here
+	// unless Class is ClassInterstitial. Values may be of type string, float64 or
+	// []byte for quoted strings, numbers and hexadecimal sequences
+	// respectively.
 	Custom     map[string]any
 	CueCommand *scte35.Splice
 	// Contains the first of the in/out cue pair. Command may be
@@ -186,6 +262,9 @@ type DateRange struct {
 	// Type must match the "out" cue.
 	CueIn     *scte35.Splice
 	EndOnNext bool
+	// Interstitial holds the attributes of date ranges of class
+	// ClassInterstitial. It is nil for other classes.
+	Interstitial *Interstitial
 }
 
 type PlaylistType uint8
@@ -206,7 +285,21 @@ func (t PlaylistType) String() string {
 	return "invalid"
 }
 
+// StartPoint represents the EXT-X-START tag, indicating a
+// preferred point at which to start playing a playlist.
 type StartPoint struct {
-	Offset  float32
+	// Offset from the start of the playlist.
+	// A negative offset is from the end of the last segment.
+	Offset time.Duration
+	// If true, clients should start playback from exactly Offset
+	// rather than from the start of the segment containing it.
 	Precise bool
 }
+
+func (s StartPoint) String() string {
+	tag := tagStartPoint + ":TIME-OFFSET=" + formatSeconds(s.Offset)
+	if s.Precise {
+		tag += ",PRECISE=YES"
+	}
+	return tag
+}
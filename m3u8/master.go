@@ -2,6 +2,7 @@ package m3u8
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -43,9 +44,18 @@ type Rendition struct {
 	// Characteristics contains Uniform Type Identifiers.
 	// For example []string{CharacteristicTranscribesDialog, ChractersticEasyToRead}
 	Characteristics []string
-	// Channels contains the different counts of audio channels available in the Rendition.
-	// For example []string{"2,6"} represents stereo (2) and 5.1 surround-sound (5 + 1) channels.
-	Channels []string
+	// Channels describes the audio channels of audio renditions.
+	Channels *Channels
+	// BitDepth is the audio bit depth of audio renditions.
+	BitDepth int
+	// SampleRate is the audio sample rate in Hz of audio renditions.
+	SampleRate int
+	// StableRenditionID identifies the rendition across playlist
+	// reloads and content steering pathways.
+	StableRenditionID string
+	// PathwayID identifies the content steering pathway, such as a
+	// CDN, from which the rendition is served. See ContentSteering.
+	PathwayID string
 }
 
 func (r Rendition) String() string {
@@ -78,13 +88,80 @@ func (r Rendition) String() string {
 		chars := strings.Join(r.Characteristics, ",")
 		attrs = append(attrs, fmt.Sprintf("CHARACTERISTICS=%q", chars))
 	}
-	if len(r.Channels) > 0 {
-		channels := strings.Join(r.Channels, "/")
-		attrs = append(attrs, fmt.Sprintf("CHANNELS=%q", channels))
+	if r.Channels != nil {
+		attrs = append(attrs, fmt.Sprintf("CHANNELS=%q", r.Channels))
+	}
+	if r.BitDepth > 0 {
+		attrs = append(attrs, fmt.Sprintf("BIT-DEPTH=%d", r.BitDepth))
+	}
+	if r.SampleRate > 0 {
+		attrs = append(attrs, fmt.Sprintf("SAMPLE-RATE=%d", r.SampleRate))
+	}
+	if r.StableRenditionID != "" {
+		attrs = append(attrs, fmt.Sprintf("STABLE-RENDITION-ID=%q", r.StableRenditionID))
+	}
+	if r.PathwayID != "" {
+		attrs = append(attrs, fmt.Sprintf("PATHWAY-ID=%q", r.PathwayID))
 	}
 	return tagRendition + ":" + strings.Join(attrs, ",")
 }
 
+// Channels represents the CHANNELS attribute of audio renditions.
+type Channels struct {
+	// Count is the number of independent, simultaneous audio channels.
+	// For example 2 for stereo, or 6 for 5.1 surround sound.
+	Count int
+	// Coding lists audio coding identifiers of object-based audio,
+	// such as "JOC" for Dolby Atmos.
+	Coding []string
+	// Usage lists audio channel usage indicators, such as
+	// ChannelsBinaural and ChannelsDownmix.
+	Usage []string
+}
+
+// Audio channel usage indicators.
+const (
+	ChannelsBinaural  = "BINAURAL"
+	ChannelsImmersive = "IMMERSIVE"
+	ChannelsDownmix   = "DOWNMIX"
+)
+
+func (c *Channels) String() string {
+	s := strconv.Itoa(c.Count)
+	if len(c.Coding) == 0 && len(c.Usage) == 0 {
+		return s
+	}
+	coding := "-"
+	if len(c.Coding) > 0 {
+		coding = strings.Join(c.Coding, ",")
+	}
+	s += "/" + coding
+	if len(c.Usage) > 0 {
+		s += "/" + strings.Join(c.Usage, ",")
+	}
+	return s
+}
+
+func parseChannels(s string) (*Channels, error) {
+	params := strings.Split(s, "/")
+	if len(params) > 3 {
+		return nil, fmt.Errorf("too many parameters")
+	}
+	var c Channels
+	var err error
+	c.Count, err = strconv.Atoi(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("parse channel count: %w", err)
+	}
+	if len(params) > 1 && params[1] != "-" {
+		c.Coding = strings.Split(params[1], ",")
+	}
+	if len(params) > 2 {
+		c.Usage = strings.Split(params[2], ",")
+	}
+	return &c, nil
+}
+
 type MediaType uint8
 
 const (
@@ -182,13 +259,44 @@ type Variant struct {
 	Video          string
 	Subtitles      string
 	ClosedCaptions string // May be NoClosedCaptions to explicitly signal no rendition.
+
+	// PathwayID identifies the content steering pathway, such as a
+	// CDN, from which the variant is served. See ContentSteering.
+	PathwayID string
+
+	// Score orders variants by preference; clients should prefer
+	// variants with higher scores. A nil Score omits the attribute.
+	Score *float64
+	// VideoRange is the dynamic range of the video, such as SDR or
+	// HDR10 (VideoRangePQ).
+	VideoRange VideoRange
+	// AllowedCPC restricts the content protection configurations
+	// allowed to play the variant for each key format.
+	AllowedCPC []CPC
+	// StableVariantID identifies the variant across playlist
+	// reloads and content steering pathways.
+	StableVariantID string
+	// SupplementalCodecs lists codecs which players may use instead
+	// of Codecs, such as Dolby Vision profiles backwards-compatible
+	// with the base HEVC stream. Each entry may be followed by
+	// "/"-separated compatibility brands, for example "dvh1.08.07/db4h".
+	SupplementalCodecs []string
+	// ReqVideoLayout lists the video channel specifiers required to
+	// play the variant, such as VideoLayoutStereo for stereoscopic video.
+	ReqVideoLayout []string
 }
 
 func (v Variant) String() string {
+	return fmt.Sprintf("%s:%s\n%s", tagVariant, strings.Join(v.attributes(), ","), v.URI)
+}
+
+// attributes returns the attributes of v, except URI,
+// in the order in which they are written.
+func (v Variant) attributes() []string {
 	var attrs []string
 	attrs = append(attrs, fmt.Sprintf("BANDWIDTH=%d", v.Bandwidth))
 	if v.AverageBandwidth > 0 {
-		attrs = append(attrs, fmt.Sprintf("AVERAGE-BANDWIDTH=%d,", v.AverageBandwidth))
+		attrs = append(attrs, fmt.Sprintf("AVERAGE-BANDWIDTH=%d", v.AverageBandwidth))
 	}
 	if len(v.Codecs) > 0 {
 		attrs = append(attrs, fmt.Sprintf("CODECS=%q", strings.Join(v.Codecs, ",")))
@@ -211,10 +319,38 @@ func (v Variant) String() string {
 	if v.Subtitles != "" {
 		attrs = append(attrs, fmt.Sprintf("SUBTITLES=%q", v.Subtitles))
 	}
-	if v.ClosedCaptions != "" && v.ClosedCaptions != NoClosedCaptions {
+	if v.ClosedCaptions == NoClosedCaptions {
+		// an enumerated string, so not quoted.
+		attrs = append(attrs, "CLOSED-CAPTIONS="+NoClosedCaptions)
+	} else if v.ClosedCaptions != "" {
 		attrs = append(attrs, fmt.Sprintf("CLOSED-CAPTIONS=%q", v.ClosedCaptions))
 	}
-	return fmt.Sprintf("%s:%s\n%s", tagVariant, strings.Join(attrs, ","), v.URI)
+	if v.PathwayID != "" {
+		attrs = append(attrs, fmt.Sprintf("PATHWAY-ID=%q", v.PathwayID))
+	}
+	if v.Score != nil {
+		attrs = append(attrs, "SCORE="+strconv.FormatFloat(*v.Score, 'f', -1, 64))
+	}
+	if v.VideoRange != VideoRangeNone {
+		attrs = append(attrs, fmt.Sprintf("VIDEO-RANGE=%s", v.VideoRange))
+	}
+	if len(v.AllowedCPC) > 0 {
+		cpcs := make([]string, len(v.AllowedCPC))
+		for i := range v.AllowedCPC {
+			cpcs[i] = v.AllowedCPC[i].String()
+		}
+		attrs = append(attrs, fmt.Sprintf("ALLOWED-CPC=%q", strings.Join(cpcs, ",")))
+	}
+	if v.StableVariantID != "" {
+		attrs = append(attrs, fmt.Sprintf("STABLE-VARIANT-ID=%q", v.StableVariantID))
+	}
+	if len(v.SupplementalCodecs) > 0 {
+		attrs = append(attrs, fmt.Sprintf("SUPPLEMENTAL-CODECS=%q", strings.Join(v.SupplementalCodecs, ",")))
+	}
+	if len(v.ReqVideoLayout) > 0 {
+		attrs = append(attrs, fmt.Sprintf("REQ-VIDEO-LAYOUT=%q", strings.Join(v.ReqVideoLayout, "/")))
+	}
+	return attrs
 }
 
 // NoClosedCaptions may be the value for Variant.ClosedCaptions to
@@ -222,6 +358,92 @@ func (v Variant) String() string {
 // Variant.
 const NoClosedCaptions string = "NONE"
 
+type VideoRange uint8
+
+const (
+	// VideoRangeNone omits the VIDEO-RANGE attribute.
+	// Clients assume VideoRangeSDR.
+	VideoRangeNone VideoRange = iota
+	VideoRangeSDR
+	// Perceptual Quantizer, as used by HDR10 and Dolby Vision.
+	VideoRangePQ
+	// Hybrid Log-Gamma.
+	VideoRangeHLG
+)
+
+func (r VideoRange) String() string {
+	switch r {
+	case VideoRangeNone:
+		return ""
+	case VideoRangeSDR:
+		return "SDR"
+	case VideoRangePQ:
+		return "PQ"
+	case VideoRangeHLG:
+		return "HLG"
+	}
+	return "invalid"
+}
+
+func parseVideoRange(s string) (VideoRange, error) {
+	for r := VideoRangeSDR; r <= VideoRangeHLG; r++ {
+		if r.String() == s {
+			return r, nil
+		}
+	}
+	return VideoRangeNone, fmt.Errorf("unknown video range %q", s)
+}
+
+// Video channel specifiers for Variant.ReqVideoLayout.
+const (
+	VideoLayoutStereo = "CH-STEREO"
+	VideoLayoutMono   = "CH-MONO"
+)
+
+// CPC represents an entry of the ALLOWED-CPC attribute.
+type CPC struct {
+	// KeyFormat corresponds to the KEYFORMAT attribute of a Key.
+	KeyFormat string
+	// Labels lists content protection configurations,
+	// such as "SW" or "HW".
+	Labels []string
+}
+
+func (c CPC) String() string {
+	return c.KeyFormat + ":" + strings.Join(c.Labels, "/")
+}
+
+func parseAllowedCPC(s string) ([]CPC, error) {
+	var cpcs []CPC
+	for _, entry := range strings.Split(s, ",") {
+		// key formats are typically reverse DNS names,
+		// so no colon is expected before that of the label list.
+		i := strings.LastIndex(entry, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("missing colon in %q", entry)
+		}
+		cpcs = append(cpcs, CPC{KeyFormat: entry[:i], Labels: strings.Split(entry[i+1:], "/")})
+	}
+	return cpcs, nil
+}
+
+// isStableID reports whether s is a valid STABLE-VARIANT-ID or
+// STABLE-RENDITION-ID value.
+func isStableID(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("+/=.-_", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 type HDCPLevel uint8
 
 const (
@@ -250,6 +472,12 @@ func (l HDCPLevel) String() string {
 // - ClosedCaptions
 type IFrameInfo Variant
 
+func (f IFrameInfo) String() string {
+	attrs := Variant(f).attributes()
+	attrs = append(attrs, fmt.Sprintf("URI=%q", f.URI))
+	return tagIFrameVariant + ":" + strings.Join(attrs, ",")
+}
+
 // SessionData represents the EXT-X-SESSION-DATA tag.
 type SessionData struct {
 	ID       string // This attribute is REQUIRED
@@ -272,3 +500,23 @@ func (sd SessionData) String() string {
 	}
 	return tagSessionData + ":" + strings.Join(attrs, ",")
 }
+
+// ContentSteering represents the EXT-X-CONTENT-STEERING tag.
+// Clients periodically request a steering manifest from ServerURI
+// listing the pathways from which to stream in order of preference.
+// See the steering package for serving steering manifests.
+type ContentSteering struct {
+	// ServerURI points to the steering manifest. It is required.
+	ServerURI string
+	// PathwayID names the pathway to use until the steering
+	// manifest has been loaded.
+	PathwayID string
+}
+
+func (cs ContentSteering) String() string {
+	attrs := []string{fmt.Sprintf("SERVER-URI=%q", cs.ServerURI)}
+	if cs.PathwayID != "" {
+		attrs = append(attrs, fmt.Sprintf("PATHWAY-ID=%q", cs.PathwayID))
+	}
+	return tagContentSteering + ":" + strings.Join(attrs, ",")
+}
@@ -14,117 +14,497 @@ const (
 	tagHead                = tagStart + "M3U"
 	tagVersion             = "#EXT-X-VERSION"
 	tagVariant             = "#EXT-X-STREAM-INF"
+	tagIFrameVariant       = "#EXT-X-I-FRAME-STREAM-INF" // RFC 8216, 4.3.4.3
 	tagRendition           = "#EXT-X-MEDIA"
-	tagPlaylistType        = "#EXT-X-PLAYLIST-TYPE"        // RFC 8216, 4.4.3.5
-	tagTargetDuration      = "#EXT-X-TARGETDURATION"       // RFC 8216, 4.4.3.1
-	tagMediaSequence       = "#EXT-X-MEDIA-SEQUENCE"       // RFC 8216, 4.3.3.2
-	tagEndList             = "#EXT-X-ENDLIST"              // RFC 8216, 4.4.3.4
-	tagIndependentSegments = "#EXT-X-INDEPENDENT-SEGMENTS" // RFC 8216, 4.3.5.1
-	tagSessionData         = "#EXT-X-SESSION-DATA"         // RFC 8216, 4.3.4.4
+	tagPlaylistType        = "#EXT-X-PLAYLIST-TYPE"          // RFC 8216, 4.4.3.5
+	tagTargetDuration      = "#EXT-X-TARGETDURATION"         // RFC 8216, 4.4.3.1
+	tagMediaSequence       = "#EXT-X-MEDIA-SEQUENCE"         // RFC 8216, 4.3.3.2
+	tagEndList             = "#EXT-X-ENDLIST"                // RFC 8216, 4.4.3.4
+	tagIndependentSegments = "#EXT-X-INDEPENDENT-SEGMENTS"   // RFC 8216, 4.3.5.1
+	tagIFramesOnly         = "#EXT-X-I-FRAMES-ONLY"          // RFC 8216, 4.3.3.6
+	tagSessionData         = "#EXT-X-SESSION-DATA"           // RFC 8216, 4.3.4.4
+	tagSessionKey          = "#EXT-X-SESSION-KEY"            // RFC 8216, 4.3.4.5
+	tagStartPoint          = "#EXT-X-START"                  // RFC 8216, 4.3.5.2
+	tagDiscontinuitySeq    = "#EXT-X-DISCONTINUITY-SEQUENCE" // RFC 8216, 4.3.3.3
+	tagContentSteering     = "#EXT-X-CONTENT-STEERING"       // RFC 8216bis, 4.4.6.6
 )
 
+// Decode reads a complete playlist from rd.
+// To process very large playlists one segment at a time,
+// use a Decoder instead.
 func Decode(rd io.Reader) (*Playlist, error) {
-	lex := newLexer(rd)
-	go lex.run()
-	it := <-lex.items
-	if it.typ == itemError {
-		return nil, errors.New(it.val)
+	return decode(NewDecoder(rd))
+}
+
+// DecodeStrict is like Decode, but continues decoding after
+// malformed lines so that every error is reported in an ErrorList.
+func DecodeStrict(rd io.Reader) (*Playlist, error) {
+	d := NewDecoder(rd)
+	d.SetMode(Strict)
+	return decode(d)
+}
+
+// DecodeLenient is like Decode, but skips malformed lines,
+// returning a best-effort playlist alongside warnings describing
+// each problem. An error is only returned if decoding could not
+// continue, such as when the input is not a playlist.
+// Segments without a valid duration are given the playlist's target
+// duration, or dropped if it has none, so that the playlist may be
+// encoded again.
+func DecodeLenient(rd io.Reader) (*Playlist, []*ParseError, error) {
+	d := NewDecoder(rd)
+	d.SetMode(Lenient)
+	p, err := decode(d)
+	return p, d.Warnings(), err
+}
+
+func decode(d *Decoder) (*Playlist, error) {
+	var segments []Segment
+	for d.Scan() {
+		segments = append(segments, *d.Segment())
 	}
-	if it.typ != itemTag || it.val != tagHead {
-		return nil, fmt.Errorf("expected head tag, got %q", it.val)
+	p := d.Playlist()
+	if p == nil {
+		return nil, d.Err()
 	}
+	p.Segments = segments
+	return p, d.Err()
+}
 
-	p := &Playlist{}
-	var err error
-	for it := range lex.items {
+// A Decoder reads a playlist from an input stream one segment at a time.
+// Only the current segment is held in memory, so a Decoder may be used
+// to process playlists too large to be decoded in full by Decode.
+//
+// Successive calls to Scan step through each segment in the playlist.
+// Playlist tags are decoded as they are encountered;
+// those appearing after the final segment, such as EXT-X-ENDLIST,
+// are only available once Scan returns false.
+type Decoder struct {
+	lex      *lexer
+	playlist Playlist
+	segment  *Segment
+	// the key applying to the current segment; see Segment.Key.
+	key *Key
+	// the bitrate applying to the current segment; see Segment.Bitrate.
+	bitrate int
+	// custom tags not yet known to belong to the playlist or a segment.
+	pending []Tag
+	// date ranges not yet known to belong to a segment.
+	ranges []DateRange
+	// whether a segment has been decoded, after which
+	// pending tags not preceding a segment are trailing.
+	seen bool
+	head bool
+	done bool
+	err  error
+	mode Mode
+	// errors skipped over in Strict or Lenient mode.
+	skipped ErrorList
+}
+
+// NewDecoder returns a new Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{lex: newLexer(r)}
+}
+
+// Scan advances the Decoder to the next segment, which will then be
+// available through the Segment method. It returns false when the
+// scan stops, either by reaching the end of the input or an error.
+// After Scan returns false, the Err method will return any error that
+// occurred during scanning.
+func (d *Decoder) Scan() bool {
+	if d.done {
+		return false
+	}
+	if !d.head {
+		if err := d.readHead(); err != nil {
+			d.err = err
+			d.done = true
+			return false
+		}
+		d.head = true
+	}
+	for {
+		it := d.lex.nextItem()
 		switch it.typ {
+		case itemEOF:
+			d.flushPending()
+			d.playlist.DateRanges = append(d.playlist.DateRanges, d.ranges...)
+			d.ranges = nil
+			d.done = true
+			return false
 		case itemError:
-			return p, errors.New(it.val)
+			if err := d.fail(item{}, errors.New(it.val)); err != nil {
+				d.err = err
+				d.done = true
+				return false
+			}
+			continue
 		case itemNewline:
 			continue
+		case itemTag:
 		default:
-			if it.typ != itemTag {
-				return p, fmt.Errorf("unexpected %s %q, expected tag", it.typ, it.val)
+			err := d.fail(item{}, fmt.Errorf("unexpected %s %q, expected tag", it.typ, it.val))
+			if err != nil {
+				d.err = err
+				d.done = true
+				return false
 			}
+			continue
 		}
 
-		switch it.val {
-		case tagVersion:
-			it = <-lex.items
-			if p.Version != 0 {
-				return p, fmt.Errorf("parse %s: playlist version already specified", it)
-			}
-			p.Version, err = strconv.Atoi(it.val)
-			if err != nil {
-				return p, fmt.Errorf("parse playlist version: %w", err)
-			}
-		case tagIndependentSegments:
-			p.IndependentSegments = true
-		case tagVariant:
-			variant, err := parseVariant(lex.items)
-			if err != nil {
-				return p, fmt.Errorf("parse variant: %w", err)
-			}
-			p.Variants = append(p.Variants, *variant)
-		case tagRendition:
-			rend, err := parseRendition(lex.items)
-			if err != nil {
-				return p, fmt.Errorf("parse rendition: %w", err)
-			}
-			p.Media = append(p.Media, *rend)
-		case tagPlaylistType:
-			it = <-lex.items
-			typ, err := parsePlaylistType(it)
-			if err != nil {
-				return p, fmt.Errorf("parse playlist type: %w", err)
+		segment, err := d.decodeTag(it)
+		if err != nil {
+			if err := d.fail(it, err); err != nil {
+				d.err = err
+				d.done = true
+				return false
 			}
-			p.Type = typ
+			continue
+		}
+		if segment != nil {
+			d.segment = segment
+			d.seen = true
+			return true
+		}
+	}
+}
 
-		case tagTargetDuration:
-			it = <-lex.items
-			dur, err := parseTargetDuration(it)
-			if err != nil {
-				return p, fmt.Errorf("parse target duration: %w", err)
-			}
-			p.TargetDuration = dur
+// Segment returns the most recent segment decoded by a call to Scan.
+func (d *Decoder) Segment() *Segment {
+	return d.segment
+}
 
-		case tagSegmentDuration, tagByteRange, tagKey:
-			segment, err := parseSegment(lex.items, it)
-			if err != nil {
-				return p, fmt.Errorf("parse segment: %w", err)
-			}
-			p.Segments = append(p.Segments, *segment)
+// Playlist returns the playlist decoded so far, excluding its segments.
+// It returns nil if the input does not start with a valid playlist header.
+func (d *Decoder) Playlist() *Playlist {
+	if d.done && !d.head {
+		return nil
+	}
+	return &d.playlist
+}
 
-		case tagEndList:
-			p.End = true
-		case tagMediaSequence:
-			it = <-lex.items
-			seq, err := strconv.Atoi(it.val)
-			if err != nil {
-				return p, fmt.Errorf("parse media sequence: %w", err)
-			}
-			p.Sequence = seq
-		default:
-			if lex.debug {
-				fmt.Fprintln(os.Stderr, "unknown tag", it)
+// Err returns the first error that was encountered by the Decoder.
+// In Strict mode, it returns an ErrorList of every error.
+// In Lenient mode, it only returns errors which stopped decoding,
+// such as a missing playlist header; see Warnings.
+// Errors in the input are of type *ParseError.
+func (d *Decoder) Err() error {
+	if d.err == nil && d.mode == Strict && len(d.skipped) > 0 {
+		return d.skipped
+	}
+	return d.err
+}
+
+// SetMode sets how the Decoder handles malformed input.
+// It must be called before the first call to Scan.
+func (d *Decoder) SetMode(m Mode) {
+	d.mode = m
+}
+
+// Warnings returns the errors skipped over when decoding in Lenient mode,
+// and the reasons tags such as malformed cues were kept undecoded
+// in the Tags of a segment.
+func (d *Decoder) Warnings() []*ParseError {
+	if d.mode != Lenient {
+		return nil
+	}
+	return d.skipped
+}
+
+// fail returns err as a *ParseError positioned at the item last read
+// while decoding tag. In Strict and Lenient mode, the error is
+// instead recorded, the rest of the line is skipped and fail returns nil.
+func (d *Decoder) fail(tag item, err error) error {
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		perr = &ParseError{Line: d.lex.last.line, Column: d.lex.last.col, Tag: tag.val, Err: err}
+	}
+	if d.mode == FailFast {
+		return perr
+	}
+	d.skipped = append(d.skipped, perr)
+	d.lex.skipLine()
+	return nil
+}
+
+// warn records err about a tag which was kept undecoded,
+// to be returned by Warnings in Lenient mode.
+// Unlike fail, decoding is unaffected in every mode.
+func (d *Decoder) warn(tag item, err error) {
+	if d.mode == Lenient {
+		d.skipped = append(d.skipped, &ParseError{Line: d.lex.last.line, Column: d.lex.last.col, Tag: tag.val, Err: err})
+	}
+}
+
+func (d *Decoder) readHead() error {
+	it := d.lex.nextItem()
+	if it.typ == itemError {
+		return &ParseError{Line: it.line, Column: it.col, Err: errors.New(it.val)}
+	}
+	if it.typ != itemTag || it.val != tagHead {
+		return &ParseError{Line: it.line, Column: it.col, Err: fmt.Errorf("expected head tag, got %q", it.val)}
+	}
+	return nil
+}
+
+// flushPending adds the pending custom tags to the playlist's Tags,
+// or to its TrailingTags once a segment has been decoded.
+func (d *Decoder) flushPending() {
+	p := &d.playlist
+	if d.seen {
+		p.TrailingTags = append(p.TrailingTags, d.pending...)
+	} else {
+		p.Tags = append(p.Tags, d.pending...)
+	}
+	d.pending = nil
+}
+
+// decodeTag decodes the tag held in it. If it marks the start of a
+// segment, the entire segment is decoded and returned.
+func (d *Decoder) decodeTag(it item) (*Segment, error) {
+	p := &d.playlist
+	lex := d.lex
+	var err error
+	if !lexedTags[it.val] && !cueTags[it.val] {
+		tag, err := parseTag(lex, it)
+		if err != nil {
+			return nil, err
+		}
+		d.pending = append(d.pending, tag)
+		return nil, nil
+	}
+
+	switch it.val {
+	case tagSegmentDuration, tagByteRange, tagKey, tagDiscontinuity, tagMap, tagDateTime, tagDateRange,
+		tagGap, tagBitrate, tagPart, tagCueOut, tagCueOutCont, tagCueIn, tagOATCLS, tagSCTE35:
+	default:
+		// Custom tags preceding a playlist tag belong to the playlist.
+		d.flushPending()
+	}
+
+	switch it.val {
+	case tagVersion:
+		it = lex.nextItem()
+		if p.Version != 0 {
+			return nil, fmt.Errorf("parse %s: playlist version already specified", it)
+		}
+		p.Version, err = strconv.Atoi(it.val)
+		if err != nil {
+			return nil, fmt.Errorf("parse playlist version: %w", err)
+		}
+	case tagIndependentSegments:
+		p.IndependentSegments = true
+	case tagIFramesOnly:
+		p.IFramesOnly = true
+	case tagDefine:
+		def, err := parseDefine(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse define: %w", err)
+		}
+		p.Defines = append(p.Defines, def)
+	case tagVariant:
+		variant, err := parseVariant(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse variant: %w", err)
+		}
+		p.Variants = append(p.Variants, *variant)
+	case tagIFrameVariant:
+		info, err := parseIFrameInfo(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse i-frame variant: %w", err)
+		}
+		p.IFrames = append(p.IFrames, *info)
+	case tagContentSteering:
+		cs, err := parseContentSteering(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse content steering: %w", err)
+		}
+		p.ContentSteering = cs
+	case tagRendition:
+		rend, err := parseRendition(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse rendition: %w", err)
+		}
+		p.Media = append(p.Media, *rend)
+	case tagPlaylistType:
+		it = lex.nextItem()
+		typ, err := parsePlaylistType(it)
+		if err != nil {
+			return nil, fmt.Errorf("parse playlist type: %w", err)
+		}
+		p.Type = typ
+
+	case tagTargetDuration:
+		it = lex.nextItem()
+		dur, err := parseTargetDuration(it)
+		if err != nil {
+			return nil, fmt.Errorf("parse target duration: %w", err)
+		}
+		p.TargetDuration = dur
+
+	case tagDateRange:
+		// Date ranges may follow the last segment,
+		// so do not start a segment.
+		dr, err := parseDateRange(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse date range: %w", err)
+		}
+		d.ranges = append(d.ranges, *dr)
+
+	case tagSegmentDuration, tagByteRange, tagKey, tagDiscontinuity, tagMap, tagDateTime,
+		tagGap, tagBitrate, tagPart, tagCueOut, tagCueOutCont, tagCueIn, tagOATCLS, tagSCTE35:
+		segment, err := parseSegment(lex, it, d.fail, d.warn)
+		if err != nil {
+			return nil, err
+		} else if segment == nil {
+			// input ended before the segment's URI.
+			return nil, nil
+		} else if segment.URI == "" {
+			p.Parts = segment.Parts
+			d.ranges = append(d.ranges, segment.DateRanges...)
+			return nil, nil
+		}
+		if len(d.pending) > 0 {
+			segment.Tags = append(d.pending, segment.Tags...)
+			d.pending = nil
+		}
+		if len(d.ranges) > 0 {
+			segment.DateRanges = append(d.ranges, segment.DateRanges...)
+			d.ranges = nil
+		}
+		if segment.Key == nil {
+			segment.Key = d.key
+		} else if segment.Key.Method == EncryptMethodNone {
+			segment.Key = nil
+		}
+		d.key = segment.Key
+		if segment.Bitrate == 0 {
+			segment.Bitrate = d.bitrate
+		}
+		d.bitrate = segment.Bitrate
+		if segment.Duration <= 0 && d.mode == Lenient {
+			// Encode rejects segments without a duration, so
+			// assume the longest allowed by the target duration.
+			if p.TargetDuration <= 0 {
+				d.warn(it, fmt.Errorf("segment %s: no duration or target duration, dropped", segment.URI))
+				return nil, nil
 			}
-			// throw away whatever is next; we don't support it but also don't want to
-			// return errors while this package is in development.
-			<-lex.items
+			d.warn(it, fmt.Errorf("segment %s: no duration, using target duration %s", segment.URI, p.TargetDuration))
+			segment.Duration = p.TargetDuration
+		}
+		return segment, nil
+
+	case tagEndList:
+		p.End = true
+	case tagMediaSequence:
+		it = lex.nextItem()
+		seq, err := strconv.Atoi(it.val)
+		if err != nil {
+			return nil, fmt.Errorf("parse media sequence: %w", err)
+		}
+		p.Sequence = seq
+	case tagDiscontinuitySeq:
+		it = lex.nextItem()
+		seq, err := strconv.Atoi(it.val)
+		if err != nil {
+			return nil, fmt.Errorf("parse discontinuity sequence: %w", err)
+		}
+		p.DiscontinuitySequence = seq
+	case tagStartPoint:
+		start, err := parseStartPoint(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse start: %w", err)
 		}
+		p.Start = start
+	case tagSessionData:
+		sd, err := parseSessionData(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse session data: %w", err)
+		}
+		p.SessionData = append(p.SessionData, *sd)
+	case tagServerControl:
+		sc, err := parseServerControl(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse server control: %w", err)
+		}
+		p.ServerControl = sc
+	case tagPartInf:
+		p.PartTarget, err = parsePartInf(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse part information: %w", err)
+		}
+	case tagSkip:
+		skip, err := parseSkip(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse skip: %w", err)
+		}
+		p.Skip = skip
+	case tagPreloadHint:
+		hint, err := parsePreloadHint(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse preload hint: %w", err)
+		}
+		p.PreloadHints = append(p.PreloadHints, hint)
+	case tagRenditionReport:
+		r, err := parseRenditionReport(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse rendition report: %w", err)
+		}
+		p.RenditionReports = append(p.RenditionReports, r)
+	case tagSessionKey:
+		key, err := parseKey(lex)
+		if err != nil {
+			return nil, fmt.Errorf("parse session key: %w", err)
+		}
+		p.SessionKey = &key
+	default:
+		if lex.debug {
+			fmt.Fprintln(os.Stderr, "unknown tag", it)
+		}
+		// throw away whatever is next; we don't support it but also don't want to
+		// return errors while this package is in development.
+		lex.nextItem()
 	}
-	return p, nil
+	return nil, nil
 }
 
-func parseVariant(items chan item) (*Variant, error) {
+func parseVariant(l *lexer) (*Variant, error) {
+	return parseStreamInf(l, false)
+}
+
+func parseIFrameInfo(l *lexer) (*IFrameInfo, error) {
+	v, err := parseStreamInf(l, true)
+	if err != nil {
+		return nil, err
+	}
+	return (*IFrameInfo)(v), nil
+}
+
+// parseStreamInf parses the attributes shared by the EXT-X-STREAM-INF
+// and EXT-X-I-FRAME-STREAM-INF tags. The URI of a variant is on the
+// line following the tag, whereas for I-frame variants (iframe is true)
+// it is held in the URI attribute.
+func parseStreamInf(l *lexer, iframe bool) (*Variant, error) {
 	var v Variant
-	for it := range items {
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
 		switch it.typ {
 		case itemError:
 			return nil, errors.New(it.val)
-		case itemComma, itemNewline:
+		case itemComma:
+			continue
+		case itemNewline:
+			if iframe {
+				if v.URI == "" {
+					return nil, fmt.Errorf("missing URI attribute")
+				}
+				return &v, nil
+			}
 			continue
 		case itemURL:
+			if iframe {
+				return nil, fmt.Errorf("unexpected url %q", it.val)
+			}
 			v.URI = it.val
 			return &v, nil
 		default:
@@ -133,7 +513,7 @@ func parseVariant(items chan item) (*Variant, error) {
 			}
 		}
 		attr := it
-		it = <-items
+		it = l.nextItem()
 		if it.typ != itemEquals {
 			return nil, fmt.Errorf("missing equals after %s", attr)
 		}
@@ -142,8 +522,10 @@ func parseVariant(items chan item) (*Variant, error) {
 		case "PROGRAM-ID", "NAME":
 			// parsing PROGRAM-ID attribute unsupported; removed in HLS version 6
 			// NAME is non-standard, should be set in Rendition.
+			// Skip the value.
+			l.nextItem()
 		case "BANDWIDTH", "AVERAGE-BANDWIDTH":
-			it = <-items
+			it = l.nextItem()
 			if it.typ != itemNumber {
 				return nil, fmt.Errorf("parse bandwidth attribute: unexpected %s", it)
 			}
@@ -157,20 +539,20 @@ func parseVariant(items chan item) (*Variant, error) {
 				v.AverageBandwidth = n
 			}
 		case "CODECS":
-			it = <-items
+			it = l.nextItem()
 			if it.typ != itemString {
 				return nil, fmt.Errorf("parse codecs attribute: unexpected %s", it)
 			}
 			v.Codecs = strings.Split(strings.Trim(it.val, `"`), ",")
 		case "RESOLUTION":
-			it = <-items
+			it = l.nextItem()
 			res, err := parseResolution(it.val)
 			if err != nil {
 				return nil, fmt.Errorf("parse resolution: %w", err)
 			}
 			v.Resolution = res
 		case "FRAME-RATE":
-			it = <-items
+			it = l.nextItem()
 			if it.typ != itemNumber {
 				return nil, fmt.Errorf("parse frame rate: unexpected %s", it)
 			}
@@ -180,7 +562,7 @@ func parseVariant(items chan item) (*Variant, error) {
 			}
 			v.FrameRate = float32(n)
 		case "HDCP-LEVEL":
-			it = <-items
+			it = l.nextItem()
 			l, err := parseHDCPLevel(it.val)
 			if err != nil {
 				return nil, fmt.Errorf("parse HDCP level: %w", err)
@@ -188,7 +570,7 @@ func parseVariant(items chan item) (*Variant, error) {
 			v.HDCP = l
 		case "AUDIO", "VIDEO", "SUBTITLES":
 			name := attr.val
-			it = <-items
+			it = l.nextItem()
 			if it.typ != itemString {
 				return nil, fmt.Errorf("parse %s: unexpected %s", name, it)
 			}
@@ -201,15 +583,71 @@ func parseVariant(items chan item) (*Variant, error) {
 				v.Subtitles = it.val
 			}
 		case "CLOSED-CAPTIONS":
-			it = <-items
+			it = l.nextItem()
 			if it.typ != itemString {
 				return nil, fmt.Errorf("parse closed-captions: unexpected %s", it)
 			}
 			v.ClosedCaptions = strings.Trim(it.val, `"`)
+		case "PATHWAY-ID":
+			it = l.nextItem()
+			if it.typ != itemString {
+				return nil, fmt.Errorf("parse pathway id: unexpected %s", it)
+			}
+			v.PathwayID = strings.Trim(it.val, `"`)
+		case "URI":
+			if !iframe {
+				return nil, fmt.Errorf("unknown attribute %s", attr.val)
+			}
+			it = l.nextItem()
+			if it.typ != itemString {
+				return nil, fmt.Errorf("parse uri: unexpected %s", it)
+			}
+			v.URI = strings.Trim(it.val, `"`)
+		case "SCORE":
+			it = l.nextItem()
+			if it.typ != itemNumber {
+				return nil, fmt.Errorf("parse score: unexpected %s", it)
+			}
+			n, err := strconv.ParseFloat(it.val, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse score: %w", err)
+			}
+			v.Score = &n
+		case "VIDEO-RANGE":
+			it = l.nextItem()
+			r, err := parseVideoRange(it.val)
+			if err != nil {
+				return nil, fmt.Errorf("parse video range: %w", err)
+			}
+			v.VideoRange = r
+		case "ALLOWED-CPC":
+			it = l.nextItem()
+			cpcs, err := parseAllowedCPC(strings.Trim(it.val, `"`))
+			if err != nil {
+				return nil, fmt.Errorf("parse allowed cpc: %w", err)
+			}
+			v.AllowedCPC = cpcs
+		case "STABLE-VARIANT-ID", "SUPPLEMENTAL-CODECS", "REQ-VIDEO-LAYOUT":
+			it = l.nextItem()
+			if it.typ != itemString {
+				return nil, fmt.Errorf("parse %s: unexpected %s", attr.val, it)
+			}
+			val := strings.Trim(it.val, `"`)
+			switch attr.val {
+			case "STABLE-VARIANT-ID":
+				v.StableVariantID = val
+			case "SUPPLEMENTAL-CODECS":
+				v.SupplementalCodecs = strings.Split(val, ",")
+			case "REQ-VIDEO-LAYOUT":
+				v.ReqVideoLayout = strings.Split(val, "/")
+			}
 		default:
 			return nil, fmt.Errorf("unknown attribute %s", attr.val)
 		}
 	}
+	if iframe && v.URI != "" {
+		return &v, nil
+	}
 	return nil, fmt.Errorf("no url")
 }
 
@@ -244,19 +682,19 @@ func parseHDCPLevel(s string) (HDCPLevel, error) {
 	return 0, fmt.Errorf("unknown HDCP level %q", s)
 }
 
-func parseRendition(items chan item) (*Rendition, error) {
+func parseRendition(l *lexer) (*Rendition, error) {
 	var rend Rendition
 	var err error
-	for it := range items {
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
 		if it.typ != itemAttrName {
 			return nil, fmt.Errorf("expected attribute name, got %s", it)
 		}
 		attr := it
-		it = <-items
+		it = l.nextItem()
 		if it.typ != itemEquals {
 			return nil, fmt.Errorf("parse %s: expected =, got %s", attr, it)
 		}
-		it = <-items
+		it = l.nextItem()
 		switch attr.val {
 		case "TYPE":
 			rend.Type, err = parseMediaType(it.val)
@@ -291,13 +729,30 @@ func parseRendition(items chan item) (*Rendition, error) {
 				return nil, fmt.Errorf("parse instream-id: %w", err)
 			}
 		case "CHARACTERISTICS":
-			rend.Characteristics = strings.Split(it.val, ",")
+			rend.Characteristics = strings.Split(strings.Trim(it.val, `"`), ",")
 		case "CHANNELS":
-			rend.Channels = strings.Split(strings.Trim(it.val, `"`), "/")
+			rend.Channels, err = parseChannels(strings.Trim(it.val, `"`))
+			if err != nil {
+				return nil, fmt.Errorf("parse channels: %w", err)
+			}
+		case "BIT-DEPTH", "SAMPLE-RATE":
+			n, err := strconv.Atoi(it.val)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", attr.val, err)
+			}
+			if attr.val == "BIT-DEPTH" {
+				rend.BitDepth = n
+			} else {
+				rend.SampleRate = n
+			}
+		case "STABLE-RENDITION-ID":
+			rend.StableRenditionID = strings.Trim(it.val, `"`)
+		case "PATHWAY-ID":
+			rend.PathwayID = strings.Trim(it.val, `"`)
 		default:
 			return nil, fmt.Errorf("unknown rendition attribute %s", attr.val)
 		}
-		it = <-items
+		it = l.nextItem()
 		switch it.typ {
 		case itemError:
 			return nil, fmt.Errorf("next attribute: %s", it.val)
@@ -312,6 +767,42 @@ func parseRendition(items chan item) (*Rendition, error) {
 	return &rend, nil
 }
 
+func parseContentSteering(l *lexer) (*ContentSteering, error) {
+	var cs ContentSteering
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return nil, errors.New(it.val)
+		case itemNewline:
+			if cs.ServerURI == "" {
+				return nil, fmt.Errorf("missing server URI")
+			}
+			return &cs, nil
+		case itemComma:
+			continue
+		}
+		if it.typ != itemAttrName {
+			return nil, fmt.Errorf("unexpected %s %q", it.typ, it.val)
+		}
+		attr := it.val
+		it = l.nextItem()
+		if it.typ != itemEquals {
+			return nil, fmt.Errorf("expected %q after %s, got %q", "=", attr, it.val)
+		}
+
+		it = l.nextItem()
+		switch attr {
+		case "SERVER-URI":
+			cs.ServerURI = strings.Trim(it.val, `"`)
+		case "PATHWAY-ID":
+			cs.PathwayID = strings.Trim(it.val, `"`)
+		default:
+			return nil, fmt.Errorf("unexpected attribute %q", attr)
+		}
+	}
+	return nil, fmt.Errorf("unexpected end of tag")
+}
+
 func parseMediaType(s string) (MediaType, error) {
 	for t := MediaAudio; t <= MediaClosedCaptions; t++ {
 		if t.String() == s {
@@ -408,3 +899,94 @@ func parseByteRange(s string) (ByteRange, error) {
 	}
 	return ByteRange{n, nn}, nil
 }
+
+func parseStartPoint(l *lexer) (*StartPoint, error) {
+	var start StartPoint
+	var offset bool
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return nil, errors.New(it.val)
+		case itemComma:
+			continue
+		case itemNewline:
+			if !offset {
+				return nil, fmt.Errorf("missing TIME-OFFSET attribute")
+			}
+			return &start, nil
+		case itemAttrName:
+		default:
+			return nil, fmt.Errorf("expected %s, got %s", itemAttrName, it)
+		}
+		attr := it
+		if it = l.nextItem(); it.typ != itemEquals {
+			return nil, fmt.Errorf("missing equals after %s", attr)
+		}
+		it = l.nextItem()
+		switch attr.val {
+		case "TIME-OFFSET":
+			// offsets may be negative, in which case they are lexed
+			// as strings rather than numbers.
+			f, err := strconv.ParseFloat(it.val, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse time offset: %w", err)
+			}
+			start.Offset = time.Duration(f * float64(time.Second))
+			offset = true
+		case "PRECISE":
+			switch it.val {
+			case "YES":
+				start.Precise = true
+			case "NO":
+				start.Precise = false
+			default:
+				return nil, fmt.Errorf("parse precise: unexpected %s", it)
+			}
+		default:
+			return nil, fmt.Errorf("unknown attribute %s", attr.val)
+		}
+	}
+	return nil, fmt.Errorf("unexpected end of tag")
+}
+
+func parseSessionData(l *lexer) (*SessionData, error) {
+	var sd SessionData
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return nil, errors.New(it.val)
+		case itemComma:
+			continue
+		case itemNewline:
+			if sd.ID == "" {
+				return nil, fmt.Errorf("missing DATA-ID attribute")
+			}
+			return &sd, nil
+		case itemAttrName:
+		default:
+			return nil, fmt.Errorf("expected %s, got %s", itemAttrName, it)
+		}
+		attr := it
+		if it = l.nextItem(); it.typ != itemEquals {
+			return nil, fmt.Errorf("missing equals after %s", attr)
+		}
+		it = l.nextItem()
+		if it.typ != itemString {
+			return nil, fmt.Errorf("parse %s: unexpected %s", attr.val, it)
+		}
+		val := strings.Trim(it.val, `"`)
+		switch attr.val {
+		case "DATA-ID":
+			sd.ID = val
+		case "VALUE":
+			sd.Value = val
+		case "URI":
+			sd.URI = val
+		case "LANGUAGE":
+			sd.Language = val
+		default:
+			return nil, fmt.Errorf("unknown attribute %s", attr.val)
+		}
+	}
+	return nil, fmt.Errorf("unexpected end of tag")
+}
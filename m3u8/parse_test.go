@@ -1,7 +1,9 @@
 package m3u8
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -80,6 +82,55 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+func TestDecoder(t *testing.T) {
+	want := longPlaylist(5000)
+	r, w := io.Pipe()
+	go func() {
+		enc := NewEncoder(w)
+		if err := enc.WriteHeader(want); err != nil {
+			w.CloseWithError(err)
+			return
+		}
+		for i := range want.Segments {
+			if err := enc.WriteSegment(&want.Segments[i]); err != nil {
+				w.CloseWithError(err)
+				return
+			}
+		}
+		w.CloseWithError(enc.Close())
+	}()
+
+	d := NewDecoder(r)
+	var i int
+	for d.Scan() {
+		if i >= len(want.Segments) {
+			t.Fatalf("decoded more than %d segments", len(want.Segments))
+		}
+		if !reflect.DeepEqual(*d.Segment(), want.Segments[i]) {
+			t.Errorf("segment %d: got %+v, want %+v", i, *d.Segment(), want.Segments[i])
+		}
+		i++
+	}
+	if err := d.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if i != len(want.Segments) {
+		t.Errorf("decoded %d segments, want %d", i, len(want.Segments))
+	}
+	p := d.Playlist()
+	if !p.End || p.Type != PlaylistVOD || p.TargetDuration != want.TargetDuration {
+		t.Errorf("playlist header not decoded: %+v", p)
+	}
+
+	d = NewDecoder(strings.NewReader("001.ts\n"))
+	if d.Scan() {
+		t.Errorf("scanned segment from playlist with no header")
+	}
+	if d.Err() == nil || d.Playlist() != nil {
+		t.Errorf("want nil playlist and non-nil error for playlist with no header")
+	}
+}
+
 func TestVariant(t *testing.T) {
 	f, err := os.Open("testdata/master.m3u8")
 	if err != nil {
@@ -122,6 +173,96 @@ func TestVariant(t *testing.T) {
 	}
 }
 
+func TestContentSteering(t *testing.T) {
+	s := `#EXTM3U
+#EXT-X-CONTENT-STEERING:SERVER-URI="/steering?video=00012",PATHWAY-ID="CDN-A"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="A",NAME="English",PATHWAY-ID="CDN-A",URI="https://a.example.com/en.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,AUDIO="A",PATHWAY-ID="CDN-A"
+https://a.example.com/low.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=1280000,AUDIO="A",PATHWAY-ID="CDN-B"
+https://b.example.com/low.m3u8
+`
+	p, err := Decode(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ContentSteering{ServerURI: "/steering?video=00012", PathwayID: "CDN-A"}
+	if !reflect.DeepEqual(p.ContentSteering, want) {
+		t.Errorf("content steering = %+v, want %+v", p.ContentSteering, want)
+	}
+	if p.Media[0].PathwayID != "CDN-A" || p.Variants[1].PathwayID != "CDN-B" {
+		t.Errorf("pathway IDs not decoded")
+	}
+	sb := &strings.Builder{}
+	if err := Encode(sb, p); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Decode(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, again) {
+		t.Errorf("content steering not preserved when encoding")
+		t.Log(sb)
+	}
+}
+
+func TestMasterAttributes(t *testing.T) {
+	s := `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="atmos",NAME="English",CHANNELS="16/JOC/BINAURAL,IMMERSIVE",BIT-DEPTH=24,SAMPLE-RATE=48000,STABLE-RENDITION-ID="en-atmos",URI="atmos.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="stereo",NAME="English",CHANNELS="2",CHARACTERISTICS="public.accessibility.describes-video,public.easy-to-read",URI="stereo.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=20000000,CODECS="hvc1.2.4.L153.b0,ec-3",SCORE=2.0,VIDEO-RANGE=PQ,ALLOWED-CPC="com.example.drm1:SMART-TV/PC,com.apple.streamingkeydelivery:HW",STABLE-VARIANT-ID="2160p-pq",SUPPLEMENTAL-CODECS="dvh1.08.07/db4h",REQ-VIDEO-LAYOUT="CH-STEREO/CH-MONO",AUDIO="atmos"
+2160p.m3u8
+`
+	p, err := Decode(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	atmos := p.Media[0]
+	wantChannels := &Channels{Count: 16, Coding: []string{"JOC"}, Usage: []string{ChannelsBinaural, ChannelsImmersive}}
+	if !reflect.DeepEqual(atmos.Channels, wantChannels) {
+		t.Errorf("channels = %+v, want %+v", atmos.Channels, wantChannels)
+	}
+	if atmos.BitDepth != 24 || atmos.SampleRate != 48000 || atmos.StableRenditionID != "en-atmos" {
+		t.Errorf("rendition attributes not decoded: %+v", atmos)
+	}
+	if len(p.Media[1].Characteristics) != 2 || p.Media[1].Characteristics[1] != CharactersticEasyToRead {
+		t.Errorf("characteristics = %q", p.Media[1].Characteristics)
+	}
+	score := 2.0
+	want := Variant{
+		URI:        "2160p.m3u8",
+		Bandwidth:  20000000,
+		Codecs:     []string{"hvc1.2.4.L153.b0", "ec-3"},
+		Audio:      "atmos",
+		Score:      &score,
+		VideoRange: VideoRangePQ,
+		AllowedCPC: []CPC{
+			{KeyFormat: "com.example.drm1", Labels: []string{"SMART-TV", "PC"}},
+			{KeyFormat: "com.apple.streamingkeydelivery", Labels: []string{"HW"}},
+		},
+		StableVariantID:    "2160p-pq",
+		SupplementalCodecs: []string{"dvh1.08.07/db4h"},
+		ReqVideoLayout:     []string{VideoLayoutStereo, VideoLayoutMono},
+	}
+	if !reflect.DeepEqual(p.Variants[0], want) {
+		t.Errorf("variant = %+v, want %+v", p.Variants[0], want)
+	}
+
+	sb := &strings.Builder{}
+	if err := Encode(sb, p); err != nil {
+		t.Fatal(err)
+	}
+	again, err := Decode(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, again) {
+		t.Errorf("master playlist attributes not preserved when encoding")
+		t.Log(sb)
+	}
+}
+
 func TestParseDuration(t *testing.T) {
 	want := 9967000 * time.Microsecond
 	it := item{typ: itemNumber, val: "9.967"}
@@ -214,3 +355,102 @@ func TestResolution(t *testing.T) {
 		}
 	}
 }
+
+func TestRoundTrip(t *testing.T) {
+	names, err := filepath.Glob("testdata/*.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// variants in this file have no bandwidth;
+	// it is only for testing the decoding of frame rates.
+	skip := map[string]bool{"frame_rate.m3u8": true}
+	for _, name := range names {
+		if skip[path.Base(name)] {
+			continue
+		}
+		t.Run(path.Base(name), func(t *testing.T) {
+			f, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			p, err := Decode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buf := &bytes.Buffer{}
+			if err := Encode(buf, p); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			encoded := buf.String()
+			again, err := Decode(buf)
+			if err != nil {
+				t.Fatalf("decode encoded playlist: %v", err)
+			}
+			if !reflect.DeepEqual(p, again) {
+				t.Errorf("playlist not preserved when encoding")
+				t.Log(encoded)
+			}
+		})
+	}
+}
+
+func TestDecodeAllTags(t *testing.T) {
+	f, err := os.Open("testdata/packager_media.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err := Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.DiscontinuitySequence != 3 {
+		t.Errorf("discontinuity sequence = %d, want %d", p.DiscontinuitySequence, 3)
+	}
+	start := StartPoint{Offset: -12500 * time.Millisecond, Precise: true}
+	if p.Start == nil || *p.Start != start {
+		t.Errorf("start = %+v, want %+v", p.Start, start)
+	}
+	if len(p.Segments) != 4 {
+		t.Fatalf("want 4 segments, got %d", len(p.Segments))
+	}
+	first := p.Segments[0]
+	if !first.Discontinuity || first.DateTime.IsZero() || first.Map == nil {
+		t.Errorf("first segment tags not decoded: %+v", first)
+	}
+	if first.Key == nil || !reflect.DeepEqual(first.Key.FormatVersions, []uint32{1}) {
+		t.Errorf("key format versions not decoded: %+v", first.Key)
+	}
+	for i, want := range []int{3200, 3200, 3200, 2800} {
+		if p.Segments[i].Bitrate != want {
+			t.Errorf("segment %d: bitrate = %d, want %d", i, p.Segments[i].Bitrate, want)
+		}
+	}
+	if !p.Segments[2].Gap || p.Segments[1].Gap {
+		t.Errorf("gap not decoded on third segment only")
+	}
+
+	f, err = os.Open("testdata/packager_master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	p, err = Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []SessionData{
+		{ID: "com.example.title", Value: "Big Buck Bunny", Language: "en"},
+		{ID: "com.example.chapters", URI: "chapters.json"},
+	}
+	if !reflect.DeepEqual(p.SessionData, data) {
+		t.Errorf("session data = %+v, want %+v", p.SessionData, data)
+	}
+	if p.SessionKey == nil || p.SessionKey.Method != EncryptMethodSampleAES {
+		t.Errorf("session key not decoded: %+v", p.SessionKey)
+	}
+	if len(p.Tags) > 0 {
+		t.Errorf("tags decoded as custom tags: %v", p.Tags)
+	}
+}
@@ -0,0 +1,320 @@
+package m3u8
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/untangledco/streaming/mpegts"
+)
+
+// probeInfo describes the media in a MPEG-TS segment.
+type probeInfo struct {
+	codecs     []string
+	resolution [2]int
+	frameRate  float32
+	// channels is the audio channel count, if known.
+	channels   int
+	sampleRate int
+}
+
+// probe reads a MPEG-TS stream from r and returns the codecs of its
+// first video and audio elementary streams, along with the video
+// resolution and frame rate. Only H.264 video and AAC, MP3 and AC-3
+// audio are recognised.
+func probe(r io.Reader) (*probeInfo, error) {
+	var info probeInfo
+	var video, audio []byte
+	var pts []uint64
+	videoPID, audioPID := mpegts.PacketNull, mpegts.PacketNull
+	sc := mpegts.NewScanner(r)
+	for sc.Scan() {
+		packet := sc.Packet()
+		if packet.PES != nil {
+			id := packet.PES.ID
+			if videoPID == mpegts.PacketNull && isVideoStream(id) {
+				videoPID = packet.PID
+			} else if audioPID == mpegts.PacketNull && (isAudioStream(id) || id == privateStream1) {
+				audioPID = packet.PID
+			}
+		}
+		var data []byte
+		if packet.PES != nil {
+			data = packet.PES.Data
+		} else {
+			data = packet.Payload
+		}
+		switch packet.PID {
+		case videoPID:
+			if packet.PES != nil && packet.PES.Header != nil && packet.PES.Header.Presentation != nil {
+				pts = append(pts, packet.PES.Header.Presentation.Ticks)
+			}
+			// Parameter sets are at the start of the stream;
+			// no need to hold on to every frame.
+			if len(video) < 4096 {
+				video = append(video, data...)
+			}
+		case audioPID:
+			if len(audio) < 4096 {
+				audio = append(audio, data...)
+			}
+		}
+	}
+	if sc.Err() != nil {
+		return nil, sc.Err()
+	}
+	if videoPID == mpegts.PacketNull && audioPID == mpegts.PacketNull {
+		return nil, fmt.Errorf("no audio or video streams found")
+	}
+
+	if videoPID != mpegts.PacketNull {
+		sps, err := findSPS(video)
+		if err != nil {
+			return nil, fmt.Errorf("video: %w", err)
+		}
+		info.codecs = append(info.codecs, fmt.Sprintf("avc1.%02x%02x%02x", sps.profile, sps.constraints, sps.level))
+		info.resolution = [2]int{sps.width, sps.height}
+		info.frameRate = frameRate(pts)
+	}
+	if audioPID != mpegts.PacketNull {
+		codec, channels, rate, err := audioCodec(audio)
+		if err != nil {
+			return nil, fmt.Errorf("audio: %w", err)
+		}
+		info.codecs = append(info.codecs, codec)
+		info.channels = channels
+		info.sampleRate = rate
+	}
+	return &info, nil
+}
+
+// privateStream1 is the PES stream id used to carry AC-3 audio.
+const privateStream1 = 0xbd
+
+// isAudioStream reports whether the PES stream id identifies
+// a MPEG audio stream.
+func isAudioStream(id byte) bool {
+	return id&0xe0 == 0xc0
+}
+
+// frameRate estimates the frame rate of video with the presentation
+// timestamps pts, rounded to 3 decimal places as in the FRAME-RATE
+// attribute.
+func frameRate(pts []uint64) float32 {
+	if len(pts) < 2 {
+		return 0
+	}
+	// Frames are stored in decode order, so sort first.
+	// Timestamps are relative to the first to allow for wrap around.
+	ticks := make([]int64, len(pts))
+	for i := range pts {
+		d := ptsSub(pts[i], pts[0])
+		if d >= 1<<32 {
+			// presented before the first frame.
+			ticks[i] = int64(d) - 1<<33
+		} else {
+			ticks[i] = int64(d)
+		}
+	}
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i] < ticks[j] })
+	span := ticks[len(ticks)-1] - ticks[0]
+	if span <= 0 {
+		return 0
+	}
+	fps := float64(len(ticks)-1) * ticksPerSecond / float64(span)
+	return float32(math.Round(fps*1000) / 1000)
+}
+
+// audioCodec returns the RFC 6381 codec identifier, channel count
+// and sample rate of the audio elementary stream starting with data.
+func audioCodec(data []byte) (codec string, channels, rate int, err error) {
+	switch {
+	case len(data) >= 7 && data[0] == 0xff && data[1]&0xf6 == 0xf0:
+		// ADTS; layer is always 0.
+		objectType := data[2]>>6 + 1
+		rates := []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+		if i := int(data[2] >> 2 & 0x0f); i < len(rates) {
+			rate = rates[i]
+		}
+		channels = int(data[2]&0x01)<<2 | int(data[3]>>6)
+		return fmt.Sprintf("mp4a.40.%d", objectType), channels, rate, nil
+	case len(data) >= 4 && data[0] == 0xff && data[1]&0xe0 == 0xe0:
+		// MPEG audio. Only layer III has a registered identifier.
+		if data[1]>>1&0x03 != 0x01 {
+			return "", 0, 0, fmt.Errorf("unsupported MPEG audio layer")
+		}
+		channels = 2
+		if data[3]>>6 == 0x03 {
+			channels = 1
+		}
+		return "mp4a.40.34", channels, 0, nil
+	case len(data) >= 6 && data[0] == 0x0b && data[1] == 0x77:
+		// bit stream identifiers above 10 are E-AC-3.
+		if data[5]>>3 > 10 {
+			return "ec-3", 0, 0, nil
+		}
+		return "ac-3", 0, 0, nil
+	}
+	return "", 0, 0, fmt.Errorf("unknown audio format")
+}
+
+// sps holds the fields of a H.264 sequence parameter set
+// needed for a master playlist.
+type sps struct {
+	profile     byte
+	constraints byte
+	level       byte
+	width       int
+	height      int
+}
+
+// findSPS finds and decodes the first sequence parameter set
+// in the H.264 byte stream data.
+func findSPS(data []byte) (*sps, error) {
+	for _, u := range nalUnits(data) {
+		nal := data[u[0]:u[1]]
+		if nal[0]&0x1f == 7 {
+			return parseSPS(unescapeNAL(nal[1:]))
+		}
+	}
+	return nil, fmt.Errorf("no H.264 sequence parameter set found")
+}
+
+// parseSPS parses the sequence parameter set b as specified in
+// ITU-T H.264 section 7.3.2.1.1.
+func parseSPS(b []byte) (*sps, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("short sequence parameter set")
+	}
+	s := &sps{profile: b[0], constraints: b[1], level: b[2]}
+	r := &bitReader{buf: b[3:]}
+	r.ue() // seq_parameter_set_id
+	chroma := uint(1)
+	switch s.profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chroma = r.ue()
+		if chroma == 3 {
+			r.bit() // separate_colour_plane_flag
+		}
+		r.ue()  // bit_depth_luma_minus8
+		r.ue()  // bit_depth_chroma_minus8
+		r.bit() // qpprime_y_zero_transform_bypass_flag
+		if r.bit() == 1 {
+			n := 8
+			if chroma == 3 {
+				n = 12
+			}
+			for i := 0; i < n; i++ {
+				if r.bit() == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				skipScalingList(r, size)
+			}
+		}
+	}
+	r.ue()          // log2_max_frame_num_minus4
+	switch r.ue() { // pic_order_cnt_type
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.bit() // delta_pic_order_always_zero_flag
+		r.se()  // offset_for_non_ref_pic
+		r.se()  // offset_for_top_to_bottom_field
+		n := r.ue()
+		for i := uint(0); i < n && r.err == nil; i++ {
+			r.se()
+		}
+	}
+	r.ue()  // max_num_ref_frames
+	r.bit() // gaps_in_frame_num_value_allowed_flag
+	width := int(r.ue()+1) * 16
+	mapUnits := int(r.ue() + 1)
+	frameMBsOnly := int(r.bit())
+	if frameMBsOnly == 0 {
+		r.bit() // mb_adaptive_frame_field_flag
+	}
+	r.bit() // direct_8x8_inference_flag
+	height := (2 - frameMBsOnly) * mapUnits * 16
+	if r.bit() == 1 {
+		// Cropping is in units depending on the chroma format;
+		// see table 6-1.
+		unitX, unitY := 1, 2-frameMBsOnly
+		switch chroma {
+		case 1:
+			unitX, unitY = 2, 2*(2-frameMBsOnly)
+		case 2:
+			unitX = 2
+		}
+		left, right := int(r.ue()), int(r.ue())
+		top, bottom := int(r.ue()), int(r.ue())
+		width -= unitX * (left + right)
+		height -= unitY * (top + bottom)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("parse sequence parameter set: %w", r.err)
+	}
+	s.width, s.height = width, height
+	return s, nil
+}
+
+func skipScalingList(r *bitReader, size int) {
+	last, next := 8, 8
+	for j := 0; j < size && r.err == nil; j++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// bitReader reads the bits of buf from the most significant bit.
+// Once the end of buf is reached, err is set and zeros are returned.
+type bitReader struct {
+	buf []byte
+	pos int // in bits
+	err error
+}
+
+func (r *bitReader) bit() uint {
+	if r.pos >= len(r.buf)*8 {
+		r.err = errors.New("unexpected end of data")
+		return 0
+	}
+	b := r.buf[r.pos/8] >> (7 - r.pos%8) & 1
+	r.pos++
+	return uint(b)
+}
+
+// ue reads an unsigned Exp-Golomb-coded integer.
+func (r *bitReader) ue() uint {
+	zeros := 0
+	for r.bit() == 0 && r.err == nil {
+		zeros++
+		if zeros > 31 {
+			r.err = errors.New("exp-golomb code too long")
+			return 0
+		}
+	}
+	var v uint
+	for i := 0; i < zeros; i++ {
+		v = v<<1 | r.bit()
+	}
+	return 1<<zeros - 1 + v
+}
+
+// se reads a signed Exp-Golomb-coded integer.
+func (r *bitReader) se() int {
+	k := r.ue()
+	if k%2 == 1 {
+		return int(k+1) / 2
+	}
+	return -int(k / 2)
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -25,92 +25,191 @@ const (
 	tagDateRange       = "#EXT-X-DATERANGE"
 )
 
-// parseSegment returns the next segment from items and the leading
+// parseSegment returns the next segment from l and the leading
 // item which indicated the start of a segment.
-func parseSegment(items chan item, leading item) (*Segment, error) {
-	// we've already read one item, so send everything through again
-	// starting with the leading item to maintain the lexer's original order.
-	segItems := make(chan item)
-	go func() {
-		segItems <- leading
-		for it := range items {
-			if it.typ == itemURL {
-				segItems <- it
-				close(segItems)
-				return
-			}
-			segItems <- it
-		}
-	}()
-
+// If the input holds only the partial segments of a segment yet
+// to be completed, the returned segment has an empty URI.
+// Errors decoding each tag are passed to fail along with the tag.
+// If fail returns nil, the tag is skipped and decoding continues.
+// Tags kept undecoded in the segment's Tags are passed to warn
+// along with the reason.
+func parseSegment(l *lexer, leading item, fail func(tag item, err error) error, warn func(tag item, err error)) (*Segment, error) {
 	var seg Segment
-	for it := range segItems {
+	for it := leading; it.typ != itemEOF; it = l.nextItem() {
 		switch it.typ {
 		case itemNewline:
 			continue
 		case itemError:
-			return nil, errors.New(it.val)
+			if err := fail(item{}, errors.New(it.val)); err != nil {
+				return nil, err
+			}
+			continue
 		case itemURL:
 			seg.URI = it.val
 			return &seg, nil
-		}
-
-		switch it.val {
-		case tagSegmentDuration:
-			it = <-segItems
-			if it.typ != itemAttrName && it.typ != itemNumber {
-				return nil, fmt.Errorf("parse segment duration: unexpected %s: want attribute name or number", it)
+		case itemTag:
+			if len(seg.Parts) > 0 && endsParts(it.val) {
+				// The parts belong to a segment still being
+				// produced; leave the tag for the playlist.
+				l.unread()
+				return &seg, nil
 			}
-			dur, err := parseSegmentDuration(it.val)
-			if err != nil {
-				return nil, fmt.Errorf("parse segment duration: %w", err)
+		}
+		err := parseSegmentTag(l, &seg, it)
+		var kept *keptError
+		if errors.As(err, &kept) {
+			warn(it, kept.err)
+		} else if err != nil {
+			if err := fail(it, err); err != nil {
+				return nil, err
 			}
-			seg.Duration = dur
+		}
+	}
+	if len(seg.Parts) > 0 {
+		return &seg, nil
+	}
+	return nil, fail(item{}, fmt.Errorf("no url"))
+}
 
-			// check for the optional segment title
-			it = <-segItems
-			if it.typ == itemNewline {
-				continue
-			} else if it.typ != itemComma {
-				return nil, fmt.Errorf("expected comma after segment duration, got %s", it)
-			}
-			it = <-segItems
-			seg.Title = it.val
+// endsParts reports whether the playlist tag name may follow the
+// partial segments of the last, incomplete segment of a playlist.
+func endsParts(name string) bool {
+	switch name {
+	case tagPreloadHint, tagRenditionReport, tagEndList:
+		return true
+	}
+	return false
+}
 
+// parseSegmentTag decodes the tag held in it into seg.
+func parseSegmentTag(l *lexer, seg *Segment, it item) error {
+	switch it.val {
+	case tagSegmentDuration:
+		it = l.nextItem()
+		if it.typ != itemAttrName && it.typ != itemNumber {
+			return fmt.Errorf("parse segment duration: unexpected %s: want attribute name or number", it)
+		}
+		dur, err := parseSegmentDuration(it.val)
+		if err != nil {
+			return fmt.Errorf("parse segment duration: %w", err)
+		}
+		seg.Duration = dur
 
-		case tagByteRange:
-			it = <-segItems
-			r, err := parseByteRange(it.val)
-			if err != nil {
-				return nil, fmt.Errorf("parse byte range: %w", err)
-			}
-			seg.Range = r
-		case tagDiscontinuity:
-			seg.Discontinuity = true
-		case tagKey:
-			key, err := parseKey(segItems)
-			if err != nil {
-				return nil, fmt.Errorf("parse key: %w", err)
-			}
-			seg.Key = &key
-		case tagMap:
-			m, err := parseMap(segItems)
-			if err != nil {
-				return nil, fmt.Errorf("parse map: %w", err)
-			}
-			seg.Map = &m
-		case tagDateTime:
-			it = <-segItems
-			t, err := time.Parse(rfc3339Milli, it.val)
-			if err != nil {
-				return nil, fmt.Errorf("bad date time tag: %w", err)
-			}
-			seg.DateTime = t
-		default:
-			return nil, fmt.Errorf("parsing %s unsupported", it)
+		// check for the optional segment title
+		it = l.nextItem()
+		if it.typ == itemNewline {
+			return nil
+		} else if it.typ != itemComma {
+			return fmt.Errorf("expected comma after segment duration, got %s", it)
+		}
+		it = l.nextItem()
+		seg.Title = it.val
+
+	case tagByteRange:
+		it = l.nextItem()
+		if it.typ == itemError {
+			return errors.New(it.val)
+		}
+		r, err := parseByteRange(it.val)
+		if err != nil {
+			return fmt.Errorf("parse byte range: %w", err)
+		}
+		seg.Range = r
+	case tagDiscontinuity:
+		seg.Discontinuity = true
+	case tagGap:
+		seg.Gap = true
+	case tagPart:
+		part, err := parsePart(l)
+		if err != nil {
+			return fmt.Errorf("parse part: %w", err)
+		}
+		seg.Parts = append(seg.Parts, part)
+	case tagBitrate:
+		it = l.nextItem()
+		if it.typ == itemError {
+			return errors.New(it.val)
+		}
+		n, err := strconv.Atoi(it.val)
+		if err != nil {
+			return fmt.Errorf("parse bitrate: %w", err)
+		}
+		if n <= 0 {
+			return fmt.Errorf("parse bitrate: non-positive bitrate %d", n)
+		}
+		seg.Bitrate = n
+	case tagKey:
+		key, err := parseKey(l)
+		if err != nil {
+			return fmt.Errorf("parse key: %w", err)
+		}
+		seg.Key = &key
+	case tagMap:
+		m, err := parseMap(l)
+		if err != nil {
+			return fmt.Errorf("parse map: %w", err)
+		}
+		seg.Map = &m
+	case tagDateTime:
+		it = l.nextItem()
+		t, err := time.Parse(rfc3339Milli, it.val)
+		if err != nil {
+			return fmt.Errorf("bad date time tag: %w", err)
+		}
+		seg.DateTime = t
+	case tagDateRange:
+		dr, err := parseDateRange(l)
+		if err != nil {
+			return fmt.Errorf("parse date range: %w", err)
+		}
+		seg.DateRanges = append(seg.DateRanges, *dr)
+	case tagCueOut, tagCueOutCont, tagCueIn, tagOATCLS, tagSCTE35:
+		tag, err := parseTag(l, it)
+		if err != nil {
+			return err
 		}
+		var cue Cue
+		if seg.Cue != nil {
+			cue = *seg.Cue
+		}
+		if err := cue.decodeTag(tag); err != nil {
+			// Keep cues we cannot decode, such as those with a
+			// bad checksum, so that they are written back as is.
+			seg.Tags = append(seg.Tags, tag)
+			return &keptError{fmt.Errorf("parse %s: %w", tag.Name, err)}
+		}
+		seg.Cue = &cue
+	default:
+		if it.typ != itemTag || lexedTags[it.val] {
+			return fmt.Errorf("parsing %s unsupported", it)
+		}
+		tag, err := parseTag(l, it)
+		if err != nil {
+			return err
+		}
+		seg.Tags = append(seg.Tags, tag)
 	}
-	return nil, fmt.Errorf("no url")
+	return nil
+}
+
+// keptError is returned by parseSegmentTag when a tag
+// which could not be decoded was kept in a segment's Tags.
+type keptError struct {
+	err error
+}
+
+func (e *keptError) Error() string { return e.err.Error() }
+
+// appendTags appends the text of each tag in ts to tags.
+func appendTags(tags []string, ts []Tag) ([]string, error) {
+	for _, tag := range ts {
+		b, err := tag.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, string(b))
+	}
+	return tags, nil
 }
 
 func parseSegmentDuration(s string) (time.Duration, error) {
@@ -152,9 +251,9 @@ func parseSegmentDuration(s string) (time.Duration, error) {
 	return time.Duration(microseconds) * time.Microsecond, nil
 }
 
-func parseKey(items chan item) (Key, error) {
+func parseKey(l *lexer) (Key, error) {
 	var key Key
-	for it := range items {
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
 		switch it.typ {
 		case itemError:
 			return key, errors.New(it.val)
@@ -167,23 +266,23 @@ func parseKey(items chan item) (Key, error) {
 				return Key{}, fmt.Errorf("expected attribute name, got %s", it.val)
 			}
 		}
-		v := <-items
+		v := l.nextItem()
 		if v.typ != itemEquals {
 			return key, fmt.Errorf("expected %q after %s, got %s", "=", it.typ, v)
 		}
 
 		switch it.val {
 		case "METHOD":
-			v = <-items
+			v = l.nextItem()
 			key.Method = parseEncryptMethod(v.val)
 			if key.Method == encryptMethodInvalid {
 				return key, fmt.Errorf("bad encrypt method %q", v.val)
 			}
 		case "URI":
-			v = <-items
+			v = l.nextItem()
 			key.URI = strings.Trim(v.val, `"`)
 		case "IV":
-			v = <-items
+			v = l.nextItem()
 			b, err := hex.DecodeString(strings.TrimPrefix(v.val, "0x"))
 			if err != nil {
 				return key, fmt.Errorf("parse initialisation vector: %w", err)
@@ -193,11 +292,11 @@ func parseKey(items chan item) (Key, error) {
 			}
 			copy(key.IV[:], b)
 		case "KEYFORMAT":
-			v = <-items
+			v = l.nextItem()
 			key.Format = strings.Trim(v.val, `"`)
 		case "KEYFORMATVERSIONS":
-			v = <-items
-			ss := strings.Split(v.val, "/")
+			v = l.nextItem()
+			ss := strings.Split(strings.Trim(v.val, `"`), "/")
 			key.FormatVersions = make([]uint32, len(ss))
 			for i := range ss {
 				n, err := strconv.Atoi(ss[i])
@@ -213,54 +312,156 @@ func parseKey(items chan item) (Key, error) {
 	return key, fmt.Errorf("unexpected end of tag")
 }
 
-func parseMap(items chan item) (Map, error) {
+func parseMap(l *lexer) (Map, error) {
 	var mmap Map
-	for it := range items {
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
 		switch it.typ {
 		case itemError:
 			return mmap, errors.New(it.val)
 		case itemNewline:
 			return mmap, nil
+		case itemComma:
+			continue
 		}
 		if it.typ != itemAttrName {
 			return Map{}, fmt.Errorf("unexpected %s %q", it.typ, it.val)
 		}
 		attr := it.val
-		it = <-items
+		it = l.nextItem()
 		if it.typ != itemEquals {
 			return Map{}, fmt.Errorf("expected %q after %s, got %q", "=", attr, it.val)
 		}
 
-		it = <-items
+		it = l.nextItem()
 		switch attr {
 		case "URI":
 			mmap.URI = strings.Trim(it.val, `"`)
 		case "BYTERANGE":
-			r, err := parseByteRange(it.val)
+			r, err := parseByteRange(strings.Trim(it.val, `"`))
 			if err != nil {
 				return Map{}, fmt.Errorf("parse byte range: %w", err)
 			}
 			mmap.ByteRange = r
 		default:
-			return Map{}, fmt.Errorf("unexpected attribute %q", it.val)
+			return Map{}, fmt.Errorf("unexpected attribute %q", attr)
 		}
 	}
 	return Map{}, fmt.Errorf("unexpected end of tag")
 }
 
-func writeSegments(w io.Writer, segments []Segment) (n int, err error) {
-	for i, seg := range segments {
-		b, err := seg.MarshalText()
-		if err != nil {
-			return n, fmt.Errorf("segment %d: %w", i, err)
+func parseDateRange(l *lexer) (*DateRange, error) {
+	var dr DateRange
+	// attributes of interstitials, which are only known to be so
+	// once CLASS is parsed.
+	var deferred [][2]string
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return nil, errors.New(it.val)
+		case itemNewline:
+			if dr.ID == "" {
+				return nil, fmt.Errorf("missing ID")
+			}
+			for _, a := range deferred {
+				if err := dr.parseInterstitialAttr(a[0], a[1]); err != nil {
+					return nil, fmt.Errorf("%s: %w", a[0], err)
+				}
+			}
+			return &dr, nil
+		case itemComma:
+			continue
+		}
+		if it.typ != itemAttrName {
+			return nil, fmt.Errorf("unexpected %s %q", it.typ, it.val)
+		}
+		attr := it.val
+		it = l.nextItem()
+		if it.typ != itemEquals {
+			return nil, fmt.Errorf("expected %q after %s, got %q", "=", attr, it.val)
+		}
+
+		it = l.nextItem()
+		if it.typ != itemString && it.typ != itemNumber {
+			return nil, fmt.Errorf("%s: unexpected %s %q", attr, it.typ, it.val)
+		}
+		var err error
+		switch attr {
+		case "ID":
+			dr.ID = strings.Trim(it.val, `"`)
+		case "CLASS":
+			dr.Class = strings.Trim(it.val, `"`)
+		case "START-DATE":
+			dr.Start, err = time.Parse(time.RFC3339Nano, strings.Trim(it.val, `"`))
+		case "END-DATE":
+			dr.End, err = time.Parse(time.RFC3339Nano, strings.Trim(it.val, `"`))
+		case "DURATION":
+			dr.Duration, err = parseSegmentDuration(it.val)
+		case "PLANNED-DURATION":
+			dr.Planned, err = parseSegmentDuration(it.val)
+		case "SCTE35-CMD":
+			dr.CueCommand, err = decodeSplice(it.val)
+		case "SCTE35-OUT":
+			dr.CueOut, err = decodeSplice(it.val)
+		case "SCTE35-IN":
+			dr.CueIn, err = decodeSplice(it.val)
+		case "END-ON-NEXT":
+			if it.val != "YES" {
+				err = fmt.Errorf("bad value %q", it.val)
+			}
+			dr.EndOnNext = true
+		default:
+			if isInterstitialAttr(attr) {
+				deferred = append(deferred, [2]string{attr, it.val})
+			} else if strings.HasPrefix(attr, "X-") {
+				if dr.Custom == nil {
+					dr.Custom = make(map[string]any)
+				}
+				dr.Custom[attr], err = parseCustomValue(it.val)
+			} else {
+				err = fmt.Errorf("unknown attribute")
+			}
 		}
-		nn, err := fmt.Fprintln(w, string(b))
-		n += nn
 		if err != nil {
-			return n, err
+			return nil, fmt.Errorf("%s: %w", attr, err)
+		}
+	}
+	return nil, fmt.Errorf("unexpected end of tag")
+}
+
+// parseInterstitialAttr parses an attribute of an interstitial into
+// dr.Interstitial if dr is of class ClassInterstitial, otherwise into
+// dr.Custom as for any other client-defined attribute.
+func (dr *DateRange) parseInterstitialAttr(attr, val string) error {
+	var err error
+	if dr.Class != ClassInterstitial {
+		if dr.Custom == nil {
+			dr.Custom = make(map[string]any)
 		}
+		dr.Custom[attr], err = parseCustomValue(val)
+		return err
+	}
+	if dr.Interstitial == nil {
+		dr.Interstitial = &Interstitial{}
+	}
+	return dr.Interstitial.parseAttribute(attr, val)
+}
+
+// parseCustomValue parses the value of a client-defined attribute
+// of a date range. See DateRange.Custom.
+func parseCustomValue(s string) (any, error) {
+	if strings.HasPrefix(s, `"`) {
+		return strings.Trim(s, `"`), nil
+	} else if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return hex.DecodeString(s[2:])
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func keysEqual(a, b *Key) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
-	return n, nil
+	return reflect.DeepEqual(*a, *b)
 }
 
 func (seg *Segment) MarshalText() ([]byte, error) {
@@ -274,16 +475,44 @@ func (seg *Segment) MarshalText() ([]byte, error) {
 	if seg.Discontinuity {
 		tags = append(tags, tagDiscontinuity)
 	}
-	if seg.DateRange != nil {
+	for i := range seg.DateRanges {
 		buf := &bytes.Buffer{}
-		if err := writeDateRange(buf, seg.DateRange); err != nil {
-			return nil, fmt.Errorf("write date range: %w", err)
+		if err := writeDateRange(buf, &seg.DateRanges[i]); err != nil {
+			return nil, fmt.Errorf("write date range %s: %w", seg.DateRanges[i].ID, err)
 		}
 		tags = append(tags, buf.String())
 	}
+	// Cue tags which could not be decoded are kept in Tags.
+	// Write them alongside the cue in their usual order:
+	// a splice in EXT-OATCLS-SCTE35 precedes the other cue tags.
+	var before, after, custom []Tag
+	for _, tag := range seg.Tags {
+		switch {
+		case tag.Name == tagOATCLS:
+			before = append(before, tag)
+		case cueTags[tag.Name]:
+			after = append(after, tag)
+		default:
+			custom = append(custom, tag)
+		}
+	}
+	tags, err := appendTags(tags, before)
+	if err != nil {
+		return nil, err
+	}
+	if seg.Cue != nil {
+		b, err := seg.Cue.MarshalText()
+		if err != nil {
+			return nil, fmt.Errorf("marshal cue: %w", err)
+		}
+		tags = append(tags, string(b))
+	}
+	if tags, err = appendTags(tags, after); err != nil {
+		return nil, err
+	}
 	if seg.Range != [2]int{0, 0} {
-		if seg.Range[0] >= seg.Range[1] {
-			return nil, fmt.Errorf("impossible range: offset (%d) must be smaller than next %d", seg.Range[0], seg.Range[1])
+		if seg.Range[0] <= 0 || seg.Range[1] < 0 {
+			return nil, fmt.Errorf("impossible range: length %d at offset %d", seg.Range[0], seg.Range[1])
 		}
 		tags = append(tags, fmt.Sprintf("%s:%s", tagByteRange, seg.Range))
 	}
@@ -293,9 +522,26 @@ func (seg *Segment) MarshalText() ([]byte, error) {
 	if seg.Map != nil {
 		tags = append(tags, seg.Map.String())
 	}
+	if seg.Bitrate < 0 {
+		return nil, fmt.Errorf("negative bitrate %d", seg.Bitrate)
+	} else if seg.Bitrate > 0 {
+		tags = append(tags, fmt.Sprintf("%s:%d", tagBitrate, seg.Bitrate))
+	}
+	if seg.Gap {
+		tags = append(tags, tagGap)
+	}
 	if !seg.DateTime.IsZero() {
 		tags = append(tags, fmt.Sprintf("%s:%s", tagDateTime, seg.DateTime.Format(rfc3339Milli)))
 	}
+	if tags, err = appendTags(tags, custom); err != nil {
+		return nil, err
+	}
+	for i, part := range seg.Parts {
+		if err := checkPart(part); err != nil {
+			return nil, fmt.Errorf("part %d: %w", i, err)
+		}
+		tags = append(tags, part.String())
+	}
 	us := seg.Duration / time.Microsecond
 	// we do .03f for the same precision as test-streams.mux.dev.
 	durTag := fmt.Sprintf("%s:%.03f", tagSegmentDuration, float32(us)/1e6)
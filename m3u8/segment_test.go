@@ -75,7 +75,8 @@ func TestMarshalBadSegments(t *testing.T) {
 	}{
 		{"empty", Segment{}},
 		{"no duration", Segment{URI: "video.ts"}},
-		{"impossible range", Segment{URI: "bbb.ts", Duration: 6 * time.Second, Range: ByteRange{999, 10}}},
+		{"empty range", Segment{URI: "bbb.ts", Duration: 6 * time.Second, Range: ByteRange{0, 10}}},
+		{"negative offset", Segment{URI: "bbb.ts", Duration: 6 * time.Second, Range: ByteRange{999, -1}}},
 	}
 	for _, tt := range cases {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,252 @@
+// Package steering implements HLS Content Steering servers.
+//
+// A multivariant playlist with an EXT-X-CONTENT-STEERING tag points
+// clients to a steering server. Clients periodically request a steering
+// manifest from the server, listing pathways such as CDNs in order of
+// preference. A Handler serves steering manifests, with a Policy
+// deciding the preferred pathways for each client.
+//
+// See m3u8.ContentSteering, and section 7 of
+// draft-pantos-hls-rfc8216bis.
+package steering
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/untangledco/streaming/cmcd"
+)
+
+// Manifest represents a steering manifest.
+type Manifest struct {
+	Version int
+	// TTL is how long clients should wait before reloading the manifest.
+	TTL time.Duration
+	// ReloadURI, if set, is the URI clients should use to reload
+	// the manifest.
+	ReloadURI string
+	// PathwayPriority lists pathway IDs in order of preference.
+	PathwayPriority []string
+	// PathwayClones declares new pathways cloned from existing ones.
+	PathwayClones []PathwayClone
+}
+
+type jsonManifest struct {
+	Version         int            `json:"VERSION"`
+	TTL             int            `json:"TTL"`
+	ReloadURI       string         `json:"RELOAD-URI,omitempty"`
+	PathwayPriority []string       `json:"PATHWAY-PRIORITY"`
+	PathwayClones   []PathwayClone `json:"PATHWAY-CLONES,omitempty"`
+}
+
+func (m Manifest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonManifest{
+		Version:         m.Version,
+		TTL:             int(m.TTL / time.Second),
+		ReloadURI:       m.ReloadURI,
+		PathwayPriority: m.PathwayPriority,
+		PathwayClones:   m.PathwayClones,
+	})
+}
+
+func (m *Manifest) UnmarshalJSON(b []byte) error {
+	var jm jsonManifest
+	if err := json.Unmarshal(b, &jm); err != nil {
+		return err
+	}
+	*m = Manifest{
+		Version:         jm.Version,
+		TTL:             time.Duration(jm.TTL) * time.Second,
+		ReloadURI:       jm.ReloadURI,
+		PathwayPriority: jm.PathwayPriority,
+		PathwayClones:   jm.PathwayClones,
+	}
+	return nil
+}
+
+// PathwayClone declares a pathway, ID, serving the same content as
+// the pathway BaseID but with URIs modified by URIReplacement.
+type PathwayClone struct {
+	BaseID         string         `json:"BASE-ID"`
+	ID             string         `json:"ID"`
+	URIReplacement URIReplacement `json:"URI-REPLACEMENT"`
+}
+
+// URIReplacement describes how to derive the URIs of a cloned pathway.
+type URIReplacement struct {
+	// Host replaces the host of each URI.
+	Host string `json:"HOST,omitempty"`
+	// Params are added to the query of each URI.
+	Params map[string]string `json:"PARAMS,omitempty"`
+	// PerVariantURIs maps stable variant IDs to replacement URIs.
+	PerVariantURIs map[string]string `json:"PER-VARIANT-URIS,omitempty"`
+	// PerRenditionURIs maps stable rendition IDs to replacement URIs.
+	PerRenditionURIs map[string]string `json:"PER-RENDITION-URIS,omitempty"`
+}
+
+// Request holds the information sent by a client requesting
+// a steering manifest.
+type Request struct {
+	// Pathway is the pathway currently used by the client,
+	// from the _HLS_pathway query parameter.
+	Pathway string
+	// Throughput is the client's measured throughput in bits per
+	// second, from the _HLS_throughput query parameter or otherwise
+	// from CMCD.
+	Throughput int
+	// CMCD holds Common Media Client Data sent with the request.
+	// It is nil if none was sent or the data could not be parsed.
+	CMCD *cmcd.Info
+	// HTTP is the underlying HTTP request.
+	HTTP *http.Request
+}
+
+// A Policy decides the pathways, in order of preference,
+// to send to a client.
+type Policy interface {
+	Steer(req *Request) ([]string, error)
+}
+
+// PolicyFunc is an adapter to use an ordinary function as a Policy.
+type PolicyFunc func(req *Request) ([]string, error)
+
+func (f PolicyFunc) Steer(req *Request) ([]string, error) {
+	return f(req)
+}
+
+// Handler serves steering manifests, with pathway priorities
+// decided by Policy.
+type Handler struct {
+	Policy Policy
+	// TTL is how long clients should wait before reloading the
+	// manifest. If zero, DefaultTTL is used.
+	TTL time.Duration
+	// ReloadURI is sent to clients as the manifest's RELOAD-URI.
+	ReloadURI string
+	// PathwayClones is sent in every manifest.
+	PathwayClones []PathwayClone
+}
+
+// DefaultTTL is the TTL recommended by the HLS specification.
+const DefaultTTL = 300 * time.Second
+
+// MimeType is the media type of steering manifests.
+const MimeType = "application/json"
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sreq, err := parseRequest(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	priority, err := h.Policy.Steer(sreq)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("steer: %v", err), http.StatusInternalServerError)
+		return
+	}
+	m := Manifest{
+		Version:         1,
+		TTL:             h.TTL,
+		ReloadURI:       h.ReloadURI,
+		PathwayPriority: priority,
+		PathwayClones:   h.PathwayClones,
+	}
+	if m.TTL == 0 {
+		m.TTL = DefaultTTL
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("marshal manifest: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", MimeType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Write(b)
+}
+
+func parseRequest(req *http.Request) (*Request, error) {
+	q := req.URL.Query()
+	sreq := &Request{
+		Pathway: q.Get("_HLS_pathway"),
+		HTTP:    req,
+	}
+	if q.Has("_HLS_throughput") {
+		n, err := strconv.Atoi(q.Get("_HLS_throughput"))
+		if err != nil {
+			return nil, fmt.Errorf("parse throughput: %w", err)
+		}
+		sreq.Throughput = n
+	}
+
+	var info cmcd.Info
+	var err error
+	if q.Has("CMCD") {
+		info, err = cmcd.ParseInfo(q.Get("CMCD"))
+	} else if hasCMCDHeader(req.Header) {
+		info, err = cmcd.ExtractInfo(req.Header)
+	} else {
+		return sreq, nil
+	}
+	if err != nil {
+		// Clients should still be steered if their CMCD is malformed.
+		return sreq, nil
+	}
+	sreq.CMCD = &info
+	if sreq.Throughput == 0 {
+		// CMCD throughput is in kilobits per second.
+		sreq.Throughput = info.Throughput * 1000
+	}
+	return sreq, nil
+}
+
+func hasCMCDHeader(header http.Header) bool {
+	for _, k := range []string{cmcd.HeaderRequest, cmcd.HeaderObject, cmcd.HeaderStatus, cmcd.HeaderSession} {
+		if header.Get(k) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Static is a Policy which sends the same pathways to every client.
+type Static []string
+
+func (s Static) Steer(req *Request) ([]string, error) {
+	return s, nil
+}
+
+// Failover is a Policy which steers clients away from their current
+// pathway when their throughput drops below MinThroughput.
+// Clients are otherwise sent Pathways unmodified.
+type Failover struct {
+	Pathways []string
+	// MinThroughput is in bits per second.
+	MinThroughput int
+}
+
+func (f *Failover) Steer(req *Request) ([]string, error) {
+	if req.Throughput == 0 || req.Throughput >= f.MinThroughput {
+		return f.Pathways, nil
+	}
+	priority := make([]string, 0, len(f.Pathways))
+	var demoted bool
+	for _, p := range f.Pathways {
+		if p == req.Pathway {
+			demoted = true
+			continue
+		}
+		priority = append(priority, p)
+	}
+	if demoted {
+		priority = append(priority, req.Pathway)
+	}
+	return priority, nil
+}
@@ -0,0 +1,81 @@
+package steering
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	h := &Handler{
+		Policy: &Failover{Pathways: []string{"CDN-A", "CDN-B", "CDN-C"}, MinThroughput: 5e6},
+		TTL:    time.Minute,
+		PathwayClones: []PathwayClone{
+			{BaseID: "CDN-A", ID: "CDN-D", URIReplacement: URIReplacement{Host: "d.example.com"}},
+		},
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	var cases = []struct {
+		name   string
+		query  string
+		header http.Header
+		want   []string
+	}{
+		{"no client info", "", nil, []string{"CDN-A", "CDN-B", "CDN-C"}},
+		{"fast client", "?_HLS_pathway=CDN-A&_HLS_throughput=8000000", nil, []string{"CDN-A", "CDN-B", "CDN-C"}},
+		{"slow client", "?_HLS_pathway=CDN-A&_HLS_throughput=1000000", nil, []string{"CDN-B", "CDN-C", "CDN-A"}},
+		{"slow client by cmcd query", "?_HLS_pathway=CDN-B&CMCD=mtp%3D1000", nil, []string{"CDN-A", "CDN-C", "CDN-B"}},
+		{
+			"slow client by cmcd header",
+			"?_HLS_pathway=CDN-A",
+			http.Header{"Cmcd-Request": []string{"mtp=1000"}},
+			[]string{"CDN-B", "CDN-C", "CDN-A"},
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("response status %s", resp.Status)
+			}
+			if resp.Header.Get("Content-Type") != MimeType {
+				t.Errorf("content type = %s, want %s", resp.Header.Get("Content-Type"), MimeType)
+			}
+			var m Manifest
+			if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+				t.Fatalf("decode manifest: %v", err)
+			}
+			if m.Version != 1 || m.TTL != time.Minute || len(m.PathwayClones) != 1 {
+				t.Errorf("unexpected manifest %+v", m)
+			}
+			if !reflect.DeepEqual(m.PathwayPriority, tt.want) {
+				t.Errorf("pathway priority = %v, want %v", m.PathwayPriority, tt.want)
+			}
+		})
+	}
+
+	resp, err := http.Get(srv.URL + "?_HLS_throughput=fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("response status for bad throughput = %s, want %d", resp.Status, http.StatusBadRequest)
+	}
+}
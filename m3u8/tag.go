@@ -0,0 +1,100 @@
+package m3u8
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Tag represents a tag not otherwise supported by this package,
+// such as a vendor-specific extension.
+// Playlists returned from Decode hold any such tags
+// so that they may be written back by Encode.
+type Tag struct {
+	// Name is the name of the tag including the leading "#",
+	// for example "#EXT-X-ASSET".
+	Name string
+	// Value holds the raw text following the colon after Name, if any.
+	Value string
+	// Decoded holds the value returned by the TagDecoder registered
+	// for Name, if any. If Decoded implements encoding.TextMarshaler,
+	// its marshalled text is written in place of Value.
+	Decoded any
+}
+
+func (t Tag) MarshalText() ([]byte, error) {
+	if !strings.HasPrefix(t.Name, tagStart) {
+		return nil, fmt.Errorf("tag name %q missing prefix %s", t.Name, tagStart)
+	}
+	value := t.Value
+	if m, ok := t.Decoded.(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return nil, fmt.Errorf("marshal %s: %w", t.Name, err)
+		}
+		value = string(b)
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return nil, fmt.Errorf("%s: newline in value", t.Name)
+	}
+	if value == "" {
+		return []byte(t.Name), nil
+	}
+	return []byte(t.Name + ":" + value), nil
+}
+
+// TagDecoder decodes the raw value of a custom tag.
+type TagDecoder func(value string) (any, error)
+
+var (
+	decodersMu  sync.RWMutex
+	tagDecoders = make(map[string]TagDecoder)
+)
+
+// RegisterTag registers dec to decode the value of every tag named
+// name, such as "#EXT-X-ASSET", found by Decode.
+// The decoded value is stored in the Decoded field of the
+// corresponding Tag. RegisterTag is typically called from an init
+// function. It panics if name is a tag already supported by this package.
+func RegisterTag(name string, dec TagDecoder) {
+	if lexedTags[name] || cueTags[name] {
+		panic("m3u8: RegisterTag of supported tag " + name)
+	}
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	tagDecoders[name] = dec
+}
+
+func tagDecoder(name string) TagDecoder {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	return tagDecoders[name]
+}
+
+// parseTag returns the custom tag whose name is held in leading.
+func parseTag(l *lexer, leading item) (Tag, error) {
+	tag := Tag{Name: leading.val}
+	for it := l.nextItem(); it.typ != itemEOF; it = l.nextItem() {
+		switch it.typ {
+		case itemError:
+			return tag, errors.New(it.val)
+		case itemString:
+			tag.Value = it.val
+			continue
+		case itemNewline:
+		default:
+			return tag, fmt.Errorf("unexpected %s", it)
+		}
+		if dec := tagDecoder(tag.Name); dec != nil {
+			v, err := dec(tag.Value)
+			if err != nil {
+				return tag, fmt.Errorf("decode %s: %w", tag.Name, err)
+			}
+			tag.Decoded = v
+		}
+		return tag, nil
+	}
+	return tag, fmt.Errorf("unexpected end of tag")
+}
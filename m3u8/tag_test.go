@@ -0,0 +1,142 @@
+package m3u8
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const testCustomTags = `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-YOSPACE-ANALYTICS-URL:"https://example.com/analytics;jsessionid=1234"
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000
+001.ts
+#EXT-X-ASSET:CAID=0x0000000020FB6501
+#EXT-X-AD-DURATION:15
+#EXTINF:10.000
+002.ts
+#EXTINF:5.000
+#EXT-X-AD-PROGRESS:ElapsedTime=10,Duration=15
+003.ts
+#EXT-X-AD-END
+#EXTINF:10.000
+004.ts
+#EXT-X-ENDLIST
+#EXT-X-SOME-OTHER-TAG
+`
+
+func TestCustomTags(t *testing.T) {
+	p, err := Decode(strings.NewReader(testCustomTags))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Tag{
+		{Name: "#EXT-X-YOSPACE-ANALYTICS-URL", Value: `"https://example.com/analytics;jsessionid=1234"`},
+	}
+	if !reflect.DeepEqual(p.Tags, want) {
+		t.Errorf("playlist tags = %v, want %v", p.Tags, want)
+	}
+	trailing := []Tag{{Name: "#EXT-X-SOME-OTHER-TAG"}}
+	if !reflect.DeepEqual(p.TrailingTags, trailing) {
+		t.Errorf("trailing tags = %v, want %v", p.TrailingTags, trailing)
+	}
+	segTags := [][]Tag{
+		nil,
+		{
+			{Name: "#EXT-X-ASSET", Value: "CAID=0x0000000020FB6501"},
+			{Name: "#EXT-X-AD-DURATION", Value: "15"},
+		},
+		{{Name: "#EXT-X-AD-PROGRESS", Value: "ElapsedTime=10,Duration=15"}},
+		{{Name: "#EXT-X-AD-END"}},
+	}
+	for i, seg := range p.Segments {
+		if !reflect.DeepEqual(seg.Tags, segTags[i]) {
+			t.Errorf("segment %d tags = %v, want %v", i, seg.Tags, segTags[i])
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.String()
+	again, err := Decode(buf)
+	if err != nil {
+		t.Fatalf("decode encoded playlist: %v", err)
+	}
+	if !reflect.DeepEqual(p, again) {
+		t.Errorf("custom tags not preserved when encoding")
+		t.Log(encoded)
+	}
+}
+
+func TestTrailingTags(t *testing.T) {
+	const s = `#EXTM3U
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXTINF:10.000
+001.ts
+#EXT-X-FOO:bar
+#EXT-X-ENDLIST
+`
+	p, err := Decode(strings.NewReader(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(buf.String(), "001.ts\n#EXT-X-FOO:bar\n#EXT-X-ENDLIST\n") {
+		t.Errorf("trailing tag moved when encoding:\n%s", buf)
+	}
+}
+
+// adDuration is an example of a custom tag value.
+type adDuration float64
+
+func (c adDuration) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatFloat(float64(c), 'f', -1, 64)), nil
+}
+
+func TestRegisterTag(t *testing.T) {
+	RegisterTag("#EXT-X-AD-DURATION", func(s string) (any, error) {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse duration: %w", err)
+		}
+		return adDuration(f), nil
+	})
+	defer func() {
+		decodersMu.Lock()
+		delete(tagDecoders, "#EXT-X-AD-DURATION")
+		decodersMu.Unlock()
+	}()
+
+	p, err := Decode(strings.NewReader(testCustomTags))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag := p.Segments[1].Tags[1]
+	if tag.Decoded != adDuration(15) {
+		t.Fatalf("decoded value of %s = %v, want %v", tag.Name, tag.Decoded, adDuration(15))
+	}
+	tag.Decoded = adDuration(30.5)
+	b, err := tag.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "#EXT-X-AD-DURATION:30.5" {
+		t.Errorf("marshalled tag = %s, want %s", b, "#EXT-X-AD-DURATION:30.5")
+	}
+
+	bad := strings.Replace(testCustomTags, "#EXT-X-AD-DURATION:15", "#EXT-X-AD-DURATION:abc", 1)
+	if _, err := Decode(strings.NewReader(bad)); err == nil {
+		t.Errorf("nil error decoding playlist with bad custom tag value")
+	}
+}
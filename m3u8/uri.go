@@ -0,0 +1,156 @@
+package m3u8
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// URIKind identifies which part of a playlist a URI belongs to.
+type URIKind uint8
+
+const (
+	URISegment URIKind = iota
+	URIKey
+	URIMap
+	URIPart
+	URIPreloadHint
+	URIRenditionReport
+	// URIRendition is the URI of an EXT-X-MEDIA tag.
+	URIRendition
+	URIVariant
+	URIIFrame
+	URISessionData
+	URISessionKey
+	// URISteering is the server URI of the EXT-X-CONTENT-STEERING tag.
+	URISteering
+	// URIAsset and URIAssetList are the URIs of an interstitial.
+	URIAsset
+	URIAssetList
+)
+
+func (k URIKind) String() string {
+	switch k {
+	case URISegment:
+		return "segment"
+	case URIKey:
+		return "key"
+	case URIMap:
+		return "map"
+	case URIPart:
+		return "part"
+	case URIPreloadHint:
+		return "preload hint"
+	case URIRenditionReport:
+		return "rendition report"
+	case URIRendition:
+		return "rendition"
+	case URIVariant:
+		return "variant"
+	case URIIFrame:
+		return "i-frame variant"
+	case URISessionData:
+		return "session data"
+	case URISessionKey:
+		return "session key"
+	case URISteering:
+		return "content steering"
+	case URIAsset:
+		return "asset"
+	case URIAssetList:
+		return "asset list"
+	}
+	return "unknown"
+}
+
+// Rewrite replaces every URI in p with the value returned by fn,
+// such as to point clients at a proxy or to sign each URI.
+// Empty URIs are left unchanged and are not passed to fn.
+// Keys and maps shared by many segments are rewritten only once.
+func (p *Playlist) Rewrite(fn func(kind URIKind, uri string) string) {
+	rewrite := func(kind URIKind, s *string) {
+		if *s != "" {
+			*s = fn(kind, *s)
+		}
+	}
+	rewriteDateRanges := func(ranges []DateRange) {
+		for i := range ranges {
+			if in := ranges[i].Interstitial; in != nil {
+				rewrite(URIAsset, &in.AssetURI)
+				rewrite(URIAssetList, &in.AssetList)
+			}
+		}
+	}
+	// Decode sets the same Key on consecutive segments.
+	keys := make(map[*Key]bool)
+	maps := make(map[*Map]bool)
+
+	for i := range p.Segments {
+		seg := &p.Segments[i]
+		rewrite(URISegment, &seg.URI)
+		if seg.Key != nil && !keys[seg.Key] {
+			rewrite(URIKey, &seg.Key.URI)
+			keys[seg.Key] = true
+		}
+		if seg.Map != nil && !maps[seg.Map] {
+			rewrite(URIMap, &seg.Map.URI)
+			maps[seg.Map] = true
+		}
+		for j := range seg.Parts {
+			rewrite(URIPart, &seg.Parts[j].URI)
+		}
+		rewriteDateRanges(seg.DateRanges)
+	}
+	rewriteDateRanges(p.DateRanges)
+	for i := range p.Parts {
+		rewrite(URIPart, &p.Parts[i].URI)
+	}
+	for i := range p.PreloadHints {
+		hint := &p.PreloadHints[i]
+		if hint.Type == PreloadMap {
+			rewrite(URIMap, &hint.URI)
+		} else {
+			rewrite(URIPreloadHint, &hint.URI)
+		}
+	}
+	for i := range p.RenditionReports {
+		rewrite(URIRenditionReport, &p.RenditionReports[i].URI)
+	}
+	for i := range p.Media {
+		rewrite(URIRendition, &p.Media[i].URI)
+	}
+	for i := range p.Variants {
+		rewrite(URIVariant, &p.Variants[i].URI)
+	}
+	for i := range p.IFrames {
+		rewrite(URIIFrame, &p.IFrames[i].URI)
+	}
+	for i := range p.SessionData {
+		rewrite(URISessionData, &p.SessionData[i].URI)
+	}
+	if p.SessionKey != nil {
+		rewrite(URISessionKey, &p.SessionKey.URI)
+	}
+	if p.ContentSteering != nil {
+		rewrite(URISteering, &p.ContentSteering.ServerURI)
+	}
+}
+
+// ResolveReferences resolves every relative URI in p against base,
+// the URL from which p was retrieved, as specified in RFC 3986
+// section 5.2. Absolute URIs are left unchanged.
+// Variable references should first be substituted; see Resolve.
+func (p *Playlist) ResolveReferences(base *url.URL) error {
+	var err error
+	p.Rewrite(func(kind URIKind, uri string) string {
+		if err != nil {
+			return uri
+		}
+		ref, perr := url.Parse(uri)
+		if perr != nil {
+			err = fmt.Errorf("%s: %w", kind, perr)
+			return uri
+		}
+		return base.ResolveReference(ref).String()
+	})
+	return err
+}
@@ -0,0 +1,102 @@
+package m3u8
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testRelative = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:4
+#EXT-X-KEY:METHOD=AES-128,URI="../keys/1.key"
+#EXT-X-MAP:URI="init.mp4"
+#EXTINF:4.000
+001.mp4
+#EXTINF:4.000
+/live/002.mp4
+#EXTINF:4.000
+https://cdn.example.com/003.mp4
+#EXT-X-PRELOAD-HINT:TYPE=PART,URI="004.0.mp4"
+`
+
+func TestResolveReferences(t *testing.T) {
+	p, err := Decode(strings.NewReader(testRelative))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := url.Parse("https://origin.example.com/live/stream/media.m3u8?token=abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ResolveReferences(base); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"https://origin.example.com/live/stream/001.mp4",
+		"https://origin.example.com/live/002.mp4",
+		"https://cdn.example.com/003.mp4",
+	}
+	for i, seg := range p.Segments {
+		if seg.URI != want[i] {
+			t.Errorf("segment %d: got %s, want %s", i, seg.URI, want[i])
+		}
+	}
+	// the key applies to every segment, but must only be resolved once.
+	key := "https://origin.example.com/live/keys/1.key"
+	if p.Segments[2].Key == nil || p.Segments[2].Key.URI != key {
+		t.Errorf("key: got %v, want URI %s", p.Segments[2].Key, key)
+	}
+	mmap := "https://origin.example.com/live/stream/init.mp4"
+	if p.Segments[0].Map.URI != mmap {
+		t.Errorf("map: got %s, want %s", p.Segments[0].Map.URI, mmap)
+	}
+	hint := "https://origin.example.com/live/stream/004.0.mp4"
+	if p.PreloadHints[0].URI != hint {
+		t.Errorf("preload hint: got %s, want %s", p.PreloadHints[0].URI, hint)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	p := &Playlist{
+		Segments: []Segment{
+			{
+				URI:   "1.ts",
+				Key:   &Key{Method: EncryptMethodAES128, URI: "key"},
+				Map:   &Map{URI: "init.mp4"},
+				Parts: []Part{{URI: "1.0.ts"}},
+				DateRanges: []DateRange{{
+					Class:        ClassInterstitial,
+					Interstitial: &Interstitial{AssetList: "assets.json"},
+				}},
+			},
+		},
+		Media:            []Rendition{{URI: "audio.m3u8"}},
+		Variants:         []Variant{{URI: "low.m3u8"}},
+		IFrames:          []IFrameInfo{{URI: "iframes.m3u8"}},
+		SessionData:      []SessionData{{ID: "com.example", URI: "data.json"}},
+		SessionKey:       &Key{Method: EncryptMethodAES128, URI: "session"},
+		ContentSteering:  &ContentSteering{ServerURI: "steer.json"},
+		RenditionReports: []RenditionReport{{URI: "other.m3u8"}},
+	}
+	seen := make(map[URIKind]string)
+	p.Rewrite(func(kind URIKind, uri string) string {
+		if _, ok := seen[kind]; ok {
+			t.Errorf("%s rewritten more than once", kind)
+		}
+		seen[kind] = uri
+		return "/proxy/" + uri
+	})
+	kinds := []URIKind{URISegment, URIKey, URIMap, URIPart, URIAssetList, URIRendition, URIVariant, URIIFrame, URISessionData, URISessionKey, URISteering, URIRenditionReport}
+	for _, k := range kinds {
+		if _, ok := seen[k]; !ok {
+			t.Errorf("%s not rewritten", k)
+		}
+	}
+	if _, ok := seen[URIAsset]; ok {
+		t.Errorf("empty asset URI rewritten")
+	}
+	if p.Variants[0].URI != "/proxy/low.m3u8" {
+		t.Errorf("variant URI not replaced: %s", p.Variants[0].URI)
+	}
+}
@@ -4,13 +4,71 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/untangledco/streaming/scte35"
 )
 
+// Encode writes the playlist p to w.
+// To write very large playlists one segment at a time,
+// use an Encoder instead.
 func Encode(w io.Writer, p *Playlist) error {
+	enc := NewEncoder(w)
+	if err := enc.WriteHeader(p); err != nil {
+		return err
+	}
+	for i := range p.Segments {
+		if err := enc.WriteSegment(&p.Segments[i]); err != nil {
+			return fmt.Errorf("write segments: segment %d: %w", i, err)
+		}
+	}
+	return enc.Close()
+}
+
+// An Encoder writes a playlist to an output stream one segment at a time.
+// A playlist is written by a call to WriteHeader,
+// followed by a call to WriteSegment for each segment, then Close.
+type Encoder struct {
+	w io.Writer
+	// the key of the previously written segment.
+	key     *Key
+	bitrate int
+	header  bool
+	end     bool
+	// tags following the last segment.
+	trailer []Tag
+	ranges  []DateRange
+	parts   []Part
+	hints   []PreloadHint
+	reports []RenditionReport
+}
+
+// NewEncoder returns a new Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// WriteHeader writes all of p except its segments and the tags
+// following them: the EXT-X-ENDLIST tag, written by Close if p.End
+// is true, and p's TrailingTags, DateRanges, Parts, PreloadHints and
+// RenditionReports, also written by Close.
+// Any segments in p are ignored.
+func (e *Encoder) WriteHeader(p *Playlist) error {
+	if e.header {
+		return fmt.Errorf("header already written")
+	}
+	e.header = true
+	e.end = p.End
+	e.trailer = p.TrailingTags
+	e.ranges = p.DateRanges
+	e.parts = p.Parts
+	e.hints = p.PreloadHints
+	e.reports = p.RenditionReports
+
+	w := e.w
 	fmt.Fprintln(w, "#EXTM3U")
 	if p.Version > 0 {
 		fmt.Fprintf(w, "%s:%d\n", tagVersion, p.Version)
@@ -21,13 +79,46 @@ func Encode(w io.Writer, p *Playlist) error {
 	if p.IndependentSegments {
 		fmt.Fprintln(w, tagIndependentSegments)
 	}
+	if p.IFramesOnly {
+		fmt.Fprintln(w, tagIFramesOnly)
+	}
+	if p.Start != nil {
+		fmt.Fprintln(w, p.Start)
+	}
+	for _, def := range p.Defines {
+		if !isVariableName(def.Name) {
+			return fmt.Errorf("define: invalid variable name %q", def.Name)
+		}
+		fmt.Fprintln(w, def)
+	}
 	if p.TargetDuration > 0 {
 		fmt.Fprintf(w, "%s:%d\n", tagTargetDuration, p.TargetDuration/time.Second)
 	}
+	if p.ServerControl != nil {
+		fmt.Fprintln(w, p.ServerControl)
+	}
+	if p.PartTarget > 0 {
+		fmt.Fprintf(w, "%s:PART-TARGET=%s\n", tagPartInf, formatSeconds(p.PartTarget))
+	}
+	// Write custom tags before another playlist tag
+	// so that they are not decoded as belonging to the first segment.
+	for _, tag := range p.Tags {
+		b, err := tag.MarshalText()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(b))
+	}
 	fmt.Fprintf(w, "%s:%d\n", tagMediaSequence, p.Sequence)
+	if p.DiscontinuitySequence > 0 {
+		fmt.Fprintf(w, "%s:%d\n", tagDiscontinuitySeq, p.DiscontinuitySequence)
+	}
 
-	if _, err := writeSegments(w, p.Segments); err != nil {
-		return fmt.Errorf("write segments: %w", err)
+	if p.ContentSteering != nil {
+		if p.ContentSteering.ServerURI == "" {
+			return fmt.Errorf("content steering: empty server URI")
+		}
+		fmt.Fprintln(w, p.ContentSteering)
 	}
 
 	for _, r := range p.Media {
@@ -41,15 +132,130 @@ func Encode(w io.Writer, p *Playlist) error {
 			return fmt.Errorf("write variant %d: %w", i, err)
 		}
 	}
+	for i, f := range p.IFrames {
+		if _, err := writeIFrameInfo(w, &f); err != nil {
+			return fmt.Errorf("write i-frame variant %d: %w", i, err)
+		}
+	}
 
 	for i, sd := range p.SessionData {
 		if _, err := writeSessionData(w, sd); err != nil {
 			return fmt.Errorf("write session data %d: %w", i, err)
 		}
 	}
+	if p.SessionKey != nil {
+		if p.SessionKey.Method == EncryptMethodNone {
+			return fmt.Errorf("session key: method must not be %s", EncryptMethodNone)
+		}
+		// same as EXT-X-KEY, only with a different name.
+		fmt.Fprintln(w, tagSessionKey+strings.TrimPrefix(p.SessionKey.String(), tagKey))
+	}
+	if p.Skip != nil {
+		if p.Skip.Segments < 0 {
+			return fmt.Errorf("skip: negative skipped segments %d", p.Skip.Segments)
+		}
+		fmt.Fprintln(w, p.Skip)
+	}
+	return nil
+}
+
+// WriteSegment writes seg. The EXT-X-KEY tag is only written
+// if seg.Key differs from the key of the previously written segment.
+func (e *Encoder) WriteSegment(seg *Segment) error {
+	if !e.header {
+		return fmt.Errorf("header not written")
+	}
+	s := *seg
+	// Only write the key when it changes from the previous segment.
+	current := s.Key
+	if current != nil && current.Method == EncryptMethodNone {
+		current = nil
+	}
+	if keysEqual(current, e.key) {
+		s.Key = nil
+	} else if current == nil {
+		s.Key = &Key{Method: EncryptMethodNone}
+	}
+	e.key = current
+
+	// Likewise for the bitrate.
+	if s.Bitrate == e.bitrate {
+		s.Bitrate = 0
+	}
+	e.bitrate = seg.Bitrate
+
+	b, err := s.MarshalText()
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = e.w.Write(b)
+	return err
+}
+
+// Close writes the trailing tags, date ranges, partial segments, preload
+// hints and rendition reports of the playlist passed to WriteHeader,
+// followed by the EXT-X-ENDLIST tag if the playlist has End set.
+// It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if !e.header {
+		return fmt.Errorf("header not written")
+	}
+	for _, tag := range e.trailer {
+		b, err := tag.MarshalText()
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(e.w, string(b)); err != nil {
+			return err
+		}
+	}
+	for i := range e.ranges {
+		if err := writeDateRange(e.w, &e.ranges[i]); err != nil {
+			return fmt.Errorf("write date range %s: %w", e.ranges[i].ID, err)
+		}
+	}
+	for i, part := range e.parts {
+		if err := checkPart(part); err != nil {
+			return fmt.Errorf("part %d: %w", i, err)
+		}
+		if _, err := fmt.Fprintln(e.w, part); err != nil {
+			return err
+		}
+	}
+	for _, h := range e.hints {
+		if h.URI == "" {
+			return fmt.Errorf("preload hint: empty URI")
+		}
+		if _, err := fmt.Fprintln(e.w, h); err != nil {
+			return err
+		}
+	}
+	for _, r := range e.reports {
+		if r.URI == "" {
+			return fmt.Errorf("rendition report: empty URI")
+		}
+		if _, err := fmt.Fprintln(e.w, r); err != nil {
+			return err
+		}
+	}
+	if e.end {
+		if _, err := fmt.Fprintln(e.w, tagEndList); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if p.End {
-		fmt.Fprintln(w, tagEndList)
+func checkPart(p Part) error {
+	if p.URI == "" {
+		return fmt.Errorf("empty URI")
+	}
+	if p.Duration <= 0 {
+		return fmt.Errorf("non-positive duration %s", p.Duration)
+	}
+	if p.Range != (ByteRange{}) && (p.Range[0] <= 0 || p.Range[1] < 0) {
+		return fmt.Errorf("impossible range: length %d at offset %d", p.Range[0], p.Range[1])
 	}
 	return nil
 }
@@ -61,9 +267,40 @@ func writeVariant(w io.Writer, v *Variant) (n int, err error) {
 	if v.URI == "" {
 		return 0, fmt.Errorf("empty URI")
 	}
+	if v.Score != nil && *v.Score < 0 {
+		return 0, fmt.Errorf("negative score %v", *v.Score)
+	}
+	if v.VideoRange > VideoRangeHLG {
+		return 0, fmt.Errorf("unknown video range %d", v.VideoRange)
+	}
+	for _, cpc := range v.AllowedCPC {
+		if cpc.KeyFormat == "" || len(cpc.Labels) == 0 {
+			return 0, fmt.Errorf("allowed cpc %q: empty key format or labels", cpc)
+		}
+	}
+	if v.StableVariantID != "" && !isStableID(v.StableVariantID) {
+		return 0, fmt.Errorf("invalid stable variant id %q", v.StableVariantID)
+	}
+	for _, layout := range v.ReqVideoLayout {
+		if layout != VideoLayoutStereo && layout != VideoLayoutMono {
+			return 0, fmt.Errorf("unknown video channel specifier %q", layout)
+		}
+	}
 	return fmt.Fprintln(w, v)
 }
 
+func writeIFrameInfo(w io.Writer, f *IFrameInfo) (n int, err error) {
+	if f.FrameRate > 0 || f.Audio != "" || f.Subtitles != "" || f.ClosedCaptions != "" {
+		return 0, fmt.Errorf("frame rate, audio, subtitles and closed captions must be unset")
+	}
+	// check remaining attributes as for any other variant.
+	v := Variant(*f)
+	if _, err := writeVariant(io.Discard, &v); err != nil {
+		return 0, err
+	}
+	return fmt.Fprintln(w, f)
+}
+
 func writeDateRange(w io.Writer, dr *DateRange) error {
 	if dr.ID == "" {
 		return fmt.Errorf("empty ID")
@@ -80,9 +317,44 @@ func writeDateRange(w io.Writer, dr *DateRange) error {
 	if dr.Class != "" {
 		attrs = append(attrs, fmt.Sprintf("CLASS=%q", dr.Class))
 	}
-	// TODO(otl): dr.Duration, dr.Planned. Differentiate zero value and user-set zero.
-	// TODO(otl): dr.Custom.
-	// TODO(otl): dr.CueCommand, when to write this versuse cuein, cueout.
+	if dr.Duration > 0 {
+		attrs = append(attrs, "DURATION="+formatSeconds(dr.Duration))
+	}
+	if dr.Planned > 0 {
+		attrs = append(attrs, "PLANNED-DURATION="+formatSeconds(dr.Planned))
+	}
+	if dr.Interstitial != nil {
+		if dr.Class != ClassInterstitial {
+			return fmt.Errorf("interstitial with class %q, want %q", dr.Class, ClassInterstitial)
+		}
+		a, err := dr.Interstitial.attributes()
+		if err != nil {
+			return fmt.Errorf("interstitial: %w", err)
+		}
+		attrs = append(attrs, a...)
+	}
+	names := make([]string, 0, len(dr.Custom))
+	for name := range dr.Custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !strings.HasPrefix(name, "X-") || (dr.Class == ClassInterstitial && isInterstitialAttr(name)) {
+			return fmt.Errorf("invalid custom attribute name %s", name)
+		}
+		v, err := formatCustomValue(dr.Custom[name])
+		if err != nil {
+			return fmt.Errorf("custom attribute %s: %w", name, err)
+		}
+		attrs = append(attrs, name+"="+v)
+	}
+	if dr.CueCommand != nil {
+		b, err := scte35.Encode(dr.CueCommand)
+		if err != nil {
+			return fmt.Errorf("encode cue command: %w", err)
+		}
+		attrs = append(attrs, fmt.Sprintf("SCTE35-CMD=0x%s", hex.EncodeToString(b)))
+	}
 	if dr.CueIn != nil {
 		b, err := scte35.Encode(dr.CueIn)
 		if err != nil {
@@ -105,7 +377,7 @@ func writeDateRange(w io.Writer, dr *DateRange) error {
 		} else if dr.Duration > 0 {
 			return fmt.Errorf("non-zero duration %s with end-on-next set", dr.Duration)
 		}
-		attrs = append(attrs, "END-ON-NEXT:YES")
+		attrs = append(attrs, "END-ON-NEXT=YES")
 	}
 	tag := tagDateRange + ":" + strings.Join(attrs, ",")
 	_, err := fmt.Fprintln(w, tag)
@@ -127,6 +399,20 @@ func writeRendition(w io.Writer, r Rendition) (n int, err error) {
 	} else if r.Type == MediaClosedCaptions && r.InstreamID == nil {
 		return 0, fmt.Errorf("nil instream-id")
 	}
+	if r.Type != MediaAudio {
+		if r.Channels != nil || r.BitDepth != 0 || r.SampleRate != 0 {
+			return 0, fmt.Errorf("audio attributes set but type is %s", r.Type)
+		}
+	}
+	if r.Channels != nil && r.Channels.Count <= 0 {
+		return 0, fmt.Errorf("invalid channel count %d", r.Channels.Count)
+	}
+	if r.BitDepth < 0 || r.SampleRate < 0 {
+		return 0, fmt.Errorf("negative bit depth or sample rate")
+	}
+	if r.StableRenditionID != "" && !isStableID(r.StableRenditionID) {
+		return 0, fmt.Errorf("invalid stable rendition id %q", r.StableRenditionID)
+	}
 	return fmt.Fprintln(w, r)
 }
 
@@ -139,3 +425,17 @@ func writeSessionData(w io.Writer, sd SessionData) (n int, err error) {
 	}
 	return fmt.Fprintln(w, sd)
 }
+
+// formatCustomValue formats v, a value held in DateRange.Custom,
+// as an attribute value.
+func formatCustomValue(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []byte:
+		return fmt.Sprintf("0x%x", v), nil
+	}
+	return "", fmt.Errorf("unsupported type %T", v)
+}
@@ -7,6 +7,8 @@ import (
 )
 
 func TestWriteVariant(t *testing.T) {
+	score := 2.5
+	zero := 0.0
 	var cases = []struct {
 		name  string
 		v     Variant
@@ -36,6 +38,42 @@ url_0/193039199_mp4_h264_aac_hd_7.m3u8`,
 small.m3u8`,
 			true,
 		},
+		{
+			"hdr",
+			Variant{
+				URI:                "hdr.m3u8",
+				Bandwidth:          10000,
+				AverageBandwidth:   8000,
+				Codecs:             []string{"hvc1.2.4.L153.b0"},
+				Score:              &score,
+				VideoRange:         VideoRangePQ,
+				AllowedCPC:         []CPC{{"com.apple.streamingkeydelivery", []string{"HW"}}},
+				StableVariantID:    "hdr-2160p",
+				SupplementalCodecs: []string{"dvh1.08.07/db4h"},
+			},
+			`#EXT-X-STREAM-INF:BANDWIDTH=10000,AVERAGE-BANDWIDTH=8000,CODECS="hvc1.2.4.L153.b0",SCORE=2.5,VIDEO-RANGE=PQ,ALLOWED-CPC="com.apple.streamingkeydelivery:HW",STABLE-VARIANT-ID="hdr-2160p",SUPPLEMENTAL-CODECS="dvh1.08.07/db4h"
+hdr.m3u8`,
+			true,
+		},
+		{
+			"zero score",
+			Variant{URI: "low.m3u8", Bandwidth: 10000, Score: &zero},
+			`#EXT-X-STREAM-INF:BANDWIDTH=10000,SCORE=0
+low.m3u8`,
+			true,
+		},
+		{
+			"bad stable id",
+			Variant{URI: "a.m3u8", Bandwidth: 10000, StableVariantID: "a b"},
+			"",
+			false,
+		},
+		{
+			"bad video layout",
+			Variant{URI: "a.m3u8", Bandwidth: 10000, ReqVideoLayout: []string{"CH-QUAD"}},
+			"",
+			false,
+		},
 		{
 			"no bandwidth",
 			Variant{URI: "url_0/193039199_mp4_h264_aac_hd_7.m3u8"},
@@ -82,6 +82,23 @@ func isPESPayload(payload []byte) bool {
 	return false
 }
 
+// hasPESHeader reports whether PES packets of the stream
+// identified by id carry the optional PES header.
+func hasPESHeader(id byte) bool {
+	switch id {
+	case 0xbc, // program stream map
+		0xbe, // padding
+		0xbf, // private stream 2
+		0xf0, // ECM
+		0xf1, // EMM
+		0xf2, // DSM-CC
+		0xf8, // ITU-T H.222.1 type E
+		0xff: // program stream directory
+		return false
+	}
+	return true
+}
+
 func decodePES(buf []byte) (*PESPacket, error) {
 	if !isPESPayload(buf) {
 		return nil, fmt.Errorf("no PES packet")
@@ -91,7 +108,9 @@ func decodePES(buf []byte) (*PESPacket, error) {
 	pes.Length = binary.BigEndian.Uint16(buf[4:6])
 	buf = buf[6:]
 	// is there a header to decode?
-	if pes.Length >= 3 {
+	// Video streams may have a zero length yet still carry a header,
+	// so decide based on the stream ID.
+	if hasPESHeader(pes.ID) && len(buf) >= 3 {
 		header, err := decodePESHeader(buf)
 		if err != nil {
 			return nil, fmt.Errorf("decode header: %w", err)
@@ -161,6 +180,9 @@ func decodePESHeader(buf []byte) (*PESHeader, error) {
 		if err != nil {
 			return nil, fmt.Errorf("read timestamp: %w", err)
 		}
+		if tstamp.DTS && !tstamp.PTS {
+			return nil, fmt.Errorf("read timestamp: DTS set but no PTS set")
+		}
 		h.Presentation = &tstamp
 		buf = buf[5:]
 	}
@@ -212,9 +234,7 @@ func encodePESHeader(h *PESHeader) ([]byte, error) {
 		opt = append(opt, packed[:]...)
 	}
 	if h.Decode != nil {
-		if !h.Decode.PTS && h.Decode.DTS {
-			return nil, fmt.Errorf("bad timestamp: DTS set without PTS")
-		}
+		// decode timestamps are prefixed with only the DTS bit set.
 		packed := packTimestamp(*h.Decode)
 		opt = append(opt, packed[:]...)
 	}
@@ -259,9 +279,6 @@ func unpackTimestamp(a [5]byte) (Timestamp, error) {
 	var tstamp Timestamp
 	tstamp.PTS = a[0]&0b00100000 > 0
 	tstamp.DTS = a[0]&0b00010000 > 0
-	if tstamp.DTS && !tstamp.PTS {
-		return Timestamp{}, fmt.Errorf("DTS set but no PTS set")
-	}
 	if a[0]&a[2]&a[4]&0x01 == 0 {
 		return Timestamp{}, fmt.Errorf("corrupt timestamp")
 	}
@@ -3,6 +3,12 @@
 
 package scte35
 
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"fmt"
+)
+
 // Cipher is a 6-bit field specifying the algorithm used to encrypt
 // payloads as defined in SCTE 35 section 11.3.
 type Cipher uint8
@@ -37,3 +43,76 @@ func (c Cipher) String() string {
 	}
 	return "invalid"
 }
+
+// KeyProvider provides the control words (keys) used to encrypt and
+// decrypt splices, as described in SCTE 35 section 11.2.
+type KeyProvider interface {
+	// ControlWord returns the control word indexed by cwIndex.
+	// DES control words are 8 bytes long; Triple DES control
+	// words are 24 bytes long, holding the three keys in order.
+	ControlWord(cwIndex uint8) ([]byte, error)
+}
+
+// ControlWords is a KeyProvider holding control words in memory.
+type ControlWords map[uint8][]byte
+
+func (cw ControlWords) ControlWord(index uint8) ([]byte, error) {
+	key, ok := cw[index]
+	if !ok {
+		return nil, fmt.Errorf("no control word at index %d", index)
+	}
+	return key, nil
+}
+
+func newBlockCipher(c Cipher, cw []byte) (cipher.Block, error) {
+	switch c {
+	case DES_ECB, DES_CBC:
+		return des.NewCipher(cw)
+	case TripleDES:
+		return des.NewTripleDESCipher(cw)
+	}
+	return nil, fmt.Errorf("cipher %s: %w", c, ErrUnsupported)
+}
+
+// encrypt encrypts b in place with the control word cw.
+// In CBC mode the initialization vector is zero.
+// The length of b must be a multiple of des.BlockSize.
+func encrypt(c Cipher, cw []byte, b []byte) error {
+	block, err := newBlockCipher(c, cw)
+	if err != nil {
+		return err
+	}
+	if len(b)%block.BlockSize() != 0 {
+		return fmt.Errorf("encrypted length %d not a multiple of block size %d", len(b), block.BlockSize())
+	}
+	if c == DES_CBC {
+		iv := make([]byte, block.BlockSize())
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(b, b)
+		return nil
+	}
+	for i := 0; i < len(b); i += block.BlockSize() {
+		block.Encrypt(b[i:], b[i:])
+	}
+	return nil
+}
+
+// decrypt decrypts b in place with the control word cw.
+// See encrypt.
+func decrypt(c Cipher, cw []byte, b []byte) error {
+	block, err := newBlockCipher(c, cw)
+	if err != nil {
+		return err
+	}
+	if len(b)%block.BlockSize() != 0 {
+		return fmt.Errorf("encrypted length %d not a multiple of block size %d", len(b), block.BlockSize())
+	}
+	if c == DES_CBC {
+		iv := make([]byte, block.BlockSize())
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(b, b)
+		return nil
+	}
+	for i := 0; i < len(b); i += block.BlockSize() {
+		block.Decrypt(b[i:], b[i:])
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package scte35
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestPackEncryption(t *testing.T) {
 	type ptest struct {
@@ -28,3 +31,47 @@ func TestPackEncryption(t *testing.T) {
 		}
 	}
 }
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	keys := ControlWords{
+		1: []byte("8bytekey"),
+		2: []byte("twenty-four byte key!!!!"),
+	}
+	for _, c := range []Cipher{DES_ECB, DES_CBC, TripleDES} {
+		t.Run(c.String(), func(t *testing.T) {
+			want := samples[1].want
+			want.Encrypted = true
+			want.Cipher = c
+			want.CWIndex = 1
+			if c == TripleDES {
+				want.CWIndex = 2
+			}
+			want.PTSAdjustment = 0x1deadbeef
+			b, err := EncodeWithKeys(&want, keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// command type through E_CRC_32, excluding CRC_32.
+			if n := len(b) - 13 - 4; n%8 != 0 {
+				t.Errorf("encrypted portion %d bytes, not a multiple of 8", n)
+			}
+			if _, err := Decode(b); err == nil {
+				t.Errorf("nil error decoding encrypted splice without keys")
+			}
+			got, err := DecodeWithKeys(b, keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want.CRC32 = got.CRC32
+			if !reflect.DeepEqual(want, *got) {
+				t.Errorf("want %+v, got %+v", want, *got)
+				t.Log(diffInfo(want, *got))
+			}
+
+			wrong := ControlWords{1: []byte("wrongkey"), 2: []byte("wrong twenty-four bytes!")}
+			if _, err := DecodeWithKeys(b, wrong); err == nil {
+				t.Errorf("nil error decrypting with wrong control word")
+			}
+		})
+	}
+}
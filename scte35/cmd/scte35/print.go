@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/untangledco/streaming/scte35"
+)
+
+// printers holds the functions printing splices in each output format.
+// src describes where the splice was found.
+var printers = map[string]func(w io.Writer, src string, splice *scte35.Splice) error{
+	"tree": printTree,
+	"json": func(w io.Writer, _ string, splice *scte35.Splice) error {
+		b, err := json.MarshalIndent(splice, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	},
+	"xml": func(w io.Writer, _ string, splice *scte35.Splice) error {
+		b, err := xml.MarshalIndent(splice, "", "\t")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", b)
+		return err
+	},
+	"base64": func(w io.Writer, _ string, splice *scte35.Splice) error {
+		s, err := scte35.EncodeBase64(splice)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, s)
+		return err
+	},
+	"hex": func(w io.Writer, _ string, splice *scte35.Splice) error {
+		s, err := scte35.EncodeHex(splice)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, s)
+		return err
+	},
+	"raw": func(w io.Writer, _ string, splice *scte35.Splice) error {
+		b, err := scte35.Encode(splice)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	},
+}
+
+// tickFields names the fields holding ticks of a 90KHz clock,
+// which are also printed as durations.
+var tickFields = map[string]bool{
+	"PTSAdjustment": true,
+	"SpliceTime":    true,
+	"TimeSignal":    true,
+	"Duration":      true,
+	"PTSOffset":     true,
+}
+
+// hexFields names the integer fields printed in hexadecimal.
+var hexFields = map[string]bool{
+	"Tier":  true,
+	"CRC32": true,
+	"Type":  true, // of a SegmentationDescriptor
+	"PTag":  true,
+	"PID":   true,
+	// flags of a SegmentationDescriptor
+	"Restrictions": true,
+}
+
+func printTree(w io.Writer, src string, splice *scte35.Splice) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s:\n", src)
+	v := reflect.ValueOf(splice).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Type().Field(i); f.IsExported() {
+			tree(&sb, 1, f.Name, v.Field(i))
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+var stringer = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// tree writes v, named name, indented by depth tabs.
+// Structs and slices are written one field or element per line,
+// indented beneath name. Elements of interface type, such as
+// descriptors, are labelled with their type.
+// Nil pointers and empty slices are omitted.
+func tree(sb *strings.Builder, depth int, name string, v reflect.Value) {
+	indent := strings.Repeat("\t", depth)
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Type().Implements(stringer) {
+		fmt.Fprintf(sb, "%s%s: %s\n", indent, name, v.Interface())
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		fmt.Fprintf(sb, "%s%s:\n", indent, name)
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.IsExported() {
+				tree(sb, depth+1, f.Name, v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			if isText(b) {
+				fmt.Fprintf(sb, "%s%s: %q\n", indent, name, b)
+			} else {
+				fmt.Fprintf(sb, "%s%s: %x\n", indent, name, b)
+			}
+			return
+		}
+		fmt.Fprintf(sb, "%s%s:\n", indent, name)
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i)
+			label := fmt.Sprintf("%d", i)
+			if elem.Kind() == reflect.Interface && !elem.IsNil() {
+				label += " " + elem.Elem().Type().Name()
+			}
+			tree(sb, depth+1, label, elem)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := v.Uint()
+		switch {
+		case tickFields[name]:
+			fmt.Fprintf(sb, "%s%s: %d (%s)\n", indent, name, n, scte35.Duration(n))
+		case hexFields[name]:
+			fmt.Fprintf(sb, "%s%s: %#x\n", indent, name, n)
+		default:
+			fmt.Fprintf(sb, "%s%s: %d\n", indent, name, n)
+		}
+	default:
+		fmt.Fprintf(sb, "%s%s: %v\n", indent, name, v.Interface())
+	}
+}
+
+// isText reports whether b is printable ASCII, such as DTMF
+// characters or an ISO 639 language code.
+func isText(b []byte) bool {
+	for _, c := range b {
+		if c < ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,504 @@
+// Command scte35 decodes, encodes and inspects SCTE 35 cues.
+//
+// Usage:
+//
+//	scte35 [-o format] [-f file] [cue ...]
+//	scte35 -new command [-id n] [-pts ticks] [-d duration] [-in] [-auto] [-seg type] [-o format]
+//
+// In the first form, scte35 decodes each cue given as an argument,
+// written in base64 or hexadecimal, and prints it in the output format.
+// If no cues are given, cues are read from file, or the standard input
+// if file is not given. The input may be:
+//
+//   - base64 or hexadecimal cues separated by white space,
+//   - a single binary splice_info_section,
+//   - a MPEG-TS stream, from which sections of every PID are read,
+//   - a HLS playlist, from which cues in EXT-X-DATERANGE tags and
+//     legacy tags such as EXT-X-CUE-OUT are read,
+//   - a splice or array of splices in JSON, or SpliceInfoSection
+//     elements in XML, as encoded by the output formats json and xml.
+//
+// Problems such as checksum mismatches, truncated messages and
+// malformed UPIDs are printed to the standard error, prefixed by
+// where the cue was found. A cue with a bad CRC_32 is still printed
+// if its contents can be decoded. scte35 exits with status 1 if
+// any problem was found.
+//
+// In the second form, scte35 builds a cue from the flags and prints it.
+// The command is one of "null", "insert" or "signal" (time_signal).
+//
+// The options are:
+//
+//	-o format
+//		Print cues as format, one of "tree", "json", "xml",
+//		"base64", "hex" or "raw" (binary). The default is tree,
+//		or base64 with -new.
+//	-f file
+//		Read cues from file. If file is a http or https URL,
+//		it is fetched, which is useful for playlists.
+//	-new command
+//		Build a cue of command.
+//	-id n
+//		Set the splice_event_id of an insert, or the
+//		segmentation_event_id of a descriptor added by -seg.
+//	-pts ticks
+//		Set the splice time in ticks of a 90KHz clock.
+//		Inserts without a splice time are immediate, and
+//		time signals without a splice time are unspecified.
+//	-d duration
+//		Set the break duration of an insert, or the segmentation
+//		duration of a descriptor added by -seg, such as "30s".
+//	-in
+//		Return to the network, rather than leaving it, in an insert.
+//	-auto
+//		Set the auto_return flag of the break duration.
+//	-seg type
+//		Add a segmentation descriptor of type, such as 0x34 for a
+//		provider placement opportunity start, to a time signal.
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/untangledco/streaming/m3u8"
+	"github.com/untangledco/streaming/mpegts"
+	"github.com/untangledco/streaming/scte35"
+)
+
+const usage string = `usage: scte35 [-o format] [-f file] [cue ...]
+       scte35 -new command [-id n] [-pts ticks] [-d duration] [-in] [-auto] [-seg type] [-o format]`
+
+func init() {
+	log.SetFlags(0)
+	log.SetPrefix("scte35: ")
+}
+
+var (
+	oflag   = flag.String("o", "", "output format")
+	fflag   = flag.String("f", "", "read cues from file")
+	newflag = flag.String("new", "", "build a cue of command")
+	idflag  = flag.Uint("id", 0, "event id")
+	ptsflag = flag.Int64("pts", -1, "splice time in ticks")
+	dflag   = flag.Duration("d", 0, "break or segmentation duration")
+	inflag  = flag.Bool("in", false, "return to the network")
+	auto    = flag.Bool("auto", false, "auto return")
+	segflag = flag.String("seg", "", "segmentation type")
+)
+
+// problems counts the problems reported with cues.
+var problems int
+
+func problem(src string, v ...any) {
+	log.Printf("%s: %s", src, fmt.Sprint(v...))
+	problems++
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	flag.Parse()
+
+	format := *oflag
+	if format == "" {
+		format = "tree"
+		if *newflag != "" {
+			format = "base64"
+		}
+	}
+	printCue, ok := printers[format]
+	if !ok {
+		log.Fatalf("unknown output format %q", format)
+	}
+
+	if *newflag != "" {
+		if flag.NArg() > 0 || *fflag != "" {
+			flag.Usage()
+		}
+		splice, err := build(*newflag)
+		if err != nil {
+			log.Fatalf("new %s: %v", *newflag, err)
+		}
+		if err := printCue(os.Stdout, "new", splice); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	emit := func(src string, splice *scte35.Splice) {
+		if err := printCue(os.Stdout, src, splice); err != nil {
+			problem(src, err)
+		}
+	}
+	switch {
+	case flag.NArg() > 0:
+		if *fflag != "" {
+			flag.Usage()
+		}
+		for i, arg := range flag.Args() {
+			src := fmt.Sprintf("arg %d", i+1)
+			if splice := decodeText(src, arg); splice != nil {
+				emit(src, splice)
+			}
+		}
+	case *fflag != "":
+		b, err := readFile(*fflag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		readCues(*fflag, b, emit)
+	default:
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		readCues("stdin", b, emit)
+	}
+	if problems > 0 {
+		os.Exit(1)
+	}
+}
+
+func readFile(name string) ([]byte, error) {
+	if !strings.HasPrefix(name, "http://") && !strings.HasPrefix(name, "https://") {
+		return os.ReadFile(name)
+	}
+	resp, err := http.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: remote status: %s", name, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// readCues calls emit with each cue decoded from b,
+// whose format is guessed from its contents.
+func readCues(name string, b []byte, emit func(src string, splice *scte35.Splice)) {
+	text := bytes.TrimSpace(b)
+	switch {
+	case len(b) >= mpegts.PacketSize && b[0] == mpegts.Sync:
+		readTS(name, b, emit)
+	case len(b) > 0 && b[0] == 0xfc:
+		if splice := decode(name, b); splice != nil {
+			emit(name, splice)
+		}
+	case bytes.HasPrefix(text, []byte("#EXTM3U")):
+		readPlaylist(name, b, emit)
+	case bytes.HasPrefix(text, []byte("{")), bytes.HasPrefix(text, []byte("[")):
+		readJSON(name, text, emit)
+	case bytes.HasPrefix(text, []byte("<")):
+		readXML(name, text, emit)
+	default:
+		for i, line := range strings.Split(string(b), "\n") {
+			for _, field := range strings.Fields(line) {
+				src := fmt.Sprintf("%s:%d", name, i+1)
+				if splice := decodeText(src, field); splice != nil {
+					emit(src, splice)
+				}
+			}
+		}
+	}
+}
+
+// readJSON calls emit with each splice in b, a stream of
+// splices or arrays of splices.
+func readJSON(name string, b []byte, emit func(src string, splice *scte35.Splice)) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	var n int
+	for {
+		var v json.RawMessage
+		if err := d.Decode(&v); errors.Is(err, io.EOF) {
+			return
+		} else if err != nil {
+			problem(name, err)
+			return
+		}
+		splices := []scte35.Splice{{}}
+		var err error
+		if v[0] == '[' {
+			err = json.Unmarshal(v, &splices)
+		} else {
+			err = json.Unmarshal(v, &splices[0])
+		}
+		if err != nil {
+			problem(fmt.Sprintf("%s: splice %d", name, n+1), err)
+			return
+		}
+		for i := range splices {
+			n++
+			emit(fmt.Sprintf("%s: splice %d", name, n), &splices[i])
+		}
+	}
+}
+
+func readXML(name string, b []byte, emit func(src string, splice *scte35.Splice)) {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	var n int
+	for {
+		tok, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			problem(name, err)
+			return
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "SpliceInfoSection" {
+			continue
+		}
+		n++
+		src := fmt.Sprintf("%s: SpliceInfoSection %d", name, n)
+		var splice scte35.Splice
+		if err := d.DecodeElement(&splice, &start); err != nil {
+			problem(src, err)
+			continue
+		}
+		emit(src, &splice)
+	}
+	if n == 0 {
+		problem(name, "no SpliceInfoSection elements")
+	}
+}
+
+// readTS calls emit with each splice_info_section carried in the
+// transport stream b. Sections are reassembled from the payloads of
+// each PID; those which are not splice_info_sections are ignored.
+func readTS(name string, b []byte, emit func(src string, splice *scte35.Splice)) {
+	sections := make(map[mpegts.PacketID][]byte)
+	sc := mpegts.NewScanner(bytes.NewReader(b))
+	var n int
+	for sc.Scan() {
+		n++
+		p := sc.Packet()
+		if p.PES != nil || p.Error {
+			continue
+		}
+		src := fmt.Sprintf("%s: packet %d: pid %d", name, n, p.PID)
+		payload := p.Payload
+		if p.PayloadStart {
+			if len(payload) == 0 || len(payload) < 1+int(payload[0]) {
+				problem(src, "bad pointer field")
+				delete(sections, p.PID)
+				continue
+			}
+			pointer := int(payload[0])
+			if sec, ok := sections[p.PID]; ok {
+				sections[p.PID] = append(sec, payload[1:1+pointer]...)
+				flushSections(src, p.PID, sections, emit)
+			}
+			sections[p.PID] = nil
+			payload = payload[1+pointer:]
+		} else if _, ok := sections[p.PID]; !ok {
+			// no section started on this PID yet.
+			continue
+		}
+		sections[p.PID] = append(sections[p.PID], payload...)
+		flushSections(src, p.PID, sections, emit)
+	}
+	if err := sc.Err(); err != nil {
+		problem(fmt.Sprintf("%s: packet %d", name, n+1), err)
+	}
+}
+
+// flushSections emits each complete splice_info_section buffered for pid.
+func flushSections(src string, pid mpegts.PacketID, sections map[mpegts.PacketID][]byte, emit func(src string, splice *scte35.Splice)) {
+	buf := sections[pid]
+	for len(buf) >= 3 && buf[0] != 0xff {
+		length := 3 + int(binary.BigEndian.Uint16(buf[1:3])&0x0fff)
+		if len(buf) < length {
+			sections[pid] = buf
+			return
+		}
+		if buf[0] == 0xfc {
+			if splice := decode(src, buf[:length]); splice != nil {
+				emit(src, splice)
+			}
+		}
+		buf = buf[length:]
+	}
+	// the rest is stuffing, or a section header split across packets.
+	if len(buf) > 0 && buf[0] != 0xff {
+		sections[pid] = buf
+	} else {
+		delete(sections, pid)
+	}
+}
+
+func readPlaylist(name string, b []byte, emit func(src string, splice *scte35.Splice)) {
+	p, warnings, err := m3u8.DecodeLenient(bytes.NewReader(b))
+	for _, w := range warnings {
+		problem(name, w)
+	}
+	if err != nil {
+		problem(name, err)
+		return
+	}
+	for i, seg := range p.Segments {
+		src := fmt.Sprintf("%s: segment %d (%s)", name, p.Sequence+i, seg.URI)
+		if seg.Cue != nil && seg.Cue.Splice != nil {
+			emit(fmt.Sprintf("%s: cue %s", src, seg.Cue.Type), seg.Cue.Splice)
+		}
+		emitDateRanges(src, seg.DateRanges, emit)
+	}
+	emitDateRanges(name, p.DateRanges, emit)
+}
+
+// emitDateRanges emits the splices held in ranges, found at src.
+func emitDateRanges(src string, ranges []m3u8.DateRange, emit func(src string, splice *scte35.Splice)) {
+	for _, dr := range ranges {
+		src := fmt.Sprintf("%s: daterange %q", src, dr.ID)
+		if dr.CueCommand != nil {
+			emit(src+" SCTE35-CMD", dr.CueCommand)
+		}
+		if dr.CueOut != nil {
+			emit(src+" SCTE35-OUT", dr.CueOut)
+		}
+		if dr.CueIn != nil {
+			emit(src+" SCTE35-IN", dr.CueIn)
+		}
+	}
+}
+
+// decodeText decodes a cue written in base64 or hexadecimal.
+// Hexadecimal cues may be prefixed by "0x".
+// Problems are reported and a nil splice returned if s cannot be decoded.
+func decodeText(src, s string) *scte35.Splice {
+	var b []byte
+	var err error
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		b, err = hex.DecodeString(s[2:])
+	case isHex(s):
+		b, err = hex.DecodeString(s)
+	default:
+		// be forgiving of cues from URLs or with padding stripped.
+		enc := base64.StdEncoding
+		if strings.ContainsAny(s, "-_") {
+			enc = base64.URLEncoding
+		}
+		if len(s)%4 != 0 {
+			enc = enc.WithPadding(base64.NoPadding)
+		}
+		b, err = enc.DecodeString(s)
+	}
+	if err != nil {
+		problem(src, "not base64 or hexadecimal: ", err)
+		return nil
+	}
+	return decode(src, b)
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// decode decodes a splice_info_section, reporting problems.
+// If only the checksum is wrong, the splice is decoded anyway.
+func decode(src string, b []byte) *scte35.Splice {
+	splice, err := scte35.Decode(b)
+	var crcErr *scte35.CRCError
+	if errors.As(err, &crcErr) && !crcErr.Encrypted {
+		problem(src, err)
+		fixed := append([]byte{}, b...)
+		binary.BigEndian.PutUint32(fixed[len(fixed)-4:], crcErr.Calculated)
+		splice, err = scte35.Decode(fixed)
+		if err == nil {
+			splice.CRC32 = crcErr.Message
+		}
+	}
+	if err != nil {
+		problem(src, err)
+		return nil
+	}
+	for i, d := range splice.Descriptors {
+		if seg, ok := d.(scte35.SegmentationDescriptor); ok {
+			if err := seg.UPID.Validate(); err != nil {
+				problem(src, fmt.Sprintf("descriptor %d: ", i), err)
+			}
+		}
+	}
+	return splice
+}
+
+func build(command string) (*scte35.Splice, error) {
+	if *ptsflag > 1<<33-1 {
+		return nil, fmt.Errorf("pts %d overflows 33 bits", *ptsflag)
+	}
+	var pts *uint64
+	if *ptsflag >= 0 {
+		t := uint64(*ptsflag)
+		pts = &t
+	}
+	if *idflag > 1<<32-1 {
+		return nil, fmt.Errorf("id %d overflows 32 bits", *idflag)
+	}
+	splice := &scte35.Splice{
+		SAPType: scte35.SAPNone,
+		Tier:    0x0fff,
+	}
+	switch command {
+	case "null":
+		splice.Command = &scte35.Command{Type: scte35.SpliceNull}
+	case "insert":
+		ins := &scte35.Insert{
+			ID:           uint32(*idflag),
+			OutOfNetwork: !*inflag,
+			SpliceTime:   pts,
+			Immediate:    pts == nil,
+		}
+		if *dflag > 0 {
+			ins.Duration = scte35.NewBreakDuration(*dflag, *auto)
+		}
+		splice.Command = &scte35.Command{Type: scte35.SpliceInsert, Insert: ins}
+	case "signal":
+		splice.Command = &scte35.Command{Type: scte35.TimeSignal, TimeSignal: pts}
+	default:
+		return nil, fmt.Errorf("unknown command, want null, insert or signal")
+	}
+	if *segflag != "" {
+		if command != "signal" {
+			return nil, fmt.Errorf("segmentation descriptors need a signal command")
+		}
+		typ, err := strconv.ParseUint(*segflag, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("parse segmentation type: %w", err)
+		}
+		seg := scte35.SegmentationDescriptor{
+			EventID: uint32(*idflag),
+			Type:    uint8(typ),
+		}
+		if *dflag > 0 {
+			d := scte35.Ticks(*dflag)
+			seg.Duration = &d
+		}
+		splice.Descriptors = append(splice.Descriptors, seg)
+	}
+	// check the cue is valid before printing it.
+	if _, err := scte35.Encode(splice); err != nil {
+		return nil, err
+	}
+	return splice, nil
+}
@@ -37,6 +37,7 @@ func Example() {
 func ExampleEncode() {
 	when := uint64(12 * 60 * 60 * 90000) // 12 hours since midnight UTC as 90KHz ticks
 	duration := uint64(60 * 90000)       // 60 seconds as 90KHz ticks
+	restrictions := scte35.NoRegionalBlackout | scte35.ArchiveAllowed | scte35.DeviceRestrictionsNone
 	splice := scte35.Splice{
 		SAPType: scte35.SAPNone,
 		Command: &scte35.Command{
@@ -46,7 +47,7 @@ func ExampleEncode() {
 		Descriptors: []scte35.SpliceDescriptor{
 			scte35.SegmentationDescriptor{
 				EventID:      1234,
-				Restrictions: scte35.NoRegionalBlackout | scte35.ArchiveAllowed | scte35.DeviceRestrictionsNone,
+				Restrictions: &restrictions,
 				Duration:     &duration,
 				UPID: scte35.UPID{
 					Type:  scte35.UPIDTI,
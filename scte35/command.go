@@ -15,9 +15,9 @@ type Command struct {
 	Type     CommandType
 	Schedule []Event // SpliceSchedule
 	Insert   *Insert
-	// Number of ticks of a 90KHz clock since midnight UTC.
-	// TODO(otl): use time.Time here instead,
-	// then calculate ticks when converting to wire format?
+	// Presentation timestamp in ticks of a 90KHz clock.
+	// See Splice.SpliceTime for the timestamp with the splice's
+	// PTSAdjustment applied, and Clock to map it to wall-clock time.
 	TimeSignal *uint64
 	Private    *PrivateCommand
 }
@@ -75,11 +75,8 @@ func encodeCommand(c *Command) ([]byte, error) {
 	case SpliceInsert:
 		return encodeInsert(c.Insert), nil
 	case TimeSignal:
-		if c.TimeSignal == nil {
-			return nil, fmt.Errorf("cannot encode nil TimeSignal")
-		}
-		b := encodeSpliceTime(*c.TimeSignal)
-		return b[:], nil
+		// a nil TimeSignal is written with time_specified_flag unset.
+		return appendSpliceTime(nil, c.TimeSignal), nil
 	case Private:
 		return encodePrivateCommand(c.Private), nil
 	default:
@@ -95,11 +92,12 @@ type Event struct {
 	Cancel bool
 
 	OutOfNetwork bool
-	// TODO(otl): should always be true? should we support
-	// deprecated Component Splice Mode?
-	// see section 9.7.2.1.
-	// ProgramSplice bool
-	SpliceTime    time.Time
+	SpliceTime   time.Time
+	// Components, if set, lists the elementary streams to be
+	// spliced individually in the deprecated component splice
+	// mode, in which case SpliceTime is unused.
+	// See SCTE 35 section 9.7.2.1.
+	Components    []EventComponent
 	BreakDuration *BreakDuration
 
 	ProgramID     uint16
@@ -112,13 +110,22 @@ type Event struct {
 	idCompliance bool
 }
 
+// EventComponent is an elementary stream spliced in a scheduled event,
+// identified by the component_tag of its stream_identifier_descriptor.
+type EventComponent struct {
+	Tag        uint8
+	SpliceTime time.Time
+}
+
 func packEvents(events []Event) ([]byte, error) {
 	if len(events) > 255 {
 		return nil, fmt.Errorf("too many events (%d), need 255 or less", len(events))
 	}
-	var packed []byte
-	packed[0] = uint8(len(events))
+	packed := []byte{uint8(len(events))}
 	for i := range events {
+		if len(events[i].Components) > 255 {
+			return nil, fmt.Errorf("event %d: too many components (%d), need 255 or less", events[i].ID, len(events[i].Components))
+		}
 		b := packEvent(&events[i])
 		packed = append(packed, b...)
 	}
@@ -135,36 +142,136 @@ func packEvent(e *Event) []byte {
 	if e.idCompliance {
 		p[4] |= 1 << 6
 	}
-	// 6 remaining bits are reserved.
-
-	if !e.Cancel {
-		p = append(p, 0x00)
-		if e.OutOfNetwork {
-			p[5] |= 1 << 7
-		}
-		// assume program_splice is always set;
-		// we don't support component splice mode.
-		p[5] |= 1 << 6
-		if e.BreakDuration != nil {
-			p[5] |= 1 << 5
-		}
-		// 5 remaining bits are reserved
+	// toggle 6 remaining reserved bits.
+	p[4] |= 0x3f
+	if e.Cancel {
+		return p
+	}
 
-		seconds := e.SpliceTime.Sub(gpsEpoch) / time.Second
-		p = binary.BigEndian.AppendUint32(p, uint32(seconds))
+	var flags byte
+	if e.OutOfNetwork {
+		flags |= 1 << 7
+	}
+	if len(e.Components) == 0 {
+		flags |= 1 << 6
+	}
+	if e.BreakDuration != nil {
+		flags |= 1 << 5
+	}
+	// toggle 5 remaining reserved bits.
+	flags |= 0x1f
+	p = append(p, flags)
 
-		if e.BreakDuration != nil {
-			bd := packBreakDuration(e.BreakDuration)
-			p = append(p, bd[:]...)
+	if len(e.Components) == 0 {
+		p = binary.BigEndian.AppendUint32(p, gpsSeconds(e.SpliceTime))
+	} else {
+		p = append(p, uint8(len(e.Components)))
+		for _, c := range e.Components {
+			p = append(p, c.Tag)
+			p = binary.BigEndian.AppendUint32(p, gpsSeconds(c.SpliceTime))
 		}
 	}
-
+	if e.BreakDuration != nil {
+		bd := packBreakDuration(e.BreakDuration)
+		p = append(p, bd[:]...)
+	}
 	p = binary.BigEndian.AppendUint16(p, e.ProgramID)
 	p = append(p, e.AvailNum)
 	p = append(p, e.AvailExpected)
 	return p
 }
 
+// gpsSeconds returns the utc_splice_time of t;
+// the number of seconds since the GPS epoch.
+func gpsSeconds(t time.Time) uint32 {
+	return uint32(t.Sub(gpsEpoch) / time.Second)
+}
+
+func fromGPSSeconds(seconds uint32) time.Time {
+	return gpsEpoch.Add(time.Duration(seconds) * time.Second)
+}
+
+func unpackEvents(buf []byte) ([]Event, error) {
+	if len(buf) < 1 {
+		return nil, errShort("splice count", 1, 0)
+	}
+	count := int(buf[0])
+	buf = buf[1:]
+	var events []Event
+	for i := 0; i < count; i++ {
+		e, n, err := unpackEvent(buf)
+		if err != nil {
+			return events, fmt.Errorf("event %d: %w", i, err)
+		}
+		events = append(events, *e)
+		buf = buf[n:]
+	}
+	return events, nil
+}
+
+// unpackEvent decodes the event at the start of buf,
+// returning the number of bytes read.
+func unpackEvent(buf []byte) (*Event, int, error) {
+	if len(buf) < 5 {
+		return nil, 0, errShort("event", 5, len(buf))
+	}
+	var e Event
+	e.ID = binary.BigEndian.Uint32(buf[:4])
+	e.Cancel = buf[4]&(1<<7) > 0
+	e.idCompliance = buf[4]&(1<<6) > 0
+	// next 6 bits are reserved.
+	if e.Cancel {
+		return &e, 5, nil
+	}
+	n := 5
+	if len(buf[n:]) < 1 {
+		return nil, 0, errShort("flags", 1, 0)
+	}
+	e.OutOfNetwork = buf[n]&(1<<7) > 0
+	programSplice := buf[n]&(1<<6) > 0
+	durflag := buf[n]&(1<<5) > 0
+	// next 5 bits are reserved.
+	n++
+
+	if programSplice {
+		if len(buf[n:]) < 4 {
+			return nil, 0, errShort("utc_splice_time", 4, len(buf[n:]))
+		}
+		e.SpliceTime = fromGPSSeconds(binary.BigEndian.Uint32(buf[n : n+4]))
+		n += 4
+	} else {
+		if len(buf[n:]) < 1 {
+			return nil, 0, errShort("component count", 1, 0)
+		}
+		count := int(buf[n])
+		n++
+		if len(buf[n:]) < count*5 {
+			return nil, 0, errShort("components", count*5, len(buf[n:]))
+		}
+		e.Components = make([]EventComponent, count)
+		for i := range e.Components {
+			e.Components[i].Tag = buf[n]
+			e.Components[i].SpliceTime = fromGPSSeconds(binary.BigEndian.Uint32(buf[n+1 : n+5]))
+			n += 5
+		}
+	}
+
+	if durflag {
+		if len(buf[n:]) < 5 {
+			return nil, 0, errShort("break duration", 5, len(buf[n:]))
+		}
+		e.BreakDuration = readBreakDuration([5]byte{buf[n], buf[n+1], buf[n+2], buf[n+3], buf[n+4]})
+		n += 5
+	}
+	if len(buf[n:]) < 4 {
+		return nil, 0, errShort("program ID and avails", 4, len(buf[n:]))
+	}
+	e.ProgramID = binary.BigEndian.Uint16(buf[n : n+2])
+	e.AvailNum = buf[n+2]
+	e.AvailExpected = buf[n+3]
+	return &e, n + 4, nil
+}
+
 type PrivateCommand struct {
 	ID   uint32
 	Data []byte
@@ -179,7 +286,7 @@ func encodePrivateCommand(c *PrivateCommand) []byte {
 
 func decodePrivateCommand(b []byte) (PrivateCommand, error) {
 	if len(b) < 4 {
-		return PrivateCommand{}, fmt.Errorf("need at least 4 bytes, have %d", len(b))
+		return PrivateCommand{}, errShort("identifier", 4, len(b))
 	}
 	return PrivateCommand{
 		ID:   binary.BigEndian.Uint32(b[:4]),
@@ -195,7 +302,12 @@ type Insert struct {
 	OutOfNetwork bool
 	Immediate    bool
 	// Number of ticks of a 90KHz clock.
-	SpliceTime    *uint64
+	SpliceTime *uint64
+	// Components, if set, lists the elementary streams to be
+	// spliced individually in the deprecated component splice
+	// mode, in which case SpliceTime is unused.
+	// See SCTE 35 section 9.7.3.1.
+	Components    []Component
 	Duration      *BreakDuration
 	ProgramID     uint16
 	AvailNum      uint8
@@ -207,6 +319,15 @@ type Insert struct {
 	idCompliance bool
 }
 
+// Component is an elementary stream spliced by a splice_insert command,
+// identified by the component_tag of its stream_identifier_descriptor.
+type Component struct {
+	Tag uint8
+	// Number of ticks of a 90KHz clock.
+	// Unused if the splice is immediate.
+	SpliceTime *uint64
+}
+
 func encodeInsert(ins *Insert) []byte {
 	buf := make([]byte, 4+1) // uint32 + 1 byte
 	binary.BigEndian.PutUint32(buf[:4], ins.ID)
@@ -220,9 +341,9 @@ func encodeInsert(ins *Insert) []byte {
 	if ins.OutOfNetwork {
 		flags |= (1 << 7)
 	}
-	// assume program_splice is set;
-	// we do not support the deprecated component_count mode.
-	flags |= (1 << 6)
+	if len(ins.Components) == 0 {
+		flags |= (1 << 6)
+	}
 	if ins.Duration != nil {
 		flags |= (1 << 5)
 	}
@@ -236,9 +357,18 @@ func encodeInsert(ins *Insert) []byte {
 	flags |= 0x07
 	buf = append(buf, flags)
 
-	if ins.SpliceTime != nil && !ins.Immediate {
-		b := encodeSpliceTime(*ins.SpliceTime)
-		buf = append(buf, b[:]...)
+	if len(ins.Components) == 0 {
+		if !ins.Immediate {
+			buf = appendSpliceTime(buf, ins.SpliceTime)
+		}
+	} else {
+		buf = append(buf, uint8(len(ins.Components)))
+		for _, c := range ins.Components {
+			buf = append(buf, c.Tag)
+			if !ins.Immediate {
+				buf = appendSpliceTime(buf, c.SpliceTime)
+			}
+		}
 	}
 
 	if ins.Duration != nil {
@@ -251,6 +381,102 @@ func encodeInsert(ins *Insert) []byte {
 	return buf
 }
 
+func decodeInsert(buf []byte) (*Insert, error) {
+	if len(buf) < 5 {
+		return nil, errShort("splice event", 5, len(buf))
+	}
+	var ins Insert
+	ins.ID = binary.BigEndian.Uint32(buf[:4])
+	ins.Cancel = buf[4]&0x80 > 0
+	if ins.Cancel {
+		return &ins, nil
+	}
+	if len(buf) < 6 {
+		return nil, errShort("flags", 1, 0)
+	}
+	ins.OutOfNetwork = buf[5]&(1<<7) > 0
+	programSplice := buf[5]&(1<<6) > 0
+	durflag := buf[5]&(1<<5) > 0
+	ins.Immediate = buf[5]&(1<<4) > 0
+	ins.idCompliance = buf[5]&(1<<3) > 0
+	// next 3 bits are reserved.
+	buf = buf[6:]
+
+	var err error
+	if programSplice {
+		if !ins.Immediate {
+			ins.SpliceTime, buf, err = readSpliceTime(buf)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if len(buf) < 1 {
+			return nil, errShort("component count", 1, 0)
+		}
+		ins.Components = make([]Component, buf[0])
+		buf = buf[1:]
+		for i := range ins.Components {
+			if len(buf) < 1 {
+				return nil, errShort(fmt.Sprintf("component %d tag", i), 1, 0)
+			}
+			ins.Components[i].Tag = buf[0]
+			buf = buf[1:]
+			if !ins.Immediate {
+				ins.Components[i].SpliceTime, buf, err = readSpliceTime(buf)
+				if err != nil {
+					return nil, fmt.Errorf("component %d: %w", i, err)
+				}
+			}
+		}
+	}
+
+	if durflag {
+		if len(buf) < 5 {
+			return nil, errShort("break duration", 5, len(buf))
+		}
+		ins.Duration = readBreakDuration([5]byte{buf[0], buf[1], buf[2], buf[3], buf[4]})
+		buf = buf[5:]
+	}
+	if len(buf) < 4 {
+		return nil, errShort("program ID and avails", 4, len(buf))
+	}
+	ins.ProgramID = binary.BigEndian.Uint16(buf[:2])
+	ins.AvailNum = buf[2]
+	ins.AvailExpected = buf[3]
+	return &ins, nil
+}
+
+// appendSpliceTime appends the splice_time structure for ticks to buf.
+// If ticks is nil, the time_specified_flag is unset.
+func appendSpliceTime(buf []byte, ticks *uint64) []byte {
+	if ticks == nil {
+		// time_specified_flag unset; remaining bits reserved.
+		return append(buf, 0x7f)
+	}
+	b := encodeSpliceTime(*ticks)
+	return append(buf, b[:]...)
+}
+
+// readSpliceTime reads a splice_time structure from the start of buf,
+// returning the time, if specified, and the remainder of buf.
+func readSpliceTime(buf []byte) (*uint64, []byte, error) {
+	if len(buf) < 1 {
+		return nil, buf, errShort("splice time", 1, 0)
+	}
+	// is time_specified_flag set? if so, read the 33-bit time.
+	if buf[0]&(1<<7) == 0 {
+		return nil, buf[1:], nil
+	}
+	if len(buf) < 5 {
+		return nil, buf, errShort("splice time", 5, len(buf))
+	}
+	b := []byte{0, 0, 0, buf[0] & 0x01} // skip reserved bits.
+	b = append(b, buf[1:5]...)
+	t := binary.BigEndian.Uint64(b)
+	return &t, buf[5:], nil
+}
+
 func encodeSpliceTime(ticks uint64) [5]byte {
 	pts := toPTS(ticks)
 	// set time_specified_flag
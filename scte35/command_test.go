@@ -2,7 +2,9 @@ package scte35
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestEncodeInsert(t *testing.T) {
@@ -26,3 +28,118 @@ func TestEncodeInsert(t *testing.T) {
 		t.Log(bin)
 	}
 }
+
+// Commands encoded by hand from the syntax in SCTE 35 section 9.7,
+// covering fields not present in the published samples.
+var commandTests = []struct {
+	name    string
+	encoded []byte
+	want    Command
+}{
+	{
+		name:    "bandwidth_reservation",
+		encoded: []byte{BandwidthReservation},
+		want:    Command{Type: BandwidthReservation},
+	},
+	{
+		name: "splice_schedule",
+		encoded: []byte{
+			SpliceSchedule, 0x03,
+			// program splice with break duration
+			0x00, 0x00, 0x00, 0x01, 0x3f, 0xff,
+			0x52, 0xbc, 0xc3, 0x00,
+			0xfe, 0x00, 0x29, 0x32, 0xe0,
+			0x00, 0x01, 0x01, 0x02,
+			// cancelled
+			0x00, 0x00, 0x00, 0x02, 0xbf,
+			// component splice
+			0x00, 0x00, 0x00, 0x03, 0x3f, 0x1f, 0x02,
+			0x01, 0x52, 0xbc, 0xc3, 0x00,
+			0x02, 0x52, 0xbc, 0xc3, 0x0a,
+			0x00, 0x00, 0x00, 0x00,
+		},
+		want: Command{
+			Type: SpliceSchedule,
+			Schedule: []Event{
+				{
+					ID:            1,
+					OutOfNetwork:  true,
+					SpliceTime:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					BreakDuration: &BreakDuration{AutoReturn: true, Duration: 30 * 90000},
+					ProgramID:     1,
+					AvailNum:      1,
+					AvailExpected: 2,
+				},
+				{ID: 2, Cancel: true},
+				{
+					ID: 3,
+					Components: []EventComponent{
+						{Tag: 1, SpliceTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+						{Tag: 2, SpliceTime: time.Date(2024, 1, 1, 0, 0, 10, 0, time.UTC)},
+					},
+				},
+			},
+		},
+	},
+	{
+		name: "splice_insert component",
+		encoded: []byte{
+			SpliceInsert, 0x00, 0x00, 0x00, 0x04, 0x7f, 0x87, 0x02,
+			0x01, 0xfe, 0x00, 0x00, 0x00, 0x64,
+			0x02, 0x7f,
+			0x00, 0x05, 0x01, 0x01,
+		},
+		want: Command{
+			Type: SpliceInsert,
+			Insert: &Insert{
+				ID:           4,
+				OutOfNetwork: true,
+				Components: []Component{
+					{Tag: 1, SpliceTime: newuint64(100)},
+					{Tag: 2},
+				},
+				ProgramID:     5,
+				AvailNum:      1,
+				AvailExpected: 1,
+			},
+		},
+	},
+	{
+		name: "splice_insert immediate",
+		encoded: []byte{
+			SpliceInsert, 0x00, 0x00, 0x00, 0x05, 0x7f, 0xf7,
+			0xfe, 0x00, 0x29, 0x32, 0xe0,
+			0x00, 0x00, 0x00, 0x00,
+		},
+		want: Command{
+			Type: SpliceInsert,
+			Insert: &Insert{
+				ID:           5,
+				OutOfNetwork: true,
+				Immediate:    true,
+				Duration:     &BreakDuration{AutoReturn: true, Duration: 30 * 90000},
+			},
+		},
+	},
+}
+
+func TestCommandRoundTrip(t *testing.T) {
+	for _, tt := range commandTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCommand(tt.encoded)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("decode: want %+v, got %+v", tt.want, *got)
+			}
+			b, err := encodeCommand(&tt.want)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if !bytes.Equal(b, tt.encoded[1:]) {
+				t.Errorf("encode: want %#x, got %#x", tt.encoded[1:], b)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package scte35
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrShortBuffer is returned when a message ends before
+	// the structures it declares.
+	ErrShortBuffer = errors.New("short buffer")
+	// ErrSectionLength is returned when the section_length field
+	// does not match the length of the message.
+	ErrSectionLength = errors.New("section length mismatch")
+	// ErrTableID is returned when a message does not start with
+	// the table_id of a splice_info_section.
+	ErrTableID = errors.New("not a splice_info_section")
+	// ErrNoKeys is returned when an encrypted splice is encoded
+	// or decoded without a KeyProvider.
+	ErrNoKeys = errors.New("encrypted splice: no key provider")
+	// ErrInvalidUPID is returned when a UPID's value is malformed
+	// for its type, or a UPID is accessed as the wrong type.
+	ErrInvalidUPID = errors.New("invalid upid")
+	// ErrUnsupported is returned for commands, descriptors and
+	// ciphers which are valid but cannot be handled.
+	ErrUnsupported = errors.New("unsupported")
+)
+
+// CRCError is returned when a message's checksum does not match
+// the checksum calculated from its contents.
+type CRCError struct {
+	// Encrypted reports whether the mismatch is of the E_CRC_32
+	// field of a decrypted splice, rather than the CRC_32 field.
+	Encrypted  bool
+	Message    uint32
+	Calculated uint32
+}
+
+func (e *CRCError) Error() string {
+	field := "CRC_32"
+	if e.Encrypted {
+		field = "E_CRC_32"
+	}
+	return fmt.Sprintf("%s mismatch: message has %#08x, calculated %#08x", field, e.Message, e.Calculated)
+}
+
+// errShort returns an error wrapping ErrShortBuffer
+// describing a truncated field.
+func errShort(field string, need, have int) error {
+	return fmt.Errorf("%s: need %d bytes, have %d: %w", field, need, have, ErrShortBuffer)
+}
@@ -0,0 +1,1014 @@
+package scte35
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Namespace is the XML namespace of the SCTE 35 XML schema.
+// Splices are marshalled to XML in this namespace as a SpliceInfoSection
+// element. Other types are marshalled as elements without a namespace,
+// to be enclosed within a SpliceInfoSection. Elements of any namespace
+// are accepted when unmarshalling.
+//
+// The JSON forms of each type mirror the XML, with attribute and
+// element names in lower camel case.
+const Namespace = "urn:scte:scte35:2013:xml"
+
+// EncodeBase64 returns the base64 encoding of splice,
+// a form commonly used to exchange cues in text.
+func EncodeBase64(splice *Splice) (string, error) {
+	b, err := Encode(splice)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// DecodeBase64 decodes a splice from its base64 encoding.
+func DecodeBase64(s string) (*Splice, error) {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	return Decode(b)
+}
+
+// EncodeHex returns the hexadecimal encoding of splice prefixed by "0x",
+// as in the SCTE35-OUT attribute of the HLS EXT-X-DATERANGE tag.
+func EncodeHex(splice *Splice) (string, error) {
+	b, err := Encode(splice)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(b), nil
+}
+
+// DecodeHex decodes a splice from its hexadecimal encoding.
+// A leading "0x" or "0X" is optional.
+func DecodeHex(s string) (*Splice, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return Decode(b)
+}
+
+// element returns a start element with the local name name
+// and no namespace, so that it inherits that of its parent.
+func element(name string) xml.StartElement {
+	return xml.StartElement{Name: xml.Name{Local: name}}
+}
+
+type spliceHeader struct {
+	SAPType         uint8         `xml:"sapType,attr" json:"sapType"`
+	PTSAdjustment   uint64        `xml:"ptsAdjustment,attr" json:"ptsAdjustment"`
+	ProtocolVersion uint8         `xml:"protocolVersion,attr" json:"protocolVersion"`
+	Tier            uint16        `xml:"tier,attr" json:"tier"`
+	EncryptedPacket *encryptedDoc `xml:"EncryptedPacket" json:"encryptedPacket,omitempty"`
+}
+
+type encryptedDoc struct {
+	Algorithm Cipher `xml:"encryptionAlgorithm,attr" json:"encryptionAlgorithm"`
+	CWIndex   uint8  `xml:"cwIndex,attr" json:"cwIndex"`
+}
+
+func (s *Splice) header() spliceHeader {
+	h := spliceHeader{
+		SAPType:         uint8(s.SAPType) >> 4,
+		PTSAdjustment:   s.PTSAdjustment,
+		ProtocolVersion: protocolVersion,
+		Tier:            s.Tier,
+	}
+	if s.Encrypted {
+		h.EncryptedPacket = &encryptedDoc{s.Cipher, s.CWIndex}
+	}
+	return h
+}
+
+func (s *Splice) setHeader(h spliceHeader) {
+	s.SAPType = SAPType(h.SAPType&0x03) << 4
+	s.PTSAdjustment = h.PTSAdjustment
+	s.Tier = h.Tier
+	if h.EncryptedPacket != nil {
+		s.Encrypted = true
+		s.Cipher = h.EncryptedPacket.Algorithm
+		s.CWIndex = h.EncryptedPacket.CWIndex
+	}
+}
+
+type spliceXML struct {
+	XMLName xml.Name
+	spliceHeader
+	Command     *Command
+	Descriptors []SpliceDescriptor
+}
+
+// MarshalXML encodes s as a SpliceInfoSection element in Namespace.
+// The CRC32 field is not encoded.
+func (s Splice) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	doc := spliceXML{
+		XMLName:      xml.Name{Space: Namespace, Local: "SpliceInfoSection"},
+		spliceHeader: s.header(),
+		Command:      s.Command,
+	}
+	for _, d := range s.Descriptors {
+		doc.Descriptors = append(doc.Descriptors, knownDescriptor(d))
+	}
+	return e.Encode(doc)
+}
+
+func (s *Splice) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var h spliceHeader
+	for _, attr := range start.Attr {
+		var err error
+		switch attr.Name.Local {
+		case "sapType":
+			h.SAPType, err = parseUint8(attr.Value)
+		case "ptsAdjustment":
+			h.PTSAdjustment, err = strconv.ParseUint(attr.Value, 10, 33)
+		case "protocolVersion":
+			h.ProtocolVersion, err = parseUint8(attr.Value)
+		case "tier":
+			var n uint64
+			n, err = strconv.ParseUint(attr.Value, 10, 12)
+			h.Tier = uint16(n)
+		}
+		if err != nil {
+			return fmt.Errorf("attribute %s: %w", attr.Name.Local, err)
+		}
+	}
+	*s = Splice{}
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.EndElement:
+			s.setHeader(h)
+			return nil
+		case xml.StartElement:
+			var err error
+			switch t.Name.Local {
+			case "EncryptedPacket":
+				h.EncryptedPacket = &encryptedDoc{}
+				err = d.DecodeElement(h.EncryptedPacket, &t)
+			case "SpliceNull", "SpliceSchedule", "SpliceInsert", "TimeSignal", "BandwidthReservation", "PrivateCommand":
+				s.Command = &Command{}
+				err = d.DecodeElement(s.Command, &t)
+			default:
+				var desc SpliceDescriptor
+				desc, err = unmarshalDescriptorXML(d, t)
+				if desc != nil {
+					s.Descriptors = append(s.Descriptors, desc)
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", t.Name.Local, err)
+			}
+		}
+	}
+}
+
+type spliceJSON struct {
+	spliceHeader
+	Command     *Command         `json:"command"`
+	Descriptors []descriptorJSON `json:"descriptors,omitempty"`
+}
+
+// descriptorJSON holds exactly one descriptor, keyed by its type.
+type descriptorJSON struct {
+	Avail        *AvailDescriptor        `json:"availDescriptor,omitempty"`
+	DTMF         *DTMFDescriptor         `json:"dtmfDescriptor,omitempty"`
+	Segmentation *SegmentationDescriptor `json:"segmentationDescriptor,omitempty"`
+	Time         *TimeDescriptor         `json:"timeDescriptor,omitempty"`
+	Audio        *AudioDescriptor        `json:"audioDescriptor,omitempty"`
+	Private      *PrivateDescriptor      `json:"privateDescriptor,omitempty"`
+}
+
+// MarshalJSON encodes s as a JSON object.
+// The CRC32 field is not encoded.
+func (s Splice) MarshalJSON() ([]byte, error) {
+	doc := spliceJSON{spliceHeader: s.header(), Command: s.Command}
+	for _, d := range s.Descriptors {
+		var dj descriptorJSON
+		switch d := knownDescriptor(d).(type) {
+		case AvailDescriptor:
+			dj.Avail = &d
+		case DTMFDescriptor:
+			dj.DTMF = &d
+		case SegmentationDescriptor:
+			dj.Segmentation = &d
+		case TimeDescriptor:
+			dj.Time = &d
+		case AudioDescriptor:
+			dj.Audio = &d
+		case PrivateDescriptor:
+			dj.Private = &d
+		}
+		doc.Descriptors = append(doc.Descriptors, dj)
+	}
+	return json.Marshal(doc)
+}
+
+func (s *Splice) UnmarshalJSON(b []byte) error {
+	var doc spliceJSON
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	*s = Splice{Command: doc.Command}
+	s.setHeader(doc.spliceHeader)
+	for i, dj := range doc.Descriptors {
+		switch {
+		case dj.Avail != nil:
+			s.Descriptors = append(s.Descriptors, *dj.Avail)
+		case dj.DTMF != nil:
+			s.Descriptors = append(s.Descriptors, *dj.DTMF)
+		case dj.Segmentation != nil:
+			s.Descriptors = append(s.Descriptors, *dj.Segmentation)
+		case dj.Time != nil:
+			s.Descriptors = append(s.Descriptors, *dj.Time)
+		case dj.Audio != nil:
+			s.Descriptors = append(s.Descriptors, *dj.Audio)
+		case dj.Private != nil:
+			desc, err := registeredDescriptor(*dj.Private)
+			if err != nil {
+				return fmt.Errorf("descriptor %d: %w", i, err)
+			}
+			s.Descriptors = append(s.Descriptors, desc)
+		default:
+			return fmt.Errorf("descriptor %d: unknown type", i)
+		}
+	}
+	return nil
+}
+
+// knownDescriptor returns d, or d as a PrivateDescriptor if d is
+// not one of the descriptor types of this package.
+func knownDescriptor(d SpliceDescriptor) SpliceDescriptor {
+	switch d.(type) {
+	case AvailDescriptor, DTMFDescriptor, SegmentationDescriptor, TimeDescriptor, AudioDescriptor, PrivateDescriptor:
+		return d
+	}
+	return PrivateDescriptor{d.Tag(), d.ID(), d.Data()}
+}
+
+// registeredDescriptor decodes d with the decoder registered for its
+// identifier and tag, if any. See RegisterDescriptor.
+func registeredDescriptor(d PrivateDescriptor) (SpliceDescriptor, error) {
+	if dec := lookupDescriptor(d.PID, d.PTag); dec != nil {
+		return dec(d.PData)
+	}
+	return d, nil
+}
+
+func unmarshalDescriptorXML(d *xml.Decoder, start xml.StartElement) (SpliceDescriptor, error) {
+	switch start.Name.Local {
+	case "AvailDescriptor":
+		var desc AvailDescriptor
+		err := d.DecodeElement(&desc, &start)
+		return desc, err
+	case "DTMFDescriptor":
+		var desc DTMFDescriptor
+		err := d.DecodeElement(&desc, &start)
+		return desc, err
+	case "SegmentationDescriptor":
+		var desc SegmentationDescriptor
+		err := d.DecodeElement(&desc, &start)
+		return desc, err
+	case "TimeDescriptor":
+		var desc TimeDescriptor
+		err := d.DecodeElement(&desc, &start)
+		return desc, err
+	case "AudioDescriptor":
+		var desc AudioDescriptor
+		err := d.DecodeElement(&desc, &start)
+		return desc, err
+	case "PrivateDescriptor":
+		var desc PrivateDescriptor
+		if err := d.DecodeElement(&desc, &start); err != nil {
+			return nil, err
+		}
+		return registeredDescriptor(desc)
+	}
+	return nil, d.Skip()
+}
+
+// commandJSON holds exactly one command, keyed by its type.
+type commandJSON struct {
+	SpliceNull           *struct{}          `json:"spliceNull,omitempty"`
+	SpliceSchedule       *scheduleDoc       `json:"spliceSchedule,omitempty"`
+	SpliceInsert         *Insert            `json:"spliceInsert,omitempty"`
+	TimeSignal           *timeSignalDoc     `json:"timeSignal,omitempty"`
+	BandwidthReservation *struct{}          `json:"bandwidthReservation,omitempty"`
+	PrivateCommand       *privateCommandDoc `json:"privateCommand,omitempty"`
+}
+
+type scheduleDoc struct {
+	Events []Event `xml:"Event" json:"events"`
+}
+
+type timeSignalDoc struct {
+	SpliceTime spliceTimeDoc `xml:"SpliceTime" json:"spliceTime"`
+}
+
+type spliceTimeDoc struct {
+	PTSTime *uint64 `xml:"ptsTime,attr,omitempty" json:"ptsTime,omitempty"`
+}
+
+type privateCommandDoc struct {
+	Identifier uint32 `xml:"identifier,attr" json:"identifier"`
+	Bytes      string `xml:"PrivateBytes" json:"privateBytes"`
+}
+
+func (c Command) doc() (commandJSON, error) {
+	var doc commandJSON
+	switch c.Type {
+	case SpliceNull:
+		doc.SpliceNull = &struct{}{}
+	case SpliceSchedule:
+		doc.SpliceSchedule = &scheduleDoc{c.Schedule}
+	case SpliceInsert:
+		if c.Insert == nil {
+			return doc, fmt.Errorf("nil Insert")
+		}
+		doc.SpliceInsert = c.Insert
+	case TimeSignal:
+		doc.TimeSignal = &timeSignalDoc{spliceTimeDoc{c.TimeSignal}}
+	case BandwidthReservation:
+		doc.BandwidthReservation = &struct{}{}
+	case Private:
+		if c.Private == nil {
+			return doc, fmt.Errorf("nil Private")
+		}
+		doc.PrivateCommand = &privateCommandDoc{c.Private.ID, hex.EncodeToString(c.Private.Data)}
+	default:
+		return doc, fmt.Errorf("command type %s: %w", c.Type, ErrUnsupported)
+	}
+	return doc, nil
+}
+
+func (c *Command) setDoc(doc commandJSON) error {
+	*c = Command{}
+	switch {
+	case doc.SpliceNull != nil:
+		c.Type = SpliceNull
+	case doc.SpliceSchedule != nil:
+		c.Type = SpliceSchedule
+		c.Schedule = doc.SpliceSchedule.Events
+	case doc.SpliceInsert != nil:
+		c.Type = SpliceInsert
+		c.Insert = doc.SpliceInsert
+	case doc.TimeSignal != nil:
+		c.Type = TimeSignal
+		c.TimeSignal = doc.TimeSignal.SpliceTime.PTSTime
+	case doc.BandwidthReservation != nil:
+		c.Type = BandwidthReservation
+	case doc.PrivateCommand != nil:
+		b, err := hex.DecodeString(doc.PrivateCommand.Bytes)
+		if err != nil {
+			return fmt.Errorf("private bytes: %w", err)
+		}
+		c.Type = Private
+		c.Private = &PrivateCommand{ID: doc.PrivateCommand.Identifier, Data: b}
+	default:
+		return fmt.Errorf("unknown command")
+	}
+	return nil
+}
+
+// MarshalXML encodes c as the element for its type, such as SpliceInsert.
+func (c Command) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	doc, err := c.doc()
+	if err != nil {
+		return err
+	}
+	switch {
+	case doc.SpliceNull != nil:
+		return e.EncodeElement(doc.SpliceNull, element("SpliceNull"))
+	case doc.SpliceSchedule != nil:
+		return e.EncodeElement(doc.SpliceSchedule, element("SpliceSchedule"))
+	case doc.SpliceInsert != nil:
+		return e.EncodeElement(doc.SpliceInsert, element("SpliceInsert"))
+	case doc.TimeSignal != nil:
+		return e.EncodeElement(doc.TimeSignal, element("TimeSignal"))
+	case doc.BandwidthReservation != nil:
+		return e.EncodeElement(doc.BandwidthReservation, element("BandwidthReservation"))
+	}
+	return e.EncodeElement(doc.PrivateCommand, element("PrivateCommand"))
+}
+
+func (c *Command) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var doc commandJSON
+	var err error
+	switch start.Name.Local {
+	case "SpliceNull":
+		doc.SpliceNull = &struct{}{}
+		err = d.Skip()
+	case "SpliceSchedule":
+		doc.SpliceSchedule = &scheduleDoc{}
+		err = d.DecodeElement(doc.SpliceSchedule, &start)
+	case "SpliceInsert":
+		doc.SpliceInsert = &Insert{}
+		err = d.DecodeElement(doc.SpliceInsert, &start)
+	case "TimeSignal":
+		doc.TimeSignal = &timeSignalDoc{}
+		err = d.DecodeElement(doc.TimeSignal, &start)
+	case "BandwidthReservation":
+		doc.BandwidthReservation = &struct{}{}
+		err = d.Skip()
+	case "PrivateCommand":
+		doc.PrivateCommand = &privateCommandDoc{}
+		err = d.DecodeElement(doc.PrivateCommand, &start)
+	default:
+		return fmt.Errorf("unknown command element %s", start.Name.Local)
+	}
+	if err != nil {
+		return err
+	}
+	return c.setDoc(doc)
+}
+
+// MarshalJSON encodes c as a JSON object with a single member
+// named by its type, such as "spliceInsert".
+func (c Command) MarshalJSON() ([]byte, error) {
+	doc, err := c.doc()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func (c *Command) UnmarshalJSON(b []byte) error {
+	var doc commandJSON
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	return c.setDoc(doc)
+}
+
+type breakDurationDoc struct {
+	AutoReturn bool   `xml:"autoReturn,attr" json:"autoReturn"`
+	Duration   uint64 `xml:"duration,attr" json:"duration"`
+}
+
+func newBreakDurationDoc(bd *BreakDuration) *breakDurationDoc {
+	if bd == nil {
+		return nil
+	}
+	return &breakDurationDoc{bd.AutoReturn, bd.Duration}
+}
+
+func (doc *breakDurationDoc) breakDuration() *BreakDuration {
+	if doc == nil {
+		return nil
+	}
+	return &BreakDuration{doc.AutoReturn, doc.Duration}
+}
+
+type insertDoc struct {
+	ID            uint32               `xml:"spliceEventId,attr" json:"spliceEventId"`
+	Cancel        bool                 `xml:"spliceEventCancelIndicator,attr,omitempty" json:"spliceEventCancelIndicator,omitempty"`
+	IDCompliance  bool                 `xml:"eventIdComplianceFlag,attr,omitempty" json:"eventIdComplianceFlag,omitempty"`
+	OutOfNetwork  bool                 `xml:"outOfNetworkIndicator,attr,omitempty" json:"outOfNetworkIndicator,omitempty"`
+	Immediate     bool                 `xml:"spliceImmediateFlag,attr,omitempty" json:"spliceImmediateFlag,omitempty"`
+	ProgramID     uint16               `xml:"uniqueProgramId,attr,omitempty" json:"uniqueProgramId,omitempty"`
+	AvailNum      uint8                `xml:"availNum,attr,omitempty" json:"availNum,omitempty"`
+	AvailExpected uint8                `xml:"availsExpected,attr,omitempty" json:"availsExpected,omitempty"`
+	Program       *insertProgramDoc    `xml:"Program" json:"program,omitempty"`
+	Components    []insertComponentDoc `xml:"Component" json:"components,omitempty"`
+	BreakDuration *breakDurationDoc    `xml:"BreakDuration" json:"breakDuration,omitempty"`
+}
+
+type insertProgramDoc struct {
+	SpliceTime *spliceTimeDoc `xml:"SpliceTime" json:"spliceTime,omitempty"`
+}
+
+type insertComponentDoc struct {
+	Tag        uint8          `xml:"componentTag,attr" json:"componentTag"`
+	SpliceTime *spliceTimeDoc `xml:"SpliceTime" json:"spliceTime,omitempty"`
+}
+
+func (ins Insert) doc() insertDoc {
+	doc := insertDoc{
+		ID:           ins.ID,
+		Cancel:       ins.Cancel,
+		IDCompliance: ins.idCompliance,
+	}
+	if ins.Cancel {
+		return doc
+	}
+	doc.OutOfNetwork = ins.OutOfNetwork
+	doc.Immediate = ins.Immediate
+	doc.ProgramID = ins.ProgramID
+	doc.AvailNum = ins.AvailNum
+	doc.AvailExpected = ins.AvailExpected
+	doc.BreakDuration = newBreakDurationDoc(ins.Duration)
+	if len(ins.Components) == 0 {
+		doc.Program = &insertProgramDoc{}
+		if !ins.Immediate {
+			doc.Program.SpliceTime = &spliceTimeDoc{ins.SpliceTime}
+		}
+	}
+	for _, c := range ins.Components {
+		cdoc := insertComponentDoc{Tag: c.Tag}
+		if !ins.Immediate {
+			cdoc.SpliceTime = &spliceTimeDoc{c.SpliceTime}
+		}
+		doc.Components = append(doc.Components, cdoc)
+	}
+	return doc
+}
+
+func (ins *Insert) setDoc(doc insertDoc) {
+	*ins = Insert{
+		ID:            doc.ID,
+		Cancel:        doc.Cancel,
+		OutOfNetwork:  doc.OutOfNetwork,
+		Immediate:     doc.Immediate,
+		Duration:      doc.BreakDuration.breakDuration(),
+		ProgramID:     doc.ProgramID,
+		AvailNum:      doc.AvailNum,
+		AvailExpected: doc.AvailExpected,
+		idCompliance:  doc.IDCompliance,
+	}
+	if doc.Program != nil && doc.Program.SpliceTime != nil {
+		ins.SpliceTime = doc.Program.SpliceTime.PTSTime
+	}
+	for _, cdoc := range doc.Components {
+		c := Component{Tag: cdoc.Tag}
+		if cdoc.SpliceTime != nil {
+			c.SpliceTime = cdoc.SpliceTime.PTSTime
+		}
+		ins.Components = append(ins.Components, c)
+	}
+}
+
+func (ins Insert) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(ins.doc(), element("SpliceInsert"))
+}
+
+func (ins *Insert) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var doc insertDoc
+	if err := d.DecodeElement(&doc, &start); err != nil {
+		return err
+	}
+	ins.setDoc(doc)
+	return nil
+}
+
+func (ins Insert) MarshalJSON() ([]byte, error) { return json.Marshal(ins.doc()) }
+
+func (ins *Insert) UnmarshalJSON(b []byte) error {
+	var doc insertDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	ins.setDoc(doc)
+	return nil
+}
+
+type eventDoc struct {
+	ID            uint32              `xml:"spliceEventId,attr" json:"spliceEventId"`
+	Cancel        bool                `xml:"spliceEventCancelIndicator,attr,omitempty" json:"spliceEventCancelIndicator,omitempty"`
+	IDCompliance  bool                `xml:"eventIdComplianceFlag,attr,omitempty" json:"eventIdComplianceFlag,omitempty"`
+	OutOfNetwork  bool                `xml:"outOfNetworkIndicator,attr,omitempty" json:"outOfNetworkIndicator,omitempty"`
+	ProgramID     uint16              `xml:"uniqueProgramId,attr,omitempty" json:"uniqueProgramId,omitempty"`
+	AvailNum      uint8               `xml:"availNum,attr,omitempty" json:"availNum,omitempty"`
+	AvailExpected uint8               `xml:"availsExpected,attr,omitempty" json:"availsExpected,omitempty"`
+	Program       *eventProgramDoc    `xml:"Program" json:"program,omitempty"`
+	Components    []eventComponentDoc `xml:"Component" json:"components,omitempty"`
+	BreakDuration *breakDurationDoc   `xml:"BreakDuration" json:"breakDuration,omitempty"`
+}
+
+// utcSpliceTime is written as seconds since the GPS epoch,
+// as in the binary encoding.
+type eventProgramDoc struct {
+	UTCSpliceTime uint32 `xml:"utcSpliceTime,attr" json:"utcSpliceTime"`
+}
+
+type eventComponentDoc struct {
+	Tag           uint8  `xml:"componentTag,attr" json:"componentTag"`
+	UTCSpliceTime uint32 `xml:"utcSpliceTime,attr" json:"utcSpliceTime"`
+}
+
+func (ev Event) doc() eventDoc {
+	doc := eventDoc{
+		ID:           ev.ID,
+		Cancel:       ev.Cancel,
+		IDCompliance: ev.idCompliance,
+	}
+	if ev.Cancel {
+		return doc
+	}
+	doc.OutOfNetwork = ev.OutOfNetwork
+	doc.ProgramID = ev.ProgramID
+	doc.AvailNum = ev.AvailNum
+	doc.AvailExpected = ev.AvailExpected
+	doc.BreakDuration = newBreakDurationDoc(ev.BreakDuration)
+	if len(ev.Components) == 0 {
+		doc.Program = &eventProgramDoc{gpsSeconds(ev.SpliceTime)}
+	}
+	for _, c := range ev.Components {
+		doc.Components = append(doc.Components, eventComponentDoc{c.Tag, gpsSeconds(c.SpliceTime)})
+	}
+	return doc
+}
+
+func (ev *Event) setDoc(doc eventDoc) {
+	*ev = Event{
+		ID:            doc.ID,
+		Cancel:        doc.Cancel,
+		OutOfNetwork:  doc.OutOfNetwork,
+		BreakDuration: doc.BreakDuration.breakDuration(),
+		ProgramID:     doc.ProgramID,
+		AvailNum:      doc.AvailNum,
+		AvailExpected: doc.AvailExpected,
+		idCompliance:  doc.IDCompliance,
+	}
+	if doc.Program != nil {
+		ev.SpliceTime = fromGPSSeconds(doc.Program.UTCSpliceTime)
+	}
+	for _, c := range doc.Components {
+		ev.Components = append(ev.Components, EventComponent{c.Tag, fromGPSSeconds(c.UTCSpliceTime)})
+	}
+}
+
+func (ev Event) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(ev.doc(), element("Event"))
+}
+
+func (ev *Event) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var doc eventDoc
+	if err := d.DecodeElement(&doc, &start); err != nil {
+		return err
+	}
+	ev.setDoc(doc)
+	return nil
+}
+
+func (ev Event) MarshalJSON() ([]byte, error) { return json.Marshal(ev.doc()) }
+
+func (ev *Event) UnmarshalJSON(b []byte) error {
+	var doc eventDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	ev.setDoc(doc)
+	return nil
+}
+
+type availDoc struct {
+	ProviderAvailID uint32 `xml:"providerAvailId,attr" json:"providerAvailId"`
+}
+
+func (d AvailDescriptor) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(availDoc{uint32(d)}, element("AvailDescriptor"))
+}
+
+func (d *AvailDescriptor) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var doc availDoc
+	if err := dec.DecodeElement(&doc, &start); err != nil {
+		return err
+	}
+	*d = AvailDescriptor(doc.ProviderAvailID)
+	return nil
+}
+
+func (d AvailDescriptor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(availDoc{uint32(d)})
+}
+
+func (d *AvailDescriptor) UnmarshalJSON(b []byte) error {
+	var doc availDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	*d = AvailDescriptor(doc.ProviderAvailID)
+	return nil
+}
+
+type dtmfDoc struct {
+	Preroll uint8  `xml:"preroll,attr" json:"preroll"`
+	Chars   string `xml:"chars,attr" json:"chars"`
+}
+
+func (d DTMFDescriptor) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(dtmfDoc{d.Preroll, string(d.Chars)}, element("DTMFDescriptor"))
+}
+
+func (d *DTMFDescriptor) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var doc dtmfDoc
+	if err := dec.DecodeElement(&doc, &start); err != nil {
+		return err
+	}
+	*d = DTMFDescriptor{doc.Preroll, []byte(doc.Chars)}
+	return nil
+}
+
+func (d DTMFDescriptor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(dtmfDoc{d.Preroll, string(d.Chars)})
+}
+
+func (d *DTMFDescriptor) UnmarshalJSON(b []byte) error {
+	var doc dtmfDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	*d = DTMFDescriptor{doc.Preroll, []byte(doc.Chars)}
+	return nil
+}
+
+type segmentationDoc struct {
+	EventID      uint32  `xml:"segmentationEventId,attr" json:"segmentationEventId"`
+	Cancel       bool    `xml:"segmentationEventCancelIndicator,attr,omitempty" json:"segmentationEventCancelIndicator,omitempty"`
+	IDCompliance bool    `xml:"segmentationEventIdComplianceIndicator,attr,omitempty" json:"segmentationEventIdComplianceIndicator,omitempty"`
+	Duration     *uint64 `xml:"segmentationDuration,attr,omitempty" json:"segmentationDuration,omitempty"`
+	TypeID       uint8   `xml:"segmentationTypeId,attr,omitempty" json:"segmentationTypeId,omitempty"`
+	Num          uint8   `xml:"segmentNum,attr,omitempty" json:"segmentNum,omitempty"`
+	Expected     uint8   `xml:"segmentsExpected,attr,omitempty" json:"segmentsExpected,omitempty"`
+	SubNum       uint8   `xml:"subSegmentNum,attr,omitempty" json:"subSegmentNum,omitempty"`
+	SubExpected  uint8   `xml:"subSegmentsExpected,attr,omitempty" json:"subSegmentsExpected,omitempty"`
+
+	Restrictions *restrictionsDoc           `xml:"DeliveryRestrictions" json:"deliveryRestrictions,omitempty"`
+	UPID         *upidDoc                   `xml:"SegmentationUpid" json:"segmentationUpid,omitempty"`
+	Components   []segmentationComponentDoc `xml:"Component" json:"components,omitempty"`
+}
+
+type restrictionsDoc struct {
+	WebDeliveryAllowed bool  `xml:"webDeliveryAllowedFlag,attr" json:"webDeliveryAllowedFlag"`
+	NoRegionalBlackout bool  `xml:"noRegionalBlackoutFlag,attr" json:"noRegionalBlackoutFlag"`
+	ArchiveAllowed     bool  `xml:"archiveAllowedFlag,attr" json:"archiveAllowedFlag"`
+	DeviceRestrictions uint8 `xml:"deviceRestrictions,attr" json:"deviceRestrictions"`
+}
+
+type upidDoc struct {
+	Type UPIDType `xml:"segmentationUpidType,attr" json:"segmentationUpidType"`
+	// Format is one of "hexbinary", "base-64" or "text".
+	// Values are always marshalled as hexbinary.
+	Format string `xml:"segmentationUpidFormat,attr,omitempty" json:"segmentationUpidFormat,omitempty"`
+	Value  string `xml:",chardata" json:"value"`
+}
+
+type segmentationComponentDoc struct {
+	Tag       uint8  `xml:"componentTag,attr" json:"componentTag"`
+	PTSOffset uint64 `xml:"ptsOffset,attr" json:"ptsOffset"`
+}
+
+func (d SegmentationDescriptor) doc() segmentationDoc {
+	doc := segmentationDoc{
+		EventID:      d.EventID,
+		Cancel:       d.Cancel,
+		IDCompliance: d.idCompliance,
+	}
+	if d.Cancel {
+		return doc
+	}
+	doc.Duration = d.Duration
+	doc.TypeID = d.Type
+	doc.Num = d.Number
+	doc.Expected = d.Expected
+	doc.SubNum = d.SubNumber
+	doc.SubExpected = d.SubExpected
+	if r := d.Restrictions; r != nil {
+		doc.Restrictions = &restrictionsDoc{
+			WebDeliveryAllowed: *r&WebDeliveryAllowed > 0,
+			NoRegionalBlackout: *r&NoRegionalBlackout > 0,
+			ArchiveAllowed:     *r&ArchiveAllowed > 0,
+			DeviceRestrictions: uint8(*r & DeviceRestrictionsNone),
+		}
+	}
+	doc.UPID = &upidDoc{
+		Type:   d.UPID.Type,
+		Format: "hexbinary",
+		Value:  strings.ToUpper(hex.EncodeToString(d.UPID.Value)),
+	}
+	for _, c := range d.Components {
+		doc.Components = append(doc.Components, segmentationComponentDoc{c.Tag, c.PTSOffset})
+	}
+	return doc
+}
+
+func (d *SegmentationDescriptor) setDoc(doc segmentationDoc) error {
+	*d = SegmentationDescriptor{
+		EventID:      doc.EventID,
+		Cancel:       doc.Cancel,
+		Duration:     doc.Duration,
+		Type:         doc.TypeID,
+		Number:       doc.Num,
+		Expected:     doc.Expected,
+		SubNumber:    doc.SubNum,
+		SubExpected:  doc.SubExpected,
+		idCompliance: doc.IDCompliance,
+	}
+	if r := doc.Restrictions; r != nil {
+		restrictions := DeliveryRestrictions(r.DeviceRestrictions & DeviceRestrictionsNone)
+		if r.WebDeliveryAllowed {
+			restrictions |= WebDeliveryAllowed
+		}
+		if r.NoRegionalBlackout {
+			restrictions |= NoRegionalBlackout
+		}
+		if r.ArchiveAllowed {
+			restrictions |= ArchiveAllowed
+		}
+		d.Restrictions = &restrictions
+	}
+	if doc.UPID != nil {
+		value, err := decodeUPIDValue(doc.UPID.Format, doc.UPID.Value)
+		if err != nil {
+			return fmt.Errorf("segmentation upid: %w", err)
+		}
+		d.UPID = UPID{Type: doc.UPID.Type, Value: value}
+	}
+	for _, c := range doc.Components {
+		d.Components = append(d.Components, SegmentationComponent{c.Tag, c.PTSOffset})
+	}
+	return nil
+}
+
+func decodeUPIDValue(format, s string) ([]byte, error) {
+	switch format {
+	case "", "hexbinary":
+		return hex.DecodeString(strings.TrimSpace(s))
+	case "base-64":
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	case "text":
+		return []byte(s), nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func (d SegmentationDescriptor) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(d.doc(), element("SegmentationDescriptor"))
+}
+
+func (d *SegmentationDescriptor) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var doc segmentationDoc
+	if err := dec.DecodeElement(&doc, &start); err != nil {
+		return err
+	}
+	return d.setDoc(doc)
+}
+
+func (d SegmentationDescriptor) MarshalJSON() ([]byte, error) { return json.Marshal(d.doc()) }
+
+func (d *SegmentationDescriptor) UnmarshalJSON(b []byte) error {
+	var doc segmentationDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	return d.setDoc(doc)
+}
+
+type timeDoc struct {
+	TAISeconds uint64 `xml:"taiSeconds,attr" json:"taiSeconds"`
+	TAINs      uint32 `xml:"taiNs,attr" json:"taiNs"`
+	UTCOffset  uint16 `xml:"utcOffset,attr" json:"utcOffset"`
+}
+
+func (d TimeDescriptor) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(timeDoc{d.Seconds, d.Nanoseconds, d.UTCOffset}, element("TimeDescriptor"))
+}
+
+func (d *TimeDescriptor) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var doc timeDoc
+	if err := dec.DecodeElement(&doc, &start); err != nil {
+		return err
+	}
+	*d = TimeDescriptor{doc.TAISeconds, doc.TAINs, doc.UTCOffset}
+	return nil
+}
+
+func (d TimeDescriptor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timeDoc{d.Seconds, d.Nanoseconds, d.UTCOffset})
+}
+
+func (d *TimeDescriptor) UnmarshalJSON(b []byte) error {
+	var doc timeDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	*d = TimeDescriptor{doc.TAISeconds, doc.TAINs, doc.UTCOffset}
+	return nil
+}
+
+type audioDoc struct {
+	Channels []audioChannelDoc `xml:"AudioChannel" json:"audioChannels"`
+}
+
+type audioChannelDoc struct {
+	ComponentTag  uint8  `xml:"componentTag,attr" json:"componentTag"`
+	ISOCode       string `xml:"ISOCode,attr" json:"ISOCode"`
+	BitStreamMode uint8  `xml:"BitStreamMode,attr" json:"BitStreamMode"`
+	NumChannels   uint8  `xml:"NumChannels,attr" json:"NumChannels"`
+	FullSrvcAudio bool   `xml:"FullSrvcAudio,attr" json:"FullSrvcAudio"`
+}
+
+func (d AudioDescriptor) doc() audioDoc {
+	var doc audioDoc
+	for _, ch := range d {
+		doc.Channels = append(doc.Channels, audioChannelDoc{
+			ComponentTag:  ch.ComponentTag,
+			ISOCode:       string(ch.Language[:]),
+			BitStreamMode: ch.BitstreamMode,
+			NumChannels:   uint8(ch.Count) >> 4,
+			FullSrvcAudio: ch.FullService,
+		})
+	}
+	return doc
+}
+
+func (d *AudioDescriptor) setDoc(doc audioDoc) {
+	*d = nil
+	for _, c := range doc.Channels {
+		ch := AudioChannel{
+			ComponentTag:  c.ComponentTag,
+			BitstreamMode: c.BitStreamMode,
+			Count:         NumChannels(c.NumChannels << 4),
+			FullService:   c.FullSrvcAudio,
+		}
+		copy(ch.Language[:], c.ISOCode)
+		*d = append(*d, ch)
+	}
+}
+
+func (d AudioDescriptor) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(d.doc(), element("AudioDescriptor"))
+}
+
+func (d *AudioDescriptor) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var doc audioDoc
+	if err := dec.DecodeElement(&doc, &start); err != nil {
+		return err
+	}
+	d.setDoc(doc)
+	return nil
+}
+
+func (d AudioDescriptor) MarshalJSON() ([]byte, error) { return json.Marshal(d.doc()) }
+
+func (d *AudioDescriptor) UnmarshalJSON(b []byte) error {
+	var doc audioDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	d.setDoc(doc)
+	return nil
+}
+
+// privateDescriptorDoc is not part of the SCTE 35 XML schema,
+// but lets private descriptors be exchanged without loss.
+type privateDescriptorDoc struct {
+	Tag        uint8  `xml:"tag,attr" json:"tag"`
+	Identifier uint32 `xml:"identifier,attr" json:"identifier"`
+	Data       string `xml:",chardata" json:"data"`
+}
+
+func (d PrivateDescriptor) doc() privateDescriptorDoc {
+	return privateDescriptorDoc{d.PTag, d.PID, hex.EncodeToString(d.PData)}
+}
+
+func (d *PrivateDescriptor) setDoc(doc privateDescriptorDoc) error {
+	b, err := hex.DecodeString(strings.TrimSpace(doc.Data))
+	if err != nil {
+		return fmt.Errorf("private descriptor data: %w", err)
+	}
+	*d = PrivateDescriptor{doc.Tag, doc.Identifier, b}
+	return nil
+}
+
+func (d PrivateDescriptor) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return e.EncodeElement(d.doc(), element("PrivateDescriptor"))
+}
+
+func (d *PrivateDescriptor) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var doc privateDescriptorDoc
+	if err := dec.DecodeElement(&doc, &start); err != nil {
+		return err
+	}
+	return d.setDoc(doc)
+}
+
+func (d PrivateDescriptor) MarshalJSON() ([]byte, error) { return json.Marshal(d.doc()) }
+
+func (d *PrivateDescriptor) UnmarshalJSON(b []byte) error {
+	var doc privateDescriptorDoc
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	return d.setDoc(doc)
+}
+
+func parseUint8(s string) (uint8, error) {
+	n, err := strconv.ParseUint(s, 10, 8)
+	return uint8(n), err
+}
@@ -0,0 +1,200 @@
+package scte35
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	var splices []Splice
+	for _, tt := range samples {
+		splices = append(splices, tt.want)
+	}
+	for _, tt := range commandTests {
+		cmd := tt.want
+		splices = append(splices, Splice{Tier: maxTier, Command: &cmd})
+	}
+	splices = append(splices, Splice{
+		Tier: maxTier,
+		Command: &Command{
+			Type:    Private,
+			Private: &PrivateCommand{ID: 0x43554549, Data: []byte{1, 2, 3}},
+		},
+		Descriptors: []SpliceDescriptor{
+			TimeDescriptor{Seconds: 1700000000, Nanoseconds: 500, UTCOffset: 37},
+			AudioDescriptor{{ComponentTag: 0xff, Language: [3]byte{'e', 'n', 'g'}, BitstreamMode: 2, Count: TwoChan, FullService: true}},
+			PrivateDescriptor{PTag: 0xf0, PID: 0x12345678, PData: []byte{0xde, 0xad}},
+			// the most restrictive delivery is all flags unset.
+			SegmentationDescriptor{EventID: 1, Restrictions: restrict(0), Type: ProgramStart},
+		},
+	})
+
+	for i := range splices {
+		want, err := Encode(&splices[i])
+		if err != nil {
+			t.Fatalf("encode splice %d: %v", i, err)
+		}
+		t.Run("xml", func(t *testing.T) {
+			b, err := xml.Marshal(splices[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			var splice Splice
+			if err := xml.Unmarshal(b, &splice); err != nil {
+				t.Fatalf("unmarshal %s: %v", b, err)
+			}
+			got, err := Encode(&splice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("round trip of %s: want %#x, got %#x", b, want, got)
+			}
+		})
+		t.Run("json", func(t *testing.T) {
+			b, err := json.Marshal(splices[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			var splice Splice
+			if err := json.Unmarshal(b, &splice); err != nil {
+				t.Fatalf("unmarshal %s: %v", b, err)
+			}
+			got, err := Encode(&splice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("round trip of %s: want %#x, got %#x", b, want, got)
+			}
+		})
+	}
+}
+
+func TestUnmarshalXML(t *testing.T) {
+	// Adapted from SCTE 35 section 14.2.
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<SpliceInfoSection xmlns="urn:scte:scte35:2013:xml" ptsAdjustment="0" protocolVersion="0" sapType="3" tier="4095">
+	<SpliceInsert spliceEventId="1207959695" spliceEventCancelIndicator="false" outOfNetworkIndicator="true" uniqueProgramId="0" availNum="0" availsExpected="0" spliceImmediateFlag="false">
+		<Program><SpliceTime ptsTime="1936310318"/></Program>
+		<BreakDuration autoReturn="true" duration="5426421"/>
+	</SpliceInsert>
+	<AvailDescriptor providerAvailId="309"/>
+	<SegmentationDescriptor segmentationEventId="1207959694" segmentationEventCancelIndicator="false" segmentationTypeId="52" segmentNum="2" segmentsExpected="0">
+		<DeliveryRestrictions webDeliveryAllowedFlag="false" noRegionalBlackoutFlag="true" archiveAllowedFlag="true" deviceRestrictions="3"/>
+		<SegmentationUpid segmentationUpidType="8" segmentationUpidFormat="hexbinary">000000002CA0A18A</SegmentationUpid>
+	</SegmentationDescriptor>
+</SpliceInfoSection>`
+	want := Splice{
+		SAPType: SAPNone,
+		Tier:    maxTier,
+		Command: &Command{
+			Type: SpliceInsert,
+			Insert: &Insert{
+				ID:           0x4800008f,
+				OutOfNetwork: true,
+				SpliceTime:   newuint64(0x07369c02e),
+				Duration:     &BreakDuration{AutoReturn: true, Duration: 0x00052ccf5},
+			},
+		},
+		Descriptors: []SpliceDescriptor{
+			AvailDescriptor(0x135),
+			SegmentationDescriptor{
+				EventID:      0x4800008e,
+				Restrictions: restrict(NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone),
+				UPID:         NewTI(0x2ca0a18a),
+				Type:         ProviderPlacementOppStart,
+				Number:       2,
+			},
+		},
+	}
+	var got Splice
+	if err := xml.Unmarshal([]byte(doc), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestMarshalXMLNamespace(t *testing.T) {
+	b, err := xml.Marshal(samples[1].want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := `<SpliceInfoSection xmlns="` + Namespace + `"`
+	if !strings.HasPrefix(string(b), prefix) {
+		t.Errorf("%s does not begin with %s", b, prefix)
+	}
+	if !strings.Contains(string(b), "<SpliceInsert ") {
+		t.Errorf("%s has no SpliceInsert element", b)
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	ev := Event{
+		ID:           1,
+		OutOfNetwork: true,
+		SpliceTime:   time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	b, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"spliceEventId":1,"outOfNetworkIndicator":true,"program":{"utcSpliceTime":1388145600}}`
+	if string(b) != want {
+		t.Errorf("want %s, got %s", want, b)
+	}
+	var got Event
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, ev) {
+		t.Errorf("want %+v, got %+v", ev, got)
+	}
+}
+
+func TestEncodingHelpers(t *testing.T) {
+	for _, tt := range samples {
+		splice, err := DecodeBase64(tt.encoded)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		// Compare against our own encoding, not the sample's;
+		// samples may set unused fields such as cw_index differently.
+		want, err := Encode(splice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := EncodeBase64(splice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != base64.StdEncoding.EncodeToString(want) {
+			t.Errorf("%s: base64: want %#x, got %s", tt.name, want, s)
+		}
+		h, err := EncodeHex(splice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, in := range []string{h, strings.ToUpper(h[2:])} {
+			splice, err := DecodeHex(in)
+			if err != nil {
+				t.Fatalf("%s: decode hex %s: %v", tt.name, in, err)
+			}
+			got, err := Encode(splice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: hex round trip: want %#x, got %#x", tt.name, want, got)
+			}
+		}
+	}
+}
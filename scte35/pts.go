@@ -21,3 +21,15 @@ func putPTS(buf []byte, ticks uint64) {
 	buf[0] |= pts[0]
 	copy(buf[1:5], pts[1:5])
 }
+
+// maxPTS is the largest value of a 33-bit presentation timestamp.
+// Timestamps wrap to zero after maxPTS.
+const maxPTS = 1<<33 - 1
+
+// ptsBefore reports whether a occurs before b, allowing for b
+// having wrapped past maxPTS. Timestamps more than half the range
+// of a PTS apart are assumed to have wrapped.
+func ptsBefore(a, b uint64) bool {
+	d := (b - a) & maxPTS
+	return d != 0 && d < 1<<32
+}
@@ -0,0 +1,203 @@
+package scte104
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultAddr is the address on which injectors listen for
+// connections from automation systems.
+const DefaultAddr = ":5167"
+
+// ResultError is returned when a peer responds to a request
+// with a result other than ResultSuccess.
+type ResultError struct {
+	OpID            OpID
+	Result          uint16
+	ResultExtension uint16
+}
+
+func (e *ResultError) Error() string {
+	return fmt.Sprintf("%s: result %d (extension %d)", e.OpID, e.Result, e.ResultExtension)
+}
+
+// Client is an automation system's session with an injector.
+// A Client is not safe for concurrent use.
+type Client struct {
+	// ASIndex and DPIPIDIndex are set in each message sent.
+	ASIndex     uint8
+	DPIPIDIndex uint16
+
+	conn   net.Conn
+	msgnum uint8
+}
+
+// Dial connects to the injector at addr
+// and initialises a session with an InitRequest.
+func Dial(network, addr string) (*Client, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn}
+	if _, err := c.roundTrip(InitRequest{}, OpInitResponse); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("init session: %w", err)
+	}
+	return c, nil
+}
+
+func (c *Client) write(m *Message) error {
+	m.ASIndex = c.ASIndex
+	m.DPIPIDIndex = c.DPIPIDIndex
+	m.MessageNumber = c.msgnum
+	c.msgnum++
+	b, err := Encode(m)
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(b)
+	return err
+}
+
+// roundTrip sends op as a single operation message and
+// returns the response, which must be of operation want.
+func (c *Client) roundTrip(op Operation, want OpID) (*Message, error) {
+	if err := c.write(&Message{Operations: []Operation{op}}); err != nil {
+		return nil, err
+	}
+	resp, err := ReadMessage(c.conn)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if got := resp.Operations[0].OpID(); got != want {
+		return nil, fmt.Errorf("unexpected response %s, want %s", got, want)
+	}
+	if resp.Result != ResultSuccess {
+		return nil, &ResultError{want, resp.Result, resp.ResultExtension}
+	}
+	return resp, nil
+}
+
+// Alive sends an AliveRequest and returns the injector's time
+// from its response.
+func (c *Client) Alive() (time.Time, error) {
+	resp, err := c.roundTrip(AliveRequest{time.Now()}, OpAliveResponse)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return resp.Operations[0].(AliveResponse).Time, nil
+}
+
+// Send sends ops in a multiple operation message for immediate
+// execution, then waits for the injector to acknowledge it.
+func (c *Client) Send(ops ...Operation) error {
+	return c.SendMessage(&Message{Operations: ops})
+}
+
+// SendMessage sends m, then waits for the injector to acknowledge it.
+// The ASIndex, DPIPIDIndex and MessageNumber of m are set by c.
+func (c *Client) SendMessage(m *Message) error {
+	if m.single() {
+		return fmt.Errorf("send %s: not a multiple operation message", m.Operations[0].OpID())
+	}
+	if err := c.write(m); err != nil {
+		return err
+	}
+	resp, err := ReadMessage(c.conn)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	inj, ok := resp.Operations[0].(InjectResponse)
+	if !ok {
+		return fmt.Errorf("unexpected response %s, want %s", resp.Operations[0].OpID(), OpInjectResponse)
+	}
+	if inj.MessageNumber != m.MessageNumber {
+		return fmt.Errorf("response to message %d, want %d", inj.MessageNumber, m.MessageNumber)
+	}
+	if resp.Result != ResultSuccess {
+		return &ResultError{OpInjectResponse, resp.Result, resp.ResultExtension}
+	}
+	return nil
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Server is an injector accepting sessions from automation systems.
+// Init and alive requests are answered by the Server.
+// Other single operation messages are ignored.
+type Server struct {
+	// Handler is called with each multiple operation message
+	// received. It returns the result sent in response,
+	// usually ResultSuccess. If nil, all messages succeed.
+	Handler func(m *Message) (result uint16)
+}
+
+// ListenAndServe listens on the TCP network address addr
+// then serves sessions with handler.
+// If addr is empty, DefaultAddr is used.
+func ListenAndServe(addr string, handler func(*Message) uint16) error {
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &Server{Handler: handler}
+	return srv.Serve(ln)
+}
+
+// Serve accepts connections on l, serving each session in a new goroutine.
+// Serve always returns a non-nil error, such as when l is closed.
+func (srv *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go srv.serve(conn)
+	}
+}
+
+func (srv *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		m, err := ReadMessage(conn)
+		if err != nil {
+			return
+		}
+		resp := &Message{
+			Result:          ResultSuccess,
+			ProtocolVersion: m.ProtocolVersion,
+			ASIndex:         m.ASIndex,
+			MessageNumber:   m.MessageNumber,
+			DPIPIDIndex:     m.DPIPIDIndex,
+		}
+		if m.single() {
+			switch m.Operations[0].(type) {
+			case InitRequest:
+				resp.Operations = []Operation{InitResponse{}}
+			case AliveRequest:
+				resp.Operations = []Operation{AliveResponse{time.Now()}}
+			default:
+				continue
+			}
+		} else {
+			if srv.Handler != nil {
+				resp.Result = srv.Handler(m)
+			}
+			resp.Operations = []Operation{InjectResponse{m.MessageNumber}}
+		}
+		b, err := Encode(resp)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(b); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package scte104
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/untangledco/streaming/scte35"
+)
+
+func TestSession(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	splices := make(chan *scte35.Splice, 1)
+	srv := &Server{
+		Handler: func(m *Message) uint16 {
+			splice, err := m.Splice(0)
+			if err != nil {
+				return 0
+			}
+			splices <- splice
+			return ResultSuccess
+		},
+	}
+	go srv.Serve(ln)
+
+	client, err := Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err := client.Alive(); err != nil {
+		t.Errorf("alive: %v", err)
+	}
+
+	req := SpliceRequest{Type: SpliceStartImmediate, EventID: 9, BreakDuration: 15 * time.Second}
+	if err := client.Send(req); err != nil {
+		t.Fatalf("send splice request: %v", err)
+	}
+	splice := <-splices
+	ins := splice.Command.Insert
+	if ins == nil || ins.ID != 9 || !ins.Immediate || !ins.OutOfNetwork {
+		t.Errorf("unexpected splice_insert %+v from %+v", ins, req)
+	}
+
+	// Descriptors alone cannot be converted, so our handler fails.
+	err = client.Send(AvailRequest{[]uint32{1}})
+	var rerr *ResultError
+	if !errors.As(err, &rerr) {
+		t.Errorf("want ResultError, got %v", err)
+	}
+
+	// The session continues after a failed request.
+	if err := client.Send(SpliceNullRequest{}); err != nil {
+		t.Errorf("send splice null: %v", err)
+	}
+	if splice := <-splices; splice.Command.Type != scte35.SpliceNull {
+		t.Errorf("want splice_null command, got %+v", splice.Command)
+	}
+}
@@ -0,0 +1,378 @@
+package scte104
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Operation is a request or response carried in a Message.
+// Operations without a corresponding type in this package
+// are decoded as a RawOperation.
+type Operation interface {
+	OpID() OpID
+	// Data returns the wire format of the operation,
+	// excluding its opID and data_length fields.
+	Data() []byte
+}
+
+// RawOperation is an operation whose data is not interpreted.
+type RawOperation struct {
+	ID      OpID
+	Payload []byte
+}
+
+func (op RawOperation) OpID() OpID   { return op.ID }
+func (op RawOperation) Data() []byte { return op.Payload }
+
+// InitRequest opens a session between an automation system and an injector.
+type InitRequest struct{}
+
+func (InitRequest) OpID() OpID   { return OpInitRequest }
+func (InitRequest) Data() []byte { return nil }
+
+// InitResponse answers an InitRequest.
+// The Message's Result indicates whether the session was accepted.
+type InitResponse struct{}
+
+func (InitResponse) OpID() OpID   { return OpInitResponse }
+func (InitResponse) Data() []byte { return nil }
+
+// AliveRequest checks that the peer of a session is still available.
+// Time is the sender's current time, with microsecond precision.
+type AliveRequest struct {
+	Time time.Time
+}
+
+func (op AliveRequest) OpID() OpID   { return OpAliveRequest }
+func (op AliveRequest) Data() []byte { return appendTime(nil, op.Time) }
+
+// AliveResponse answers an AliveRequest with the responder's current time.
+type AliveResponse struct {
+	Time time.Time
+}
+
+func (op AliveResponse) OpID() OpID   { return OpAliveResponse }
+func (op AliveResponse) Data() []byte { return appendTime(nil, op.Time) }
+
+func appendTime(buf []byte, t time.Time) []byte {
+	d := t.Sub(gpsEpoch)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(d/time.Second))
+	return binary.BigEndian.AppendUint32(buf, uint32(d%time.Second/time.Microsecond))
+}
+
+func readTime(buf []byte) (time.Time, error) {
+	if len(buf) < 8 {
+		return time.Time{}, errShort("time", 8, len(buf))
+	}
+	secs := time.Duration(binary.BigEndian.Uint32(buf)) * time.Second
+	usecs := time.Duration(binary.BigEndian.Uint32(buf[4:])) * time.Microsecond
+	return gpsEpoch.Add(secs + usecs), nil
+}
+
+// InjectResponse acknowledges a multiple operation message.
+// MessageNumber is that of the acknowledged message.
+type InjectResponse struct {
+	MessageNumber uint8
+}
+
+func (op InjectResponse) OpID() OpID   { return OpInjectResponse }
+func (op InjectResponse) Data() []byte { return []byte{op.MessageNumber} }
+
+// SpliceInsertType is the kind of splice requested by a SpliceRequest,
+// as listed in SCTE 104 table 8-6.
+type SpliceInsertType uint8
+
+const (
+	SpliceStartNormal SpliceInsertType = 1 + iota
+	SpliceStartImmediate
+	SpliceEndNormal
+	SpliceEndImmediate
+	SpliceCancel
+)
+
+// SpliceRequest requests a splice_insert command.
+// See SCTE 104 section 8.3.1.
+type SpliceRequest struct {
+	Type    SpliceInsertType
+	EventID uint32
+	// ProgramID is the unique_program_id of the splice.
+	ProgramID uint16
+	// Preroll is the time from the message's Timestamp
+	// until the splice point, with millisecond precision.
+	Preroll time.Duration
+	// BreakDuration has a precision of a tenth of a second.
+	BreakDuration time.Duration
+	AvailNum      uint8
+	AvailExpected uint8
+	AutoReturn    bool
+}
+
+func (op SpliceRequest) OpID() OpID { return OpSpliceRequest }
+
+func (op SpliceRequest) Data() []byte {
+	buf := []byte{byte(op.Type)}
+	buf = binary.BigEndian.AppendUint32(buf, op.EventID)
+	buf = binary.BigEndian.AppendUint16(buf, op.ProgramID)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(op.Preroll/time.Millisecond))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(op.BreakDuration/(time.Second/10)))
+	buf = append(buf, op.AvailNum, op.AvailExpected, boolByte(op.AutoReturn))
+	return buf
+}
+
+func decodeSpliceRequest(buf []byte) (SpliceRequest, error) {
+	if len(buf) < 14 {
+		return SpliceRequest{}, errShort("splice_request_data", 14, len(buf))
+	}
+	return SpliceRequest{
+		Type:          SpliceInsertType(buf[0]),
+		EventID:       binary.BigEndian.Uint32(buf[1:5]),
+		ProgramID:     binary.BigEndian.Uint16(buf[5:7]),
+		Preroll:       time.Duration(binary.BigEndian.Uint16(buf[7:9])) * time.Millisecond,
+		BreakDuration: time.Duration(binary.BigEndian.Uint16(buf[9:11])) * time.Second / 10,
+		AvailNum:      buf[11],
+		AvailExpected: buf[12],
+		AutoReturn:    buf[13] > 0,
+	}, nil
+}
+
+// SpliceNullRequest requests a splice_null command.
+type SpliceNullRequest struct{}
+
+func (SpliceNullRequest) OpID() OpID   { return OpSpliceNullRequest }
+func (SpliceNullRequest) Data() []byte { return nil }
+
+// TimeSignalRequest requests a time_signal command,
+// usually followed by descriptors such as a SegmentationRequest.
+type TimeSignalRequest struct {
+	// Preroll has millisecond precision.
+	Preroll time.Duration
+}
+
+func (op TimeSignalRequest) OpID() OpID { return OpTimeSignalRequest }
+
+func (op TimeSignalRequest) Data() []byte {
+	return binary.BigEndian.AppendUint16(nil, uint16(op.Preroll/time.Millisecond))
+}
+
+// AvailRequest requests an avail_descriptor for each provider avail ID.
+type AvailRequest struct {
+	ProviderAvailIDs []uint32
+}
+
+func (op AvailRequest) OpID() OpID { return OpInsertAvailDescriptor }
+
+func (op AvailRequest) Data() []byte {
+	buf := []byte{uint8(len(op.ProviderAvailIDs))}
+	for _, id := range op.ProviderAvailIDs {
+		buf = binary.BigEndian.AppendUint32(buf, id)
+	}
+	return buf
+}
+
+func decodeAvailRequest(buf []byte) (AvailRequest, error) {
+	var op AvailRequest
+	if len(buf) < 1 {
+		return op, errShort("num_provider_avails", 1, len(buf))
+	}
+	n := int(buf[0])
+	buf = buf[1:]
+	if len(buf) < 4*n {
+		return op, errShort("provider_avail_id", 4*n, len(buf))
+	}
+	for i := 0; i < n; i++ {
+		op.ProviderAvailIDs = append(op.ProviderAvailIDs, binary.BigEndian.Uint32(buf[4*i:]))
+	}
+	return op, nil
+}
+
+// DTMFRequest requests a DTMF_descriptor.
+type DTMFRequest struct {
+	// Preroll is in tenths of a second.
+	Preroll uint8
+	Chars   []byte
+}
+
+func (op DTMFRequest) OpID() OpID { return OpInsertDTMFDescriptor }
+
+func (op DTMFRequest) Data() []byte {
+	buf := []byte{op.Preroll, uint8(len(op.Chars))}
+	return append(buf, op.Chars...)
+}
+
+func decodeDTMFRequest(buf []byte) (DTMFRequest, error) {
+	var op DTMFRequest
+	if len(buf) < 2 {
+		return op, errShort("DTMF header", 2, len(buf))
+	}
+	op.Preroll = buf[0]
+	n := int(buf[1])
+	if len(buf[2:]) < n {
+		return op, errShort("DTMF_char", n, len(buf[2:]))
+	}
+	op.Chars = buf[2 : 2+n]
+	return op, nil
+}
+
+// SegmentationRequest requests a segmentation_descriptor.
+// See SCTE 104 section 8.3.9.
+type SegmentationRequest struct {
+	EventID uint32
+	Cancel  bool
+	// Duration has a precision of one second.
+	// Any remainder is given in DurationFrames.
+	Duration       time.Duration
+	DurationFrames uint8
+	UPIDType       uint8
+	UPID           []byte
+	// Type is the segmentation_type_id.
+	Type     uint8
+	Number   uint8
+	Expected uint8
+
+	DeliveryNotRestricted bool
+	WebDeliveryAllowed    bool
+	NoRegionalBlackout    bool
+	ArchiveAllowed        bool
+	DeviceRestrictions    uint8
+
+	// SubSegments indicates whether SubNumber and SubExpected are set.
+	SubSegments bool
+	SubNumber   uint8
+	SubExpected uint8
+}
+
+func (op SegmentationRequest) OpID() OpID { return OpInsertSegmentation }
+
+func (op SegmentationRequest) Data() []byte {
+	buf := binary.BigEndian.AppendUint32(nil, op.EventID)
+	buf = append(buf, boolByte(op.Cancel))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(op.Duration/time.Second))
+	buf = append(buf, op.UPIDType, uint8(len(op.UPID)))
+	buf = append(buf, op.UPID...)
+	buf = append(buf, op.Type, op.Number, op.Expected, op.DurationFrames)
+	buf = append(buf,
+		boolByte(op.DeliveryNotRestricted),
+		boolByte(op.WebDeliveryAllowed),
+		boolByte(op.NoRegionalBlackout),
+		boolByte(op.ArchiveAllowed),
+		op.DeviceRestrictions,
+	)
+	buf = append(buf, boolByte(op.SubSegments), op.SubNumber, op.SubExpected)
+	return buf
+}
+
+func decodeSegmentationRequest(buf []byte) (SegmentationRequest, error) {
+	var op SegmentationRequest
+	if len(buf) < 9 {
+		return op, errShort("segmentation request", 9, len(buf))
+	}
+	op.EventID = binary.BigEndian.Uint32(buf)
+	op.Cancel = buf[4] > 0
+	op.Duration = time.Duration(binary.BigEndian.Uint16(buf[5:7])) * time.Second
+	op.UPIDType = buf[7]
+	n := int(buf[8])
+	buf = buf[9:]
+	// UPID, then 9 bytes from segmentation_type_id to device_restrictions.
+	if len(buf) < n+9 {
+		return op, errShort("segmentation request", n+9, len(buf))
+	}
+	op.UPID = buf[:n]
+	buf = buf[n:]
+	op.Type = buf[0]
+	op.Number = buf[1]
+	op.Expected = buf[2]
+	op.DurationFrames = buf[3]
+	op.DeliveryNotRestricted = buf[4] > 0
+	op.WebDeliveryAllowed = buf[5] > 0
+	op.NoRegionalBlackout = buf[6] > 0
+	op.ArchiveAllowed = buf[7] > 0
+	op.DeviceRestrictions = buf[8]
+	buf = buf[9:]
+	// Sub-segment fields were added in SCTE 104 2012;
+	// older automation systems omit them.
+	if len(buf) >= 3 {
+		op.SubSegments = buf[0] > 0
+		op.SubNumber = buf[1]
+		op.SubExpected = buf[2]
+	}
+	return op, nil
+}
+
+// TimeRequest requests a time_descriptor.
+type TimeRequest struct {
+	// A 48-bit number of seconds since the Unix epoch according to TAI.
+	TAISeconds     uint64
+	TAINanoseconds uint32
+	UTCOffset      uint16
+}
+
+func (op TimeRequest) OpID() OpID { return OpInsertTimeDescriptor }
+
+func (op TimeRequest) Data() []byte {
+	buf := binary.BigEndian.AppendUint64(nil, op.TAISeconds)[2:]
+	buf = binary.BigEndian.AppendUint32(buf, op.TAINanoseconds)
+	return binary.BigEndian.AppendUint16(buf, op.UTCOffset)
+}
+
+func decodeTimeRequest(buf []byte) (TimeRequest, error) {
+	if len(buf) < 12 {
+		return TimeRequest{}, errShort("insert_time_descriptor", 12, len(buf))
+	}
+	b := make([]byte, 8)
+	copy(b[2:], buf[:6])
+	return TimeRequest{
+		TAISeconds:     binary.BigEndian.Uint64(b),
+		TAINanoseconds: binary.BigEndian.Uint32(buf[6:10]),
+		UTCOffset:      binary.BigEndian.Uint16(buf[10:12]),
+	}, nil
+}
+
+func decodeOperation(id OpID, buf []byte) (Operation, error) {
+	switch id {
+	case OpInitRequest:
+		return InitRequest{}, nil
+	case OpInitResponse:
+		return InitResponse{}, nil
+	case OpAliveRequest:
+		t, err := readTime(buf)
+		return AliveRequest{t}, err
+	case OpAliveResponse:
+		t, err := readTime(buf)
+		return AliveResponse{t}, err
+	case OpInjectResponse:
+		if len(buf) < 1 {
+			return nil, errShort("message_number", 1, len(buf))
+		}
+		return InjectResponse{buf[0]}, nil
+	case OpSpliceRequest:
+		return decodeSpliceRequest(buf)
+	case OpSpliceNullRequest:
+		return SpliceNullRequest{}, nil
+	case OpTimeSignalRequest:
+		if len(buf) < 2 {
+			return nil, errShort("pre_roll_time", 2, len(buf))
+		}
+		ms := binary.BigEndian.Uint16(buf)
+		return TimeSignalRequest{time.Duration(ms) * time.Millisecond}, nil
+	case OpInsertAvailDescriptor:
+		return decodeAvailRequest(buf)
+	case OpInsertDTMFDescriptor:
+		return decodeDTMFRequest(buf)
+	case OpInsertSegmentation:
+		return decodeSegmentationRequest(buf)
+	case OpInsertTimeDescriptor:
+		return decodeTimeRequest(buf)
+	}
+	if id == opMultipleOperation {
+		return nil, fmt.Errorf("reserved opID %#04x", uint16(id))
+	}
+	return RawOperation{id, buf}, nil
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,372 @@
+// Package scte104 implements a subset of the
+// Automation System to Compression System Communications
+// Applications Program Interface as specified in [ANSI/SCTE 104].
+//
+// Playout automation systems send SCTE 104 messages to encoders,
+// which insert the equivalent SCTE 35 splice into the transport stream.
+// See Message.Splice for the conversion.
+//
+// [ANSI/SCTE 104]: https://www.scte.org/standards/library/catalog/scte-104-automation-system-to-compression-system-communications-applications-program-interface/
+package scte104
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// OpID identifies an operation, as listed in
+// SCTE 104 tables 7-1 and 7-2.
+type OpID uint16
+
+// Single operation message IDs.
+const (
+	OpGeneralResponse OpID = 0x0000
+	OpInitRequest     OpID = 0x0001
+	OpInitResponse    OpID = 0x0002
+	OpAliveRequest    OpID = 0x0003
+	OpAliveResponse   OpID = 0x0004
+	OpInjectResponse  OpID = 0x0007
+)
+
+// Multiple operation message IDs.
+const (
+	OpInjectSection          OpID = 0x0100
+	OpSpliceRequest          OpID = 0x0101
+	OpSpliceNullRequest      OpID = 0x0102
+	OpTimeSignalRequest      OpID = 0x0104
+	OpInsertDescriptor       OpID = 0x0108
+	OpInsertDTMFDescriptor   OpID = 0x0109
+	OpInsertAvailDescriptor  OpID = 0x010a
+	OpInsertSegmentation     OpID = 0x010b
+	OpProprietaryCommand     OpID = 0x010c
+	OpInsertTier             OpID = 0x010f
+	OpInsertTimeDescriptor   OpID = 0x0110
+	opMultipleOperationFirst OpID = 0x0100
+	opMultipleOperation      OpID = 0xffff
+)
+
+func (id OpID) String() string {
+	switch id {
+	case OpGeneralResponse:
+		return "general_response_data"
+	case OpInitRequest:
+		return "init_request_data"
+	case OpInitResponse:
+		return "init_response_data"
+	case OpAliveRequest:
+		return "alive_request_data"
+	case OpAliveResponse:
+		return "alive_response_data"
+	case OpInjectResponse:
+		return "inject_response_data"
+	case OpInjectSection:
+		return "inject_section_data_request"
+	case OpSpliceRequest:
+		return "splice_request_data"
+	case OpSpliceNullRequest:
+		return "splice_null_request_data"
+	case OpTimeSignalRequest:
+		return "time_signal_request_data"
+	case OpInsertDescriptor:
+		return "insert_descriptor_request_data"
+	case OpInsertDTMFDescriptor:
+		return "insert_DTMF_descriptor_request_data"
+	case OpInsertAvailDescriptor:
+		return "insert_avail_descriptor_request_data"
+	case OpInsertSegmentation:
+		return "insert_segmentation_descriptor_request_data"
+	case OpProprietaryCommand:
+		return "proprietary_command_request_data"
+	case OpInsertTier:
+		return "insert_tier_data"
+	case OpInsertTimeDescriptor:
+		return "insert_time_descriptor"
+	}
+	return fmt.Sprintf("opID %#04x", uint16(id))
+}
+
+// ResultSuccess is the result of a successful request.
+// Other values are listed in SCTE 104 table 14-1.
+const ResultSuccess uint16 = 100
+
+// ErrShortMessage is returned when a message is shorter than
+// its fields require.
+var ErrShortMessage = errors.New("short message")
+
+func errShort(field string, need, have int) error {
+	return fmt.Errorf("%s: %w: need %d bytes, have %d", field, ErrShortMessage, need, have)
+}
+
+// Message is a SCTE 104 message.
+//
+// A message holding exactly one operation whose ID is less than
+// 0x0100, such as an InitRequest, is a single_operation_message.
+// Other messages are multiple_operation_messages.
+type Message struct {
+	// Result and ResultExtension are only used in single
+	// operation messages, usually responses.
+	Result          uint16
+	ResultExtension uint16
+
+	ProtocolVersion uint8
+	// Index of the automation system sending the message.
+	ASIndex uint8
+	// MessageNumber identifies a message so that responses
+	// may refer to it.
+	MessageNumber uint8
+	// DPIPIDIndex identifies the SCTE 35 stream of a
+	// multiplex to which the message applies.
+	DPIPIDIndex uint16
+
+	// SCTE35ProtocolVersion and Timestamp are only used in
+	// multiple operation messages.
+	SCTE35ProtocolVersion uint8
+	Timestamp             Timestamp
+
+	Operations []Operation
+}
+
+func (m *Message) single() bool {
+	return len(m.Operations) == 1 && m.Operations[0].OpID() < opMultipleOperationFirst
+}
+
+// TimeType is the type of a Timestamp,
+// as specified in SCTE 104 section 11.5.
+type TimeType uint8
+
+const (
+	TimeNone TimeType = iota
+	TimeUTC
+	TimeVITC
+	TimeGPI
+)
+
+// Timestamp indicates when the operations of a multiple operation
+// message should be executed. Which fields are used depends on Type.
+// A Timestamp of type TimeNone means "immediately".
+type Timestamp struct {
+	Type TimeType
+	// UTC is used with TimeUTC. The wire format holds the
+	// fraction of a second in a 16-bit count of microseconds,
+	// so the fraction must be less than 65536 microseconds.
+	UTC time.Time
+	// VITC is used with TimeVITC.
+	VITC Timecode
+	// GPINumber and GPIEdge are used with TimeGPI.
+	GPINumber uint8
+	GPIEdge   uint8
+}
+
+// Timecode is a SMPTE 12M timecode.
+type Timecode struct {
+	Hours   uint8
+	Minutes uint8
+	Seconds uint8
+	Frames  uint8
+}
+
+// gpsEpoch is the epoch of times in SCTE 104 messages,
+// the same as that of SCTE 35.
+var gpsEpoch = time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC)
+
+func appendTimestamp(buf []byte, ts Timestamp) ([]byte, error) {
+	buf = append(buf, byte(ts.Type))
+	switch ts.Type {
+	case TimeNone:
+	case TimeUTC:
+		d := ts.UTC.Sub(gpsEpoch)
+		usecs := d % time.Second / time.Microsecond
+		if usecs > 0xffff {
+			return nil, fmt.Errorf("UTC microseconds %d larger than max %d", usecs, 0xffff)
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(d/time.Second))
+		buf = binary.BigEndian.AppendUint16(buf, uint16(usecs))
+	case TimeVITC:
+		tc := ts.VITC
+		buf = append(buf, tc.Hours, tc.Minutes, tc.Seconds, tc.Frames)
+	case TimeGPI:
+		buf = append(buf, ts.GPINumber, ts.GPIEdge)
+	default:
+		return nil, fmt.Errorf("unknown time type %d", ts.Type)
+	}
+	return buf, nil
+}
+
+func readTimestamp(buf []byte) (Timestamp, []byte, error) {
+	var ts Timestamp
+	if len(buf) < 1 {
+		return ts, nil, errShort("time_type", 1, len(buf))
+	}
+	ts.Type = TimeType(buf[0])
+	buf = buf[1:]
+	var need int
+	switch ts.Type {
+	case TimeNone:
+	case TimeUTC:
+		need = 6
+	case TimeVITC:
+		need = 4
+	case TimeGPI:
+		need = 2
+	default:
+		return ts, nil, fmt.Errorf("unknown time type %d", ts.Type)
+	}
+	if len(buf) < need {
+		return ts, nil, errShort("timestamp", need, len(buf))
+	}
+	switch ts.Type {
+	case TimeUTC:
+		secs := time.Duration(binary.BigEndian.Uint32(buf)) * time.Second
+		usecs := time.Duration(binary.BigEndian.Uint16(buf[4:])) * time.Microsecond
+		ts.UTC = gpsEpoch.Add(secs + usecs)
+	case TimeVITC:
+		ts.VITC = Timecode{buf[0], buf[1], buf[2], buf[3]}
+	case TimeGPI:
+		ts.GPINumber, ts.GPIEdge = buf[0], buf[1]
+	}
+	return ts, buf[need:], nil
+}
+
+// Encode returns the wire format of m.
+func Encode(m *Message) ([]byte, error) {
+	if len(m.Operations) == 0 {
+		return nil, fmt.Errorf("no operations")
+	}
+	var buf []byte
+	if m.single() {
+		op := m.Operations[0]
+		buf = binary.BigEndian.AppendUint16(buf, uint16(op.OpID()))
+		buf = append(buf, 0, 0) // messageSize, set below.
+		buf = binary.BigEndian.AppendUint16(buf, m.Result)
+		buf = binary.BigEndian.AppendUint16(buf, m.ResultExtension)
+		buf = append(buf, m.ProtocolVersion, m.ASIndex, m.MessageNumber)
+		buf = binary.BigEndian.AppendUint16(buf, m.DPIPIDIndex)
+		buf = append(buf, op.Data()...)
+	} else {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(opMultipleOperation))
+		buf = append(buf, 0, 0) // messageSize, set below.
+		buf = append(buf, m.ProtocolVersion, m.ASIndex, m.MessageNumber)
+		buf = binary.BigEndian.AppendUint16(buf, m.DPIPIDIndex)
+		buf = append(buf, m.SCTE35ProtocolVersion)
+		var err error
+		buf, err = appendTimestamp(buf, m.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if len(m.Operations) > 0xff {
+			return nil, fmt.Errorf("%d operations larger than max %d", len(m.Operations), 0xff)
+		}
+		buf = append(buf, uint8(len(m.Operations)))
+		for _, op := range m.Operations {
+			data := op.Data()
+			if len(data) > 0xffff {
+				return nil, fmt.Errorf("%s: data length %d larger than max %d", op.OpID(), len(data), 0xffff)
+			}
+			buf = binary.BigEndian.AppendUint16(buf, uint16(op.OpID()))
+			buf = binary.BigEndian.AppendUint16(buf, uint16(len(data)))
+			buf = append(buf, data...)
+		}
+	}
+	if len(buf) > 0xffff {
+		return nil, fmt.Errorf("message size %d larger than max %d", len(buf), 0xffff)
+	}
+	binary.BigEndian.PutUint16(buf[2:4], uint16(len(buf)))
+	return buf, nil
+}
+
+// Decode decodes a single message from buf.
+func Decode(buf []byte) (*Message, error) {
+	if len(buf) < 4 {
+		return nil, errShort("message header", 4, len(buf))
+	}
+	opID := OpID(binary.BigEndian.Uint16(buf))
+	size := int(binary.BigEndian.Uint16(buf[2:4]))
+	if size != len(buf) {
+		return nil, fmt.Errorf("message size %d does not match buffer length %d", size, len(buf))
+	}
+	var m Message
+	if opID != opMultipleOperation {
+		if len(buf) < 13 {
+			return nil, errShort("single operation header", 13, len(buf))
+		}
+		m.Result = binary.BigEndian.Uint16(buf[4:6])
+		m.ResultExtension = binary.BigEndian.Uint16(buf[6:8])
+		m.ProtocolVersion = buf[8]
+		m.ASIndex = buf[9]
+		m.MessageNumber = buf[10]
+		m.DPIPIDIndex = binary.BigEndian.Uint16(buf[11:13])
+		op, err := decodeOperation(opID, buf[13:])
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", opID, err)
+		}
+		m.Operations = []Operation{op}
+		return &m, nil
+	}
+
+	if len(buf) < 10 {
+		return nil, errShort("multiple operation header", 10, len(buf))
+	}
+	m.ProtocolVersion = buf[4]
+	m.ASIndex = buf[5]
+	m.MessageNumber = buf[6]
+	m.DPIPIDIndex = binary.BigEndian.Uint16(buf[7:9])
+	m.SCTE35ProtocolVersion = buf[9]
+	ts, buf, err := readTimestamp(buf[10:])
+	if err != nil {
+		return nil, err
+	}
+	m.Timestamp = ts
+	if len(buf) < 1 {
+		return nil, errShort("num_ops", 1, len(buf))
+	}
+	nops := int(buf[0])
+	if nops == 0 {
+		return nil, fmt.Errorf("no operations")
+	}
+	buf = buf[1:]
+	for i := 0; i < nops; i++ {
+		if len(buf) < 4 {
+			return nil, errShort("operation header", 4, len(buf))
+		}
+		id := OpID(binary.BigEndian.Uint16(buf))
+		n := int(binary.BigEndian.Uint16(buf[2:4]))
+		buf = buf[4:]
+		if len(buf) < n {
+			return nil, errShort(id.String(), n, len(buf))
+		}
+		op, err := decodeOperation(id, buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", id, err)
+		}
+		m.Operations = append(m.Operations, op)
+		buf = buf[n:]
+	}
+	if len(buf) > 0 {
+		return nil, fmt.Errorf("%d trailing bytes after %d operations", len(buf), nops)
+	}
+	return &m, nil
+}
+
+// ReadMessage reads and decodes the next message from r.
+func ReadMessage(r io.Reader) (*Message, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	size := int(binary.BigEndian.Uint16(header[2:4]))
+	if size < len(header) {
+		return nil, fmt.Errorf("message size %d smaller than header", size)
+	}
+	buf := make([]byte, size)
+	copy(buf, header)
+	if _, err := io.ReadFull(r, buf[len(header):]); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return Decode(buf)
+}
@@ -0,0 +1,177 @@
+package scte104
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Messages encoded by hand from the syntax in SCTE 104 sections 7 to 11.
+var messageTests = []struct {
+	name    string
+	encoded []byte
+	want    Message
+}{
+	{
+		name:    "init_request",
+		encoded: []byte{0x00, 0x01, 0x00, 0x0d, 0, 0, 0, 0, 0, 0x01, 0x02, 0, 0},
+		want: Message{
+			ASIndex:       1,
+			MessageNumber: 2,
+			Operations:    []Operation{InitRequest{}},
+		},
+	},
+	{
+		name: "inject_response",
+		encoded: []byte{
+			0x00, 0x07, 0x00, 0x0e,
+			0x00, 0x64, // result 100
+			0x00, 0x00,
+			0, 0, 0x05, 0x00, 0x01,
+			0x05,
+		},
+		want: Message{
+			Result:        ResultSuccess,
+			MessageNumber: 5,
+			DPIPIDIndex:   1,
+			Operations:    []Operation{InjectResponse{5}},
+		},
+	},
+	{
+		name: "splice_request",
+		encoded: []byte{
+			0xff, 0xff, 0x00, 0x1e,
+			0, 0, 0x01, 0, 0, // version, AS index, message number, DPI PID index
+			0,    // SCTE 35 protocol version
+			0,    // time_type none
+			0x01, // num_ops
+			0x01, 0x01, 0x00, 0x0e,
+			0x01,                   // spliceStart_normal
+			0x00, 0x00, 0x00, 0x2a, // event ID
+			0x00, 0x01, // program ID
+			0x13, 0x88, // pre-roll 5000ms
+			0x01, 0x2c, // break duration 30s
+			0x00, 0x00, // avails
+			0x01, // auto return
+		},
+		want: Message{
+			MessageNumber: 1,
+			Operations: []Operation{
+				SpliceRequest{
+					Type:          SpliceStartNormal,
+					EventID:       42,
+					ProgramID:     1,
+					Preroll:       5 * time.Second,
+					BreakDuration: 30 * time.Second,
+					AutoReturn:    true,
+				},
+			},
+		},
+	},
+	{
+		name: "time_signal_segmentation",
+		encoded: []byte{
+			0xff, 0xff, 0x00, 0x39,
+			0, 0, 0x02, 0, 0,
+			0,
+			0x01, 0x52, 0xbc, 0xc3, 0x00, 0xc3, 0x50, // UTC 2024-01-01T00:00:00.05Z
+			0x02,
+			0x01, 0x04, 0x00, 0x02, 0x07, 0xd0,
+			0x01, 0x0b, 0x00, 0x1d,
+			0x00, 0x00, 0x00, 0x07, // event ID
+			0x00,       // cancel
+			0x00, 0x3c, // duration 60s
+			0x08, 0x08, 0x00, 0x00, 0x00, 0x00, 0x2c, 0xa0, 0xa1, 0x8a, // TI UPID
+			0x34, 0x01, 0x01, // provider placement opportunity start, 1 of 1
+			0x00,                         // duration extension frames
+			0x01, 0x00, 0x00, 0x00, 0x00, // not restricted
+			0x00, 0x00, 0x00, // no sub-segments
+		},
+		want: Message{
+			MessageNumber: 2,
+			Timestamp: Timestamp{
+				Type: TimeUTC,
+				UTC:  time.Date(2024, 1, 1, 0, 0, 0, 50*int(time.Millisecond), time.UTC),
+			},
+			Operations: []Operation{
+				TimeSignalRequest{2 * time.Second},
+				SegmentationRequest{
+					EventID:               7,
+					Duration:              time.Minute,
+					UPIDType:              0x08,
+					UPID:                  []byte{0, 0, 0, 0, 0x2c, 0xa0, 0xa1, 0x8a},
+					Type:                  0x34,
+					Number:                1,
+					Expected:              1,
+					DeliveryNotRestricted: true,
+				},
+			},
+		},
+	},
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	for _, tt := range messageTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.encoded)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("decode: want %+v, got %+v", tt.want, *got)
+			}
+			b, err := Encode(&tt.want)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if !bytes.Equal(b, tt.encoded) {
+				t.Errorf("encode: want %#x, got %#x", tt.encoded, b)
+			}
+			m, err := ReadMessage(bytes.NewReader(tt.encoded))
+			if err != nil {
+				t.Fatalf("read message: %v", err)
+			}
+			if !reflect.DeepEqual(*m, tt.want) {
+				t.Errorf("read message: want %+v, got %+v", tt.want, *m)
+			}
+		})
+	}
+}
+
+func TestDecodeShort(t *testing.T) {
+	for _, tt := range messageTests {
+		for i := 0; i < len(tt.encoded); i++ {
+			b := make([]byte, i)
+			copy(b, tt.encoded)
+			if i >= 4 {
+				// Keep messageSize consistent so that the
+				// truncated fields themselves are checked.
+				b[2], b[3] = byte(i>>8), byte(i)
+			}
+			_, err := Decode(b)
+			if err == nil {
+				// Trailing sub-segment fields are optional.
+				continue
+			}
+			if !errors.Is(err, ErrShortMessage) {
+				t.Errorf("%s truncated to %d bytes: unexpected error %v", tt.name, i, err)
+			}
+		}
+	}
+}
+
+func TestDecodeNoOperations(t *testing.T) {
+	b, err := Encode(&Message{Operations: []Operation{SpliceNullRequest{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// drop the only operation's header and zero num_ops.
+	b = b[:len(b)-4]
+	b[len(b)-1] = 0
+	b[2], b[3] = byte(len(b)>>8), byte(len(b))
+	if m, err := Decode(b); err == nil {
+		t.Errorf("nil error decoding message with no operations: %+v", m)
+	}
+}
@@ -0,0 +1,136 @@
+package scte104
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/untangledco/streaming/scte35"
+)
+
+// Splice returns the SCTE 35 splice equivalent to the operations in m.
+// pts is the presentation time stamp, in ticks of a 90KHz clock,
+// of the video at which m is to be executed; usually the current
+// time for messages timestamped TimeNone. Preroll times in m are
+// added to pts to give splice times.
+//
+// Exactly one operation in m must request a splice command.
+// The remaining operations must request descriptors.
+// Frames in a SegmentationRequest's duration are ignored as
+// the frame rate of the video is not known.
+func (m *Message) Splice(pts uint64) (*scte35.Splice, error) {
+	splice := &scte35.Splice{
+		SAPType: scte35.SAPNone,
+		Tier:    0x0fff,
+	}
+	for _, op := range m.Operations {
+		var cmd *scte35.Command
+		switch op := op.(type) {
+		case SpliceRequest:
+			ins, err := op.insert(pts)
+			if err != nil {
+				return nil, err
+			}
+			cmd = &scte35.Command{Type: scte35.SpliceInsert, Insert: ins}
+		case SpliceNullRequest:
+			cmd = &scte35.Command{Type: scte35.SpliceNull}
+		case TimeSignalRequest:
+			t := scte35.AddPTS(pts, op.Preroll)
+			cmd = &scte35.Command{Type: scte35.TimeSignal, TimeSignal: &t}
+		case AvailRequest:
+			for _, id := range op.ProviderAvailIDs {
+				splice.Descriptors = append(splice.Descriptors, scte35.AvailDescriptor(id))
+			}
+		case DTMFRequest:
+			splice.Descriptors = append(splice.Descriptors, scte35.DTMFDescriptor{
+				Preroll: op.Preroll,
+				Chars:   op.Chars,
+			})
+		case SegmentationRequest:
+			splice.Descriptors = append(splice.Descriptors, op.descriptor())
+		case TimeRequest:
+			splice.Descriptors = append(splice.Descriptors, scte35.TimeDescriptor{
+				Seconds:     op.TAISeconds,
+				Nanoseconds: op.TAINanoseconds,
+				UTCOffset:   op.UTCOffset,
+			})
+		default:
+			return nil, fmt.Errorf("convert %s: unsupported operation", op.OpID())
+		}
+		if cmd != nil {
+			if splice.Command != nil {
+				return nil, fmt.Errorf("%s: more than one splice command requested", op.OpID())
+			}
+			splice.Command = cmd
+		}
+	}
+	if splice.Command == nil {
+		return nil, errors.New("no splice command requested")
+	}
+	return splice, nil
+}
+
+func (op SpliceRequest) insert(pts uint64) (*scte35.Insert, error) {
+	ins := &scte35.Insert{
+		ID:            op.EventID,
+		ProgramID:     op.ProgramID,
+		AvailNum:      op.AvailNum,
+		AvailExpected: op.AvailExpected,
+	}
+	switch op.Type {
+	case SpliceCancel:
+		ins.Cancel = true
+		return ins, nil
+	case SpliceStartNormal, SpliceStartImmediate:
+		ins.OutOfNetwork = true
+		if op.BreakDuration > 0 {
+			ins.Duration = scte35.NewBreakDuration(op.BreakDuration, op.AutoReturn)
+		}
+	case SpliceEndNormal, SpliceEndImmediate:
+	default:
+		return nil, fmt.Errorf("unknown splice insert type %d", op.Type)
+	}
+	if op.Type == SpliceStartImmediate || op.Type == SpliceEndImmediate {
+		ins.Immediate = true
+	} else {
+		t := scte35.AddPTS(pts, op.Preroll)
+		ins.SpliceTime = &t
+	}
+	return ins, nil
+}
+
+func (op SegmentationRequest) descriptor() scte35.SegmentationDescriptor {
+	desc := scte35.SegmentationDescriptor{
+		EventID: op.EventID,
+		Cancel:  op.Cancel,
+	}
+	if op.Cancel {
+		return desc
+	}
+	if !op.DeliveryNotRestricted {
+		var r scte35.DeliveryRestrictions
+		if op.WebDeliveryAllowed {
+			r |= scte35.WebDeliveryAllowed
+		}
+		if op.NoRegionalBlackout {
+			r |= scte35.NoRegionalBlackout
+		}
+		if op.ArchiveAllowed {
+			r |= scte35.ArchiveAllowed
+		}
+		r |= scte35.DeliveryRestrictions(op.DeviceRestrictions & 0x03)
+		desc.Restrictions = &r
+	}
+	if op.Duration > 0 {
+		d := scte35.Ticks(op.Duration)
+		desc.Duration = &d
+	}
+	desc.UPID = scte35.UPID{Type: scte35.UPIDType(op.UPIDType), Value: op.UPID}
+	desc.Type = op.Type
+	desc.Number = op.Number
+	desc.Expected = op.Expected
+	if op.SubSegments {
+		desc.SubNumber = op.SubNumber
+		desc.SubExpected = op.SubExpected
+	}
+	return desc
+}
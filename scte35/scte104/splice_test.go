@@ -0,0 +1,133 @@
+package scte104
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/untangledco/streaming/scte35"
+)
+
+func newuint64(i uint64) *uint64 { return &i }
+
+func TestSplice(t *testing.T) {
+	var tests = []struct {
+		name string
+		ops  []Operation
+		pts  uint64
+		want scte35.Splice
+	}{
+		{
+			name: "splice_insert",
+			ops:  messageTests[2].want.Operations,
+			pts:  1000,
+			want: scte35.Splice{
+				SAPType: scte35.SAPNone,
+				Tier:    0x0fff,
+				Command: &scte35.Command{
+					Type: scte35.SpliceInsert,
+					Insert: &scte35.Insert{
+						ID:           42,
+						OutOfNetwork: true,
+						SpliceTime:   newuint64(1000 + 5*90000),
+						Duration:     &scte35.BreakDuration{AutoReturn: true, Duration: 30 * 90000},
+						ProgramID:    1,
+					},
+				},
+			},
+		},
+		{
+			name: "pts_wraps",
+			ops:  []Operation{SpliceRequest{Type: SpliceEndNormal, EventID: 1, Preroll: time.Second}},
+			pts:  1<<33 - 1,
+			want: scte35.Splice{
+				SAPType: scte35.SAPNone,
+				Tier:    0x0fff,
+				Command: &scte35.Command{
+					Type:   scte35.SpliceInsert,
+					Insert: &scte35.Insert{ID: 1, SpliceTime: newuint64(90000 - 1)},
+				},
+			},
+		},
+		{
+			name: "time_signal_segmentation",
+			ops: append(messageTests[3].want.Operations,
+				AvailRequest{[]uint32{0x135}},
+				DTMFRequest{Preroll: 10, Chars: []byte("1*")},
+			),
+			pts: 1000,
+			want: scte35.Splice{
+				SAPType: scte35.SAPNone,
+				Tier:    0x0fff,
+				Command: &scte35.Command{
+					Type:       scte35.TimeSignal,
+					TimeSignal: newuint64(1000 + 2*90000),
+				},
+				Descriptors: []scte35.SpliceDescriptor{
+					scte35.SegmentationDescriptor{
+						EventID:  7,
+						Duration: newuint64(60 * 90000),
+						UPID:     scte35.NewTI(0x2ca0a18a),
+						Type:     scte35.ProviderPlacementOppStart,
+						Number:   1,
+						Expected: 1,
+					},
+					scte35.AvailDescriptor(0x135),
+					scte35.DTMFDescriptor{Preroll: 10, Chars: []byte("1*")},
+				},
+			},
+		},
+		{
+			name: "blackout",
+			ops: []Operation{
+				TimeSignalRequest{},
+				SegmentationRequest{EventID: 8, Type: 0x10},
+			},
+			pts: 1000,
+			want: scte35.Splice{
+				SAPType: scte35.SAPNone,
+				Tier:    0x0fff,
+				Command: &scte35.Command{
+					Type:       scte35.TimeSignal,
+					TimeSignal: newuint64(1000),
+				},
+				Descriptors: []scte35.SpliceDescriptor{
+					scte35.SegmentationDescriptor{
+						EventID:      8,
+						Restrictions: new(scte35.DeliveryRestrictions),
+						Type:         scte35.ProgramStart,
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := Message{Operations: tt.ops}
+			got, err := m.Splice(tt.pts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("want %+v, got %+v", tt.want, *got)
+			}
+			if _, err := scte35.Encode(got); err != nil {
+				t.Errorf("encode splice: %v", err)
+			}
+		})
+	}
+}
+
+func TestSpliceErrors(t *testing.T) {
+	for name, ops := range map[string][]Operation{
+		"no command":   {AvailRequest{[]uint32{1}}},
+		"two commands": {SpliceNullRequest{}, TimeSignalRequest{}},
+		"unsupported":  {SpliceNullRequest{}, RawOperation{OpProprietaryCommand, nil}},
+		"bad type":     {SpliceRequest{Type: 0}},
+	} {
+		m := Message{Operations: ops}
+		if _, err := m.Splice(0); err == nil {
+			t.Errorf("%s: nil error converting %+v", name, ops)
+		}
+	}
+}
@@ -6,6 +6,8 @@ type sample struct {
 	want    Splice
 }
 
+func restrict(r DeliveryRestrictions) *DeliveryRestrictions { return &r }
+
 var samples = []sample{
 	{
 		name:    "14.1. time_signal",
@@ -22,7 +24,7 @@ var samples = []sample{
 				SegmentationDescriptor{
 					EventID:      0x4800008e,
 					idCompliance: true,
-					Restrictions: NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone,
+					Restrictions: restrict(NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone),
 					Duration:     newuint64(0x0001a599b0),
 					UPID: UPID{
 						Type:  UPIDTI,
@@ -77,7 +79,7 @@ var samples = []sample{
 				SegmentationDescriptor{
 					EventID:      0x4800008e,
 					idCompliance: true,
-					Restrictions: WebDeliveryAllowed | NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone,
+					Restrictions: restrict(WebDeliveryAllowed | NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone),
 					UPID: UPID{
 						Type:  UPIDTI,
 						Value: []byte{0x00, 0x00, 0x00, 0x00, 0x2c, 0xa0, 0xa1, 0x8a},
@@ -103,7 +105,7 @@ var samples = []sample{
 			Descriptors: []SpliceDescriptor{
 				SegmentationDescriptor{
 					EventID:      0x48000018,
-					Restrictions: WebDeliveryAllowed | NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone,
+					Restrictions: restrict(WebDeliveryAllowed | NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone),
 					idCompliance: true,
 					UPID: UPID{
 						Type:  UPIDTI,
@@ -114,7 +116,7 @@ var samples = []sample{
 				SegmentationDescriptor{
 					EventID:      0x48000019,
 					idCompliance: true,
-					Restrictions: WebDeliveryAllowed | NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone,
+					Restrictions: restrict(WebDeliveryAllowed | NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone),
 					UPID: UPID{
 						Type:  UPIDTI,
 						Value: []byte{0, 0, 0, 0, 0x2c, 0xa4, 0xdb, 0xa0},
@@ -140,7 +142,7 @@ var samples = []sample{
 				SegmentationDescriptor{
 					EventID:      1560886545,
 					idCompliance: true,
-					Restrictions: WebDeliveryAllowed | NoRegionalBlackout | DeviceRestrictionsNone,
+					Restrictions: restrict(WebDeliveryAllowed | NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone),
 					UPID: UPID{
 						Type:  UPIDType(1),
 						Value: []byte{69, 80, 48, 49, 56, 48, 51, 56, 52, 48, 48, 54, 54, 54},
@@ -152,7 +154,7 @@ var samples = []sample{
 				SegmentationDescriptor{
 					EventID:      1560886545,
 					idCompliance: true,
-					Restrictions: WebDeliveryAllowed | NoRegionalBlackout | DeviceRestrictionsNone,
+					Restrictions: restrict(WebDeliveryAllowed | NoRegionalBlackout | ArchiveAllowed | DeviceRestrictionsNone),
 					Duration:     newuint64(19803003),
 					UPID: UPID{
 						Type:  UPIDType(1),
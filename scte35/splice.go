@@ -6,6 +6,7 @@
 package scte35
 
 import (
+	"crypto/des"
 	"encoding/binary"
 	"fmt"
 )
@@ -39,12 +40,12 @@ func (t SAPType) String() string {
 type Splice struct {
 	SAPType SAPType
 
-	// If true, indicates that the contents of Command,
-	// Descriptors and CRC32 are encrypted with Cipher.
-	// TODO(otl): encoding and decoding of encrypted splices is not supported.
+	// If true, indicates that the contents of Command and
+	// Descriptors are encrypted with Cipher.
+	// See EncodeWithKeys and DecodeWithKeys.
 	Encrypted bool
 	Cipher    Cipher
-	// The control word (key) used to decrypt the message.
+	// Index of the control word (key) used to encrypt the message.
 	CWIndex uint8
 
 	// Holds a 33-bit unsigned integer representing the number of
@@ -81,7 +82,17 @@ const (
 // maximum 12-bit uint (2^12 - 1)
 const maxTier uint16 = 0xfff
 
+// Encode returns the wire format of splice.
+// Encrypted splices must be encoded with EncodeWithKeys.
 func Encode(splice *Splice) ([]byte, error) {
+	return EncodeWithKeys(splice, nil)
+}
+
+// EncodeWithKeys is like Encode but, if splice.Encrypted is set,
+// encrypts the splice with the control word provided by keys at
+// splice.CWIndex. Alignment stuffing and the E_CRC_32 field are added
+// as specified in SCTE 35 section 11.
+func EncodeWithKeys(splice *Splice, keys KeyProvider) ([]byte, error) {
 	buf := make([]byte, 4)
 	buf[0] = byte(tableID)
 	// next 2 bits (section_syntax_indicator, private_indicator) must be 0.
@@ -128,19 +139,45 @@ func Encode(splice *Splice) ([]byte, error) {
 	// stuff remaining 4 bits into the last byte.
 	buf[len(buf)-1] |= byte(cmdlen >> 8)
 	buf = append(buf, byte(cmdlen))
-	buf = append(buf, byte(splice.Command.Type))
-	buf = append(buf, cmd...)
 
+	// Everything from the command type up to and including
+	// E_CRC_32 may be encrypted.
+	payload := []byte{byte(splice.Command.Type)}
+	payload = append(payload, cmd...)
 	var buf1 []byte
-	for _, desc := range splice.Descriptors {
+	for i, desc := range splice.Descriptors {
+		if seg, ok := desc.(SegmentationDescriptor); ok {
+			if err := seg.UPID.Validate(); err != nil {
+				return nil, fmt.Errorf("descriptor %d: %w", i, err)
+			}
+		}
 		buf1 = append(buf1, encodeSpliceDescriptor(desc)...)
 	}
-	buf = binary.BigEndian.AppendUint16(buf, uint16(len(buf1)))
-	buf = append(buf, buf1...)
+	payload = binary.BigEndian.AppendUint16(payload, uint16(len(buf1)))
+	payload = append(payload, buf1...)
+	if splice.Encrypted {
+		if keys == nil {
+			return nil, ErrNoKeys
+		}
+		cw, err := keys.ControlWord(splice.CWIndex)
+		if err != nil {
+			return nil, fmt.Errorf("get control word: %w", err)
+		}
+		// Stuff so that the encrypted payload, including
+		// E_CRC_32, fills a whole number of blocks.
+		for (len(payload)+4)%des.BlockSize != 0 {
+			payload = append(payload, 0xff)
+		}
+		payload = binary.BigEndian.AppendUint32(payload, ^updateCRC(0, payload))
+		if err := encrypt(splice.Cipher, cw, payload); err != nil {
+			return nil, fmt.Errorf("encrypt: %w", err)
+		}
+	}
+	buf = append(buf, payload...)
 
 	// want only 12 bits, left 4 bits are used by flags, saptype.
 	buflen := uint16(len(buf)) & 0x0fff
-	buflen++ // TODO(otl): is this required because of alignment stuffing?
+	buflen++ // header is 3 bytes, but we haven't appended the 4-byte CRC yet.
 	buf[1] |= byte(buflen >> 8)
 	buf[2] = byte(buflen)
 
@@ -148,11 +185,28 @@ func Encode(splice *Splice) ([]byte, error) {
 	return binary.BigEndian.AppendUint32(buf, crc), nil
 }
 
+// Decode decodes a splice from its wire format.
+// Encrypted splices must be decoded with DecodeWithKeys.
 func Decode(buf []byte) (*Splice, error) {
+	return DecodeWithKeys(buf, nil)
+}
+
+// DecodeWithKeys is like Decode but decrypts encrypted splices with
+// the control word provided by keys at the splice's CWIndex.
+//
+// Errors from truncated or malformed messages wrap the sentinel errors
+// such as ErrShortBuffer, and may be tested for with errors.Is.
+// If the CRC_32 field, or for encrypted splices the decrypted E_CRC_32
+// field, does not match the message, a *CRCError is returned.
+// A mismatched E_CRC_32 usually indicates the wrong control word.
+func DecodeWithKeys(buf []byte, keys KeyProvider) (*Splice, error) {
 	if len(buf) < 3 {
-		return nil, fmt.Errorf("need at least 2 bytes")
+		return nil, errShort("section header", 3, len(buf))
 	}
-	// skip buf[0], we don't store table_id.
+	if buf[0] != tableID {
+		return nil, fmt.Errorf("table id %#x: %w", buf[0], ErrTableID)
+	}
+	msg := buf
 
 	var splice Splice
 	// skip 2 bits, straight to sap_type.
@@ -161,19 +215,27 @@ func Decode(buf []byte) (*Splice, error) {
 	length &= 0x0fff // 12-bit field
 	buf = buf[3:]
 	if len(buf) != int(length) {
-		return nil, fmt.Errorf("message declares %d bytes but have %d", length, len(buf))
+		return nil, fmt.Errorf("message declares %d bytes but have %d: %w", length, len(buf), ErrSectionLength)
+	}
+	// header, command type, descriptor loop length and CRC_32.
+	if len(buf) < 10+1+2+4 {
+		return nil, errShort("splice_info_section", 10+1+2+4, len(buf))
+	}
+	splice.CRC32 = binary.BigEndian.Uint32(buf[len(buf)-4:])
+	if crc := ^updateCRC(0, msg[:len(msg)-4]); crc != splice.CRC32 {
+		return nil, &CRCError{Message: splice.CRC32, Calculated: crc}
 	}
 
 	// skip version byte at buf[0]. We don't store version as it's constant.
 	splice.Encrypted = buf[1]&0b10000000 > 0
 	if splice.Encrypted {
 		// right-most bit is used by PTSAdjustment.
-		splice.Cipher = Cipher(buf[1] & 0b01111110)
+		splice.Cipher = Cipher(buf[1]&0b01111110) >> 1
 	}
 
 	pts := make([]byte, 8)
-	pts[0] = buf[1] & (1 << 1)
-	copy(pts[1:], buf[2:6])
+	pts[3] = buf[1] & 0x01
+	copy(pts[4:], buf[2:6])
 	splice.PTSAdjustment = binary.BigEndian.Uint64(pts)
 	splice.CWIndex = uint8(buf[6])
 
@@ -182,88 +244,82 @@ func Decode(buf []byte) (*Splice, error) {
 	splice.Tier = tier >> 4
 
 	// 4-bits out of buf[8], then all of buf[9] for a 12-bit integer.
-	cmdlen := binary.BigEndian.Uint16([]byte{buf[8] & 0x0f, buf[9]})
-	cmd, err := decodeCommand(buf[10 : 10+cmdlen+1])
+	cmdlen := int(binary.BigEndian.Uint16([]byte{buf[8] & 0x0f, buf[9]}))
+
+	payload := buf[10 : len(buf)-4]
+	if splice.Encrypted {
+		if keys == nil {
+			return nil, ErrNoKeys
+		}
+		cw, err := keys.ControlWord(splice.CWIndex)
+		if err != nil {
+			return nil, fmt.Errorf("get control word: %w", err)
+		}
+		// don't decrypt the caller's buffer in place.
+		payload = append([]byte{}, payload...)
+		if err := decrypt(splice.Cipher, cw, payload); err != nil {
+			return nil, fmt.Errorf("decrypt: %w", err)
+		}
+		if len(payload) < 4 {
+			return nil, errShort("E_CRC_32", 4, len(payload))
+		}
+		ecrc := binary.BigEndian.Uint32(payload[len(payload)-4:])
+		payload = payload[:len(payload)-4]
+		if crc := ^updateCRC(0, payload); crc != ecrc {
+			return nil, &CRCError{Encrypted: true, Message: ecrc, Calculated: crc}
+		}
+	}
+
+	if len(payload) < 1+cmdlen+2 {
+		return nil, errShort("splice command", 1+cmdlen+2, len(payload))
+	}
+	cmd, err := decodeCommand(payload[:1+cmdlen])
 	if err != nil {
 		return nil, fmt.Errorf("decode command: %w", err)
 	}
 	splice.Command = cmd
-	buf = buf[10+cmdlen+1:]
+	payload = payload[1+cmdlen:]
 
-	desclen := binary.BigEndian.Uint16([]byte{buf[0], buf[1]})
-	descriptors, err := decodeAllDescriptors(buf[2 : 2+desclen])
+	desclen := int(binary.BigEndian.Uint16(payload[:2]))
+	if len(payload[2:]) < desclen {
+		return nil, errShort("descriptor loop", desclen, len(payload[2:]))
+	}
+	descriptors, err := decodeAllDescriptors(payload[2 : 2+desclen])
 	if err != nil {
 		return nil, fmt.Errorf("decode splice descriptors: %w", err)
 	}
 	splice.Descriptors = descriptors
-
-	buf = buf[2+desclen:]
-	if splice.Encrypted {
-		// TODO(otl): handle alignment_stuffing for encrypted packets.
-		// skip past E_CRC_32; we don't store it.
-		buf = buf[1:]
-	}
-	splice.CRC32 = binary.BigEndian.Uint32(buf)
+	// any remaining bytes are alignment stuffing.
 	return &splice, nil
 }
 
 func decodeCommand(buf []byte) (*Command, error) {
+	if len(buf) < 1 {
+		return nil, errShort("command type", 1, 0)
+	}
 	var cmd Command
 	cmd.Type = CommandType(buf[0])
 	switch cmd.Type {
 	case SpliceNull, BandwidthReservation:
 		// nothing to decode
 	case TimeSignal:
-		// check if time specified flag is set.
-		// If so, extract the 33-bit integer timestamp.
-		if buf[1]&0x80 == 1<<7 {
-			b := make([]byte, 8)
-			b[3] = buf[1] & 0x01 // ignoring flag and reserved bits
-			copy(b[4:], buf[2:6])
-			t := binary.BigEndian.Uint64(b)
-			cmd.TimeSignal = &t
-		}
-	case SpliceInsert:
-		var ins Insert
-		ins.ID = binary.BigEndian.Uint32(buf[1:5])
-		ins.Cancel = buf[5]&0x80 > 0
-		if ins.Cancel {
-			cmd.Insert = &ins
-			// rebelelder told us to do this.
-			return &cmd, nil
+		t, _, err := readSpliceTime(buf[1:])
+		if err != nil {
+			return nil, fmt.Errorf("decode time signal: %w", err)
 		}
-		ins.OutOfNetwork = buf[6]&(1<<7) > 0
-		// assume program_splice is set at bit 6;
-		// we don't support deprecated component mode.
-		durflag := buf[6]&(1<<5) > 0
-		ins.Immediate = buf[6]&(1<<4) > 0
-		ins.idCompliance = buf[6]&(1<<3) > 0
-		// next 3 bits are reserved.
-
-		if !ins.Immediate {
-			// is time_specified_flag set? if so, read the 33-bit time.
-			if buf[7]&(1<<7) > 0 {
-				b := make([]byte, 3)
-				b = append(b, buf[7]&0x01)  // skip reserved bits.
-				b = append(b, buf[8:12]...) // read remaining 32 bits.
-				dur := binary.BigEndian.Uint64(b)
-				ins.SpliceTime = newuint64(dur)
-				buf = buf[12:]
-			} else {
-				buf = buf[8:]
-			}
+		cmd.TimeSignal = t
+	case SpliceSchedule:
+		events, err := unpackEvents(buf[1:])
+		if err != nil {
+			return nil, fmt.Errorf("unpack events: %w", err)
 		}
-
-		if durflag {
-			a := [5]byte{buf[0], buf[1], buf[2], buf[3], buf[4]}
-			ins.Duration = readBreakDuration(a)
-			buf = buf[5:]
+		cmd.Schedule = events
+	case SpliceInsert:
+		ins, err := decodeInsert(buf[1:])
+		if err != nil {
+			return nil, fmt.Errorf("decode splice insert: %w", err)
 		}
-
-		ins.ProgramID = binary.BigEndian.Uint16([]byte{buf[0], buf[1]})
-		ins.AvailNum = uint8(buf[2])
-		ins.AvailExpected = uint8(buf[3])
-		cmd.Insert = &ins
+		cmd.Insert = ins
 	case Private:
 		pcmd, err := decodePrivateCommand(buf[1:])
 		if err != nil {
@@ -271,10 +327,7 @@ func decodeCommand(buf []byte) (*Command, error) {
 		}
 		cmd.Private = &pcmd
 	default:
-		// TODO(otl): we could support more commands but we
-		// just haven't written the code yet. See issues
-		// #28 and #29.
-		return nil, fmt.Errorf("cannot decode command type %s", cmd.Type)
+		return nil, fmt.Errorf("decode command type %s: %w", cmd.Type, ErrUnsupported)
 	}
 	return &cmd, nil
 }
@@ -3,6 +3,7 @@ package scte35
 import (
 	"encoding/binary"
 	"fmt"
+	"sync"
 )
 
 const DescriptorIDCUEI = "CUEI"
@@ -22,17 +23,31 @@ const (
 const (
 	ProgramStart                        = 0x10
 	ProgramEnd                          = 0x11
+	ProgramEarlyTermination             = 0x12
+	ProgramOverlapStart                 = 0x17
+	ProgramStartInProgress              = 0x19
 	ChapterStart                        = 0x20
 	ChapterEnd                          = 0x21
+	BreakStart                          = 0x22
+	BreakEnd                            = 0x23
 	ProviderAdStart                     = 0x30
+	ProviderAdEnd                       = 0x31
 	DistributorAdStart                  = 0x32
+	DistributorAdEnd                    = 0x33
 	ProviderPlacementOppStart           = 0x34
 	ProviderPlacementOppEnd             = 0x35
 	DistributorPlacementOppStart        = 0x36
+	DistributorPlacementOppEnd          = 0x37
 	ProviderOverlayPlacementOppStart    = 0x38
+	ProviderOverlayPlacementOppEnd      = 0x39
 	DistributorOverlayPlacementOppStart = 0x3a
+	DistributorOverlayPlacementOppEnd   = 0x3b
 	ProviderAdBlockStart                = 0x44
+	ProviderAdBlockEnd                  = 0x45
 	DistributorAdBlockStart             = 0x46
+	DistributorAdBlockEnd               = 0x47
+	NetworkStart                        = 0x50
+	NetworkEnd                          = 0x51
 )
 
 type SpliceDescriptor interface {
@@ -93,18 +108,29 @@ func (d DTMFDescriptor) Data() []byte {
 	return b
 }
 
-func unmarshalDTMF(buf []byte) DTMFDescriptor {
-	// skip buf[1]; contains the length which we don't care about when using slices.
+func unmarshalDTMF(buf []byte) (DTMFDescriptor, error) {
+	if len(buf) < 2 {
+		return DTMFDescriptor{}, errShort("dtmf descriptor", 2, len(buf))
+	}
+	// count is the left-most 3 bits; the rest are reserved.
+	count := int(buf[1] >> 5)
+	if len(buf[2:]) < count {
+		return DTMFDescriptor{}, errShort("dtmf chars", count, len(buf[2:]))
+	}
 	return DTMFDescriptor{
 		Preroll: uint8(buf[0]),
-		Chars:   buf[2:],
-	}
+		Chars:   buf[2 : 2+count],
+	}, nil
 }
 
+// DeliveryRestrictions holds the delivery restriction flags and
+// device group of a SegmentationDescriptor. The zero value is the
+// most restrictive: no web delivery, regional blackout applies,
+// no archiving and device group 0.
 type DeliveryRestrictions uint8
 
 const (
-	WebDeliveryAllowed DeliveryRestrictions = 1<<4 - iota
+	WebDeliveryAllowed DeliveryRestrictions = 1 << (4 - iota)
 	NoRegionalBlackout
 	ArchiveAllowed
 	DeviceRestrictGroup0   = 0x00
@@ -116,9 +142,15 @@ const (
 // SegmentationDescriptor represents the segmentation_descriptor
 // structure defined in SCTE 35 section 10.3.3.
 type SegmentationDescriptor struct {
-	EventID      uint32
-	Cancel       bool
-	Restrictions DeliveryRestrictions
+	EventID uint32
+	Cancel  bool
+	// Restrictions is nil if delivery is not restricted,
+	// as signalled by the delivery_not_restricted_flag.
+	Restrictions *DeliveryRestrictions
+	// Components, if set, lists the elementary streams segmented
+	// in the deprecated component mode. Otherwise the whole
+	// program is segmented.
+	Components []SegmentationComponent
 	// 40-bit integer representing the number of ticks of a 90KHz clock.
 	Duration *uint64
 	UPID     UPID
@@ -139,6 +171,16 @@ type SegmentationDescriptor struct {
 	idCompliance bool
 }
 
+// SegmentationComponent is an elementary stream segmented by a
+// SegmentationDescriptor, identified by the component_tag of its
+// stream_identifier_descriptor.
+type SegmentationComponent struct {
+	Tag uint8
+	// Number of ticks of a 90KHz clock added to the splice time
+	// of the associated command.
+	PTSOffset uint64
+}
+
 func (d SegmentationDescriptor) Tag() uint8 { return TagSegmentation }
 func (d SegmentationDescriptor) ID() uint32 { return descriptorIDCUEI }
 
@@ -156,6 +198,16 @@ func (d SegmentationDescriptor) Data() []byte {
 
 	if !d.Cancel {
 		buf = append(buf, segDescFlags(&d))
+		if len(d.Components) > 0 {
+			buf = append(buf, uint8(len(d.Components)))
+			for _, c := range d.Components {
+				pts := toPTS(c.PTSOffset)
+				// toggle 7 reserved bits.
+				pts[0] |= 0xfe
+				buf = append(buf, c.Tag)
+				buf = append(buf, pts[:]...)
+			}
+		}
 		if d.Duration != nil {
 			b := make([]byte, 8)                           // uint64 needs 8
 			binary.BigEndian.PutUint64(b, *d.Duration<<24) // 40 bits
@@ -181,32 +233,68 @@ func (d SegmentationDescriptor) Data() []byte {
 	return buf
 }
 
-func unmarshalSegDescriptor(buf []byte) SegmentationDescriptor {
+func unmarshalSegDescriptor(buf []byte) (SegmentationDescriptor, error) {
 	var desc SegmentationDescriptor
+	if len(buf) < 5 {
+		return desc, errShort("segmentation event", 5, len(buf))
+	}
 	desc.EventID = binary.BigEndian.Uint32(buf[:4])
 	desc.Cancel = buf[4]&(1<<7) > 0
 	desc.idCompliance = buf[4]&(1<<6) > 0
 	// next 6 bits are reserved
 
-	// always assume program_segmentation_flag is set at 0b10000000
-	// we don't support the deprecated component mode.
-
 	if !desc.Cancel {
+		if len(buf) < 6 {
+			return desc, errShort("flags", 1, 0)
+		}
 		// left-most 2 bits are flags for later.
-		desc.Restrictions = DeliveryRestrictions(buf[5] & 0b00111111)
+		if buf[5]&0b00100000 == 0 {
+			r := DeliveryRestrictions(buf[5] & 0b00011111)
+			desc.Restrictions = &r
+		}
+		programSegmentation := buf[5]&0b10000000 > 0
+		durflag := buf[5]&0b01000000 > 0
+		buf = buf[6:]
+
+		if !programSegmentation {
+			if len(buf) < 1 {
+				return desc, errShort("component count", 1, 0)
+			}
+			n := int(buf[0])
+			buf = buf[1:]
+			if len(buf) < 6*n {
+				return desc, errShort("components", 6*n, len(buf))
+			}
+			desc.Components = make([]SegmentationComponent, n)
+			for i := range desc.Components {
+				b := []byte{0, 0, 0, buf[1] & 0x01} // skip reserved bits.
+				b = append(b, buf[2:6]...)
+				desc.Components[i] = SegmentationComponent{
+					Tag:       buf[0],
+					PTSOffset: binary.BigEndian.Uint64(b),
+				}
+				buf = buf[6:]
+			}
+		}
 
-		// is segmentation duration flag set?
-		if buf[5]&0b01000000 > 0 {
+		if durflag {
+			if len(buf) < 5 {
+				return desc, errShort("segmentation duration", 5, len(buf))
+			}
 			b := make([]byte, 3)
-			b = append(b, buf[6:11]...)
+			b = append(b, buf[:5]...)
 			dur := binary.BigEndian.Uint64(b)
 			desc.Duration = &dur
-			buf = buf[11:]
-		} else {
-			buf = buf[6:]
+			buf = buf[5:]
 		}
 
+		if len(buf) < 2 {
+			return desc, errShort("upid", 2, len(buf))
+		}
 		uplen := int(buf[1])
+		if len(buf[2:]) < uplen+3 {
+			return desc, errShort("upid and segmentation type", uplen+3, len(buf[2:]))
+		}
 		desc.UPID = UPID{
 			Type:  UPIDType(buf[0]),
 			Value: buf[2 : 2+uplen],
@@ -226,8 +314,7 @@ func unmarshalSegDescriptor(buf []byte) SegmentationDescriptor {
 			}
 		}
 	}
-
-	return desc
+	return desc, nil
 }
 
 // UPID represents a segmentation_upid structure as specified in SCTE 35 section 10.3.3.1.
@@ -283,9 +370,9 @@ func (d TimeDescriptor) ID() uint32 { return descriptorIDCUEI }
 
 func (d TimeDescriptor) Data() []byte {
 	// 48 bits + 32 bits + 16 bits
-	b := make([]byte, 0, 6+4+2)
+	b := make([]byte, 0, 8+4+2)
 	b = binary.BigEndian.AppendUint64(b, d.Seconds)
-	b = b[:6] // only want 48-bits
+	b = b[2:] // only want the low 48 bits
 	b = binary.BigEndian.AppendUint32(b, d.Nanoseconds)
 	return binary.BigEndian.AppendUint16(b, d.UTCOffset)
 }
@@ -339,17 +426,20 @@ func (d AudioDescriptor) Data() []byte {
 
 func decodeAllDescriptors(buf []byte) ([]SpliceDescriptor, error) {
 	var sds []SpliceDescriptor
-	for len(buf) >= 6 {
+	for len(buf) > 0 {
+		if len(buf) < 2 {
+			return sds, errShort("descriptor header", 2, len(buf))
+		}
 		// first byte is tag, second is length of next descriptor.
-		dlen := uint8(buf[1])
+		dlen := int(buf[1])
+		if len(buf[2:]) < dlen {
+			return sds, errShort("descriptor", dlen, len(buf[2:]))
+		}
 		desc, err := unmarshalSpliceDescriptor(buf[:2+dlen])
 		if err != nil {
 			return sds, err
 		}
 		sds = append(sds, desc)
-		if int(dlen) >= len(buf) {
-			break
-		}
 		buf = buf[2+dlen:]
 	}
 	return sds, nil
@@ -358,28 +448,118 @@ func decodeAllDescriptors(buf []byte) ([]SpliceDescriptor, error) {
 // UnmarshalSpliceDescriptor reads exactly one descriptor from buf.
 func unmarshalSpliceDescriptor(buf []byte) (SpliceDescriptor, error) {
 	if len(buf) < 6 {
-		return nil, fmt.Errorf("short slice: need at least 5 bytes")
+		return nil, errShort("descriptor", 6, len(buf))
 	}
 	tag := uint8(buf[0])
 	length := uint8(buf[1])
 	if len(buf[2:]) != int(length) {
-		return nil, fmt.Errorf("need %d bytes, have %d", int(length), len(buf[2:]))
+		return nil, errShort("descriptor", int(length), len(buf[2:]))
 	}
-	buf = buf[2 : 2+length]
+	buf = buf[2:]
 	id := binary.BigEndian.Uint32(buf[:4])
 	buf = buf[4:]
+	if dec := lookupDescriptor(id, tag); dec != nil {
+		return dec(buf)
+	}
 	if id != descriptorIDCUEI {
 		return PrivateDescriptor{tag, id, buf}, nil
 	}
-	switch tag {
-	case TagAvail:
-		return AvailDescriptor(binary.BigEndian.Uint32(buf)), nil
-	case TagSegmentation:
-		return unmarshalSegDescriptor(buf), nil
-	case TagDTMF:
-		return unmarshalDTMF(buf), nil
+	return nil, fmt.Errorf("unmarshal descriptor tag %d: %w", tag, ErrUnsupported)
+}
+
+// A DescriptorDecoder decodes the bytes of a splice descriptor
+// following its identifier field.
+type DescriptorDecoder func(data []byte) (SpliceDescriptor, error)
+
+type descriptorKey struct {
+	id  uint32
+	tag uint8
+}
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[descriptorKey]DescriptorDecoder{
+		{descriptorIDCUEI, TagAvail}:        decodeAvail,
+		{descriptorIDCUEI, TagDTMF}:         decodeDTMF,
+		{descriptorIDCUEI, TagSegmentation}: decodeSegmentation,
+		{descriptorIDCUEI, TagTime}:         decodeTime,
+		{descriptorIDCUEI, TagAudio}:        decodeAudio,
+	}
+)
+
+// RegisterDescriptor registers dec to decode descriptors with the
+// identifier id and tag, such as those defined by a vendor.
+// Decoders of the descriptors in this package may be replaced.
+// Descriptors without a decoder are decoded as a PrivateDescriptor.
+//
+// RegisterDescriptor is usually called from an init function.
+func RegisterDescriptor(id uint32, tag uint8, dec DescriptorDecoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[descriptorKey{id, tag}] = dec
+}
+
+func lookupDescriptor(id uint32, tag uint8) DescriptorDecoder {
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	return decoders[descriptorKey{id, tag}]
+}
+
+func decodeAvail(buf []byte) (SpliceDescriptor, error) {
+	if len(buf) < 4 {
+		return nil, errShort("avail descriptor", 4, len(buf))
+	}
+	return AvailDescriptor(binary.BigEndian.Uint32(buf)), nil
+}
+
+func decodeDTMF(buf []byte) (SpliceDescriptor, error) {
+	desc, err := unmarshalDTMF(buf)
+	if err != nil {
+		return nil, fmt.Errorf("dtmf descriptor: %w", err)
+	}
+	return desc, nil
+}
+
+func decodeSegmentation(buf []byte) (SpliceDescriptor, error) {
+	desc, err := unmarshalSegDescriptor(buf)
+	if err != nil {
+		return nil, fmt.Errorf("segmentation descriptor: %w", err)
+	}
+	return desc, nil
+}
+
+func decodeTime(buf []byte) (SpliceDescriptor, error) {
+	if len(buf) < 12 {
+		return nil, errShort("time descriptor", 12, len(buf))
 	}
-	return nil, fmt.Errorf("unmarshal %d unsupported", tag)
+	secs := []byte{0, 0}
+	secs = append(secs, buf[:6]...)
+	return TimeDescriptor{
+		Seconds:     binary.BigEndian.Uint64(secs),
+		Nanoseconds: binary.BigEndian.Uint32(buf[6:10]),
+		UTCOffset:   binary.BigEndian.Uint16(buf[10:12]),
+	}, nil
+}
+
+func decodeAudio(buf []byte) (SpliceDescriptor, error) {
+	if len(buf) < 1 {
+		return nil, errShort("audio descriptor", 1, len(buf))
+	}
+	count := int(buf[0] >> 4)
+	buf = buf[1:]
+	if len(buf) < 5*count {
+		return nil, errShort("audio descriptor channels", 5*count, len(buf))
+	}
+	desc := make(AudioDescriptor, count)
+	for i := range desc {
+		ch := buf[5*i : 5*i+5]
+		desc[i].ComponentTag = ch[0]
+		copy(desc[i].Language[:], ch[1:4])
+		desc[i].BitstreamMode = ch[4] >> 5
+		desc[i].Count = NumChannels(ch[4]&0x1e) << 3
+		desc[i].FullService = ch[4]&0x01 > 0
+	}
+	return desc, nil
 }
 
 type PrivateDescriptor struct {
@@ -394,15 +574,17 @@ func (d PrivateDescriptor) Data() []byte { return d.PData }
 
 func segDescFlags(seg *SegmentationDescriptor) uint8 {
 	var b uint8
-	// assume program_segmentation is always set; we do not support the deprecated component mode.
-	b |= (1 << 7)
+	if len(seg.Components) == 0 {
+		b |= (1 << 7)
+	}
 	if seg.Duration != nil {
 		b |= (1 << 6)
 	}
-	if seg.Restrictions != 0 {
-		b |= byte(seg.Restrictions)
+	if seg.Restrictions != nil {
+		b |= byte(*seg.Restrictions) & 0b00011111
 	} else {
-		b |= (1 << 5)
+		// set delivery_not_restricted_flag and toggle 5 reserved bits.
+		b |= 0x3f
 	}
 	return b
 }
@@ -1,7 +1,11 @@
 package scte35
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -196,3 +200,236 @@ func TestAudioDescriptor(t *testing.T) {
 		t.Fatalf("Data() = %#08bb, want %#08b", got, want)
 	}
 }
+
+func TestDescriptorRoundTrip(t *testing.T) {
+	descs := []SpliceDescriptor{
+		TimeDescriptor{Seconds: 0x123456789abc, Nanoseconds: 500, UTCOffset: 37},
+		AudioDescriptor{
+			{ComponentTag: 1, Language: [3]byte{'e', 'n', 'g'}, BitstreamMode: 2, Count: SixChan, FullService: true},
+			{ComponentTag: 2, Language: [3]byte{'f', 'r', 'a'}, Count: TwoChan},
+		},
+	}
+	for _, want := range descs {
+		buf := []byte{want.Tag(), 0}
+		buf = binary.BigEndian.AppendUint32(buf, want.ID())
+		buf = append(buf, want.Data()...)
+		buf[1] = uint8(len(buf) - 2)
+		got, err := unmarshalSpliceDescriptor(buf)
+		if err != nil {
+			t.Fatalf("decode %T: %v", want, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("decode %#x: want %+v, got %+v", buf, want, got)
+		}
+	}
+
+	data := TimeDescriptor{Seconds: 0x123456789abc}.Data()
+	if want := []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc}; !bytes.Equal(data[:6], want) {
+		t.Errorf("TAI seconds encoded as %#x, want %#x", data[:6], want)
+	}
+}
+
+// testDescriptor is a vendor descriptor for TestRegisterDescriptor.
+type testDescriptor struct {
+	Channel uint16
+}
+
+const testDescriptorID = 0x54455354 // "TEST"
+
+func (d testDescriptor) Tag() uint8 { return 0xf0 }
+func (d testDescriptor) ID() uint32 { return testDescriptorID }
+
+func (d testDescriptor) Data() []byte {
+	return binary.BigEndian.AppendUint16(nil, d.Channel)
+}
+
+func TestRegisterDescriptor(t *testing.T) {
+	RegisterDescriptor(testDescriptorID, 0xf0, func(b []byte) (SpliceDescriptor, error) {
+		if len(b) != 2 {
+			return nil, fmt.Errorf("need 2 bytes, have %d", len(b))
+		}
+		return testDescriptor{binary.BigEndian.Uint16(b)}, nil
+	})
+	want := Splice{
+		SAPType:     SAPNone,
+		Tier:        maxTier,
+		Command:     &Command{Type: SpliceNull},
+		Descriptors: []SpliceDescriptor{testDescriptor{7}},
+	}
+	b, err := Encode(&want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Descriptors, want.Descriptors) {
+		t.Errorf("decode: want %+v, got %+v", want.Descriptors, got.Descriptors)
+	}
+
+	j, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON Splice
+	if err := json.Unmarshal(j, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON.Descriptors, want.Descriptors) {
+		t.Errorf("json: want %+v, got %+v", want.Descriptors, fromJSON.Descriptors)
+	}
+
+	// The vendor's identifier with another tag is still private.
+	other := PrivateDescriptor{PTag: 0xf1, PID: testDescriptorID, PData: []byte{1}}
+	want.Descriptors = []SpliceDescriptor{other}
+	if b, err = Encode(&want); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = Decode(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Descriptors[0], other) {
+		t.Errorf("want %+v, got %+v", other, got.Descriptors[0])
+	}
+}
+
+func TestDeliveryRestrictions(t *testing.T) {
+	for _, want := range []*DeliveryRestrictions{nil, restrict(0), restrict(NoRegionalBlackout | DeviceRestrictGroup2)} {
+		desc := SegmentationDescriptor{EventID: 1, Restrictions: want, Type: BreakStart}
+		got, err := unmarshalSegDescriptor(desc.Data())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got.Restrictions, want) {
+			t.Errorf("restrictions %v: got %v", want, got.Restrictions)
+		}
+	}
+}
+
+func TestSegmentationComponents(t *testing.T) {
+	encoded := []byte{
+		0x00, 0x00, 0x00, 0x06, 0x3f, 0x7f,
+		0x01, 0x01, 0xfe, 0x00, 0x00, 0x00, 0x0a,
+		0x00, 0x00, 0x29, 0x32, 0xe0,
+		0x00, 0x00,
+		ProgramStart, 0x01, 0x01,
+	}
+	want := SegmentationDescriptor{
+		EventID:    6,
+		Components: []SegmentationComponent{{Tag: 1, PTSOffset: 10}},
+		Duration:   newuint64(30 * 90000),
+		UPID:       UPID{Value: []byte{}},
+		Type:       ProgramStart,
+		Number:     1,
+		Expected:   1,
+	}
+	got, err := unmarshalSegDescriptor(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decode: want %+v, got %+v", want, got)
+	}
+	if !bytes.Equal(want.Data(), encoded) {
+		t.Errorf("encode: want %#x, got %#x", encoded, want.Data())
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	b, err := base64.StdEncoding.DecodeString(samples[0].encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range b {
+		if _, err := Decode(b[:i]); err == nil {
+			t.Errorf("nil error decoding %d byte prefix", i)
+		}
+	}
+
+	corrupt := append([]byte{}, b...)
+	corrupt[len(corrupt)-1]++
+	var crcErr *CRCError
+	if _, err := Decode(corrupt); !errors.As(err, &crcErr) {
+		t.Errorf("want CRCError from corrupt checksum, got %v", err)
+	}
+
+	corrupt[0] = 0
+	if _, err := Decode(corrupt); !errors.Is(err, ErrTableID) {
+		t.Errorf("want %v, got %v", ErrTableID, err)
+	}
+
+	// shrink the descriptor loop length so it overflows the message
+	// but the section length and checksum remain valid.
+	short := append([]byte{}, b[:len(b)-4]...)
+	short[2] -= 10
+	short = short[:len(short)-10]
+	short = binary.BigEndian.AppendUint32(short, ^updateCRC(0, short))
+	if _, err := Decode(short); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("want %v, got %v", ErrShortBuffer, err)
+	}
+}
+
+// withChecksum returns a copy of b with a valid table ID, section
+// length and CRC_32 appended, so that fuzzed input may reach past
+// the checksum.
+func withChecksum(b []byte) []byte {
+	if len(b) < 3 || len(b)+4-3 > 0x0fff {
+		return b
+	}
+	b = append([]byte{}, b...)
+	b[0] = tableID
+	n := len(b) + 4 - 3
+	b[1] = b[1]&0xf0 | byte(n>>8)
+	b[2] = byte(n)
+	return binary.BigEndian.AppendUint32(b, ^updateCRC(0, b))
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, tt := range samples {
+		b, err := base64.StdEncoding.DecodeString(tt.encoded)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b[:len(b)-4])
+	}
+	for _, tt := range commandTests {
+		splice := Splice{Tier: maxTier, Command: &tt.want}
+		b, err := Encode(&splice)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b[:len(b)-4])
+	}
+	// a descriptor of the maximum length.
+	long := Splice{
+		Tier:        maxTier,
+		Command:     &Command{Type: SpliceNull},
+		Descriptors: []SpliceDescriptor{PrivateDescriptor{0xf0, 0x54455354, make([]byte, 251)}},
+	}
+	b, err := Encode(&long)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(b[:len(b)-4])
+	keys := ControlWords{0: []byte("8bytekey")}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		for _, in := range [][]byte{b, withChecksum(b)} {
+			splice, err := DecodeWithKeys(in, keys)
+			if err != nil {
+				continue
+			}
+			// anything we decode we must be able to encode,
+			// unless it holds a malformed UPID.
+			bb, err := EncodeWithKeys(splice, keys)
+			if errors.Is(err, ErrInvalidUPID) {
+				continue
+			} else if err != nil {
+				t.Fatalf("encode decoded splice: %v", err)
+			}
+			if _, err := DecodeWithKeys(bb, keys); err != nil {
+				t.Fatalf("decode re-encoded splice: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,127 @@
+package scte35
+
+import "time"
+
+// TicksPerSecond is the frequency of the clock counting
+// presentation timestamps and durations in splices.
+const TicksPerSecond = 90000
+
+// Ticks returns d as a number of ticks of a 90KHz clock,
+// rounded to the nearest tick. Negative durations return 0.
+func Ticks(d time.Duration) uint64 {
+	if d < 0 {
+		return 0
+	}
+	secs := uint64(d / time.Second)
+	frac := uint64(d % time.Second)
+	return secs*TicksPerSecond + (frac*TicksPerSecond+uint64(time.Second)/2)/uint64(time.Second)
+}
+
+// Duration returns ticks of a 90KHz clock as a time.Duration,
+// rounded to the nearest nanosecond.
+func Duration(ticks uint64) time.Duration {
+	secs := time.Duration(ticks/TicksPerSecond) * time.Second
+	frac := time.Duration(ticks % TicksPerSecond)
+	return secs + (frac*time.Second+TicksPerSecond/2)/TicksPerSecond
+}
+
+// AddPTS returns pts offset by d, which may be negative.
+// The result wraps around at the 33-bit limit of a PTS.
+func AddPTS(pts uint64, d time.Duration) uint64 {
+	if d < 0 {
+		return (pts - Ticks(-d)) & maxPTS
+	}
+	return (pts + Ticks(d)) & maxPTS
+}
+
+// subPTS returns the duration from b to a, allowing for wrapping.
+// The result is negative if a occurs before b.
+func subPTS(a, b uint64) time.Duration {
+	d := (a - b) & maxPTS
+	if d >= 1<<32 {
+		return -Duration(maxPTS + 1 - d)
+	}
+	return Duration(d)
+}
+
+// NewBreakDuration returns a BreakDuration of d.
+func NewBreakDuration(d time.Duration, autoReturn bool) *BreakDuration {
+	return &BreakDuration{AutoReturn: autoReturn, Duration: Ticks(d)}
+}
+
+// SpliceTime returns the presentation timestamp at which the splice's
+// command is executed, with PTSAdjustment applied.
+// The returned bool is false if the command has no time,
+// as in an immediate or component mode splice_insert,
+// or a time_signal with an unspecified time.
+func (s *Splice) SpliceTime() (uint64, bool) {
+	if s.Command == nil {
+		return 0, false
+	}
+	var pts *uint64
+	switch s.Command.Type {
+	case SpliceInsert:
+		if s.Command.Insert == nil || s.Command.Insert.Immediate {
+			return 0, false
+		}
+		pts = s.Command.Insert.SpliceTime
+	case TimeSignal:
+		pts = s.Command.TimeSignal
+	}
+	if pts == nil {
+		return 0, false
+	}
+	return (*pts + s.PTSAdjustment) & maxPTS, true
+}
+
+// Clock maps presentation timestamps to wall-clock time from a
+// reference point, such as a TimeDescriptor or the
+// EXT-X-PROGRAM-DATE-TIME tag of a HLS playlist.
+type Clock struct {
+	PTS  uint64
+	Time time.Time
+}
+
+// At returns the wall-clock time of pts. As timestamps wrap,
+// pts is assumed to be within half the range of a PTS
+// (about 13 hours) before or after c.PTS.
+func (c Clock) At(pts uint64) time.Time {
+	return c.Time.Add(subPTS(pts, c.PTS))
+}
+
+// PTSAt returns the presentation timestamp of the wall-clock time t.
+func (c Clock) PTSAt(t time.Time) uint64 {
+	return AddPTS(c.PTS, t.Sub(c.Time))
+}
+
+// Clock returns a Clock relating the splice time of s to the time
+// in its TimeDescriptor, as sent with a time_signal command.
+// The returned bool is false if s has no splice time or TimeDescriptor.
+func (s *Splice) Clock() (Clock, bool) {
+	pts, ok := s.SpliceTime()
+	if !ok {
+		return Clock{}, false
+	}
+	for _, d := range s.Descriptors {
+		if td, ok := d.(TimeDescriptor); ok {
+			return Clock{pts, td.Time()}, true
+		}
+	}
+	return Clock{}, false
+}
+
+// Time returns the time of d in UTC, calculated from TAI by
+// subtracting the UTC offset.
+func (d TimeDescriptor) Time() time.Time {
+	return time.Unix(int64(d.Seconds)-int64(d.UTCOffset), int64(d.Nanoseconds)).UTC()
+}
+
+// NewTimeDescriptor returns a TimeDescriptor of t, given the
+// current offset in seconds of UTC from TAI (37 since 2017).
+func NewTimeDescriptor(t time.Time, utcOffset uint16) TimeDescriptor {
+	return TimeDescriptor{
+		Seconds:     uint64(t.Unix() + int64(utcOffset)),
+		Nanoseconds: uint32(t.Nanosecond()),
+		UTCOffset:   utcOffset,
+	}
+}
@@ -0,0 +1,87 @@
+package scte35
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTicks(t *testing.T) {
+	var tests = []struct {
+		d     time.Duration
+		ticks uint64
+	}{
+		{0, 0},
+		{time.Second, 90000},
+		{30 * time.Second, 2700000},
+		{11111 * time.Nanosecond, 1},
+		{time.Second / 30, 3000},
+		{200 * time.Hour, 200 * 3600 * 90000},
+	}
+	for _, tt := range tests {
+		if got := Ticks(tt.d); got != tt.ticks {
+			t.Errorf("Ticks(%s) = %d, want %d", tt.d, got, tt.ticks)
+		}
+	}
+	for _, ticks := range []uint64{0, 1, 2, 89999, 90001, maxPTS, 1<<40 - 1} {
+		if got := Ticks(Duration(ticks)); got != ticks {
+			t.Errorf("Ticks(Duration(%d)) = %d", ticks, got)
+		}
+	}
+}
+
+func TestAddPTS(t *testing.T) {
+	if got := AddPTS(maxPTS, time.Second); got != 90000-1 {
+		t.Errorf("add past max: got %d", got)
+	}
+	if got := AddPTS(0, -time.Second); got != maxPTS-90000+1 {
+		t.Errorf("subtract past zero: got %d", got)
+	}
+	if d := subPTS(10, maxPTS); d != Duration(11) {
+		t.Errorf("subPTS across wrap = %s, want %s", d, Duration(11))
+	}
+	if d := subPTS(maxPTS, 10); d != -Duration(11) {
+		t.Errorf("subPTS across wrap = %s, want %s", d, -Duration(11))
+	}
+}
+
+func TestSpliceTime(t *testing.T) {
+	splice := samples[1].want
+	splice.PTSAdjustment = maxPTS
+	pts, ok := splice.SpliceTime()
+	if !ok {
+		t.Fatalf("no splice time in %+v", splice.Command.Insert)
+	}
+	if want := *splice.Command.Insert.SpliceTime - 1; pts != want {
+		t.Errorf("splice time = %d, want %d", pts, want)
+	}
+	immediate := Splice{Command: &Command{Type: SpliceInsert, Insert: &Insert{Immediate: true}}}
+	if _, ok := immediate.SpliceTime(); ok {
+		t.Errorf("immediate splice_insert should have no splice time")
+	}
+}
+
+func TestClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	splice := Splice{
+		Command:     &Command{Type: TimeSignal, TimeSignal: newuint64(maxPTS - 90000 + 1)},
+		Descriptors: []SpliceDescriptor{NewTimeDescriptor(now, 37)},
+	}
+	clock, ok := splice.Clock()
+	if !ok {
+		t.Fatal("no clock from time_signal with time descriptor")
+	}
+	if !clock.Time.Equal(now) {
+		t.Errorf("clock time %s, want %s", clock.Time, now)
+	}
+	// two seconds later, after the PTS has wrapped.
+	later := now.Add(2 * time.Second)
+	if got := clock.At(90000); !got.Equal(later) {
+		t.Errorf("clock.At(90000) = %s, want %s", got, later)
+	}
+	if got := clock.PTSAt(later); got != 90000 {
+		t.Errorf("clock.PTSAt(%s) = %d, want %d", later, got, 90000)
+	}
+	if got := clock.At(maxPTS - 2*90000 + 1); !got.Equal(now.Add(-time.Second)) {
+		t.Errorf("clock.At before reference = %s, want %s", got, now.Add(-time.Second))
+	}
+}
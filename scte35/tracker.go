@@ -0,0 +1,356 @@
+package scte35
+
+// Break is an ad break or program segment, signalled either by a
+// splice_insert command or by a segmentation descriptor.
+type Break struct {
+	// EventID is the splice_event_id of the splice_insert
+	// or the segmentation_event_id of the descriptor
+	// which started the break.
+	EventID uint32
+	// Segmentation is the descriptor which started the break,
+	// or nil if the break was started by a splice_insert.
+	Segmentation *SegmentationDescriptor
+	// Start is the time the break started,
+	// in ticks of a 90KHz clock.
+	Start uint64
+	// Duration is the expected duration of the break in ticks of
+	// a 90KHz clock, or nil if unknown.
+	Duration *uint64
+	// AutoReturn indicates that the break ends after Duration
+	// without a return signal.
+	AutoReturn bool
+}
+
+// BreakEventType is the kind of a BreakEvent.
+type BreakEventType uint8
+
+const (
+	BreakStarted BreakEventType = iota
+	// BreakEnded is a break ending with a return signal, at or
+	// after its expected duration, or by auto return.
+	BreakEnded
+	// BreakTruncated is a break ending before its expected
+	// duration, or ended by a cancellation, an early termination
+	// or the start of an overlapping break.
+	BreakTruncated
+)
+
+func (t BreakEventType) String() string {
+	switch t {
+	case BreakStarted:
+		return "started"
+	case BreakEnded:
+		return "ended"
+	case BreakTruncated:
+		return "truncated"
+	}
+	return "invalid"
+}
+
+// BreakEvent is a change in the state of a Break.
+type BreakEvent struct {
+	Type BreakEventType
+	// PTS is the time of the event, in ticks of a 90KHz clock.
+	PTS   uint64
+	Break *Break
+}
+
+// Tracker pairs the cue-out and cue-in signals of a stream,
+// reporting breaks as they start and end.
+//
+// Splice inserts are paired by splice_event_id. As only one
+// splice_insert break may be active at a time, a return with an
+// unknown ID ends the active break, and a new cue-out truncates it.
+// Segmentation descriptors are paired by segmentation_event_id and
+// by type, such as ProviderAdStart with ProviderAdEnd. A segment
+// starting while another of the same type is active truncates it,
+// except for a ProgramOverlapStart, which leaves the running program
+// active until its own ProgramEnd.
+//
+// Signals take effect at their splice time, adjusted by the splice's
+// PTSAdjustment, rather than when they are received. This lets
+// repeated signals and cancellations be handled before the break
+// they refer to starts.
+//
+// The zero value is ready to use.
+type Tracker struct {
+	pending []cue
+	active  []*Break
+}
+
+// cue is a signal waiting for its splice time.
+type cue struct {
+	pts   uint64
+	start bool
+	// brk is the break started by a start cue,
+	// or the break ended by an auto return.
+	brk *Break
+
+	insert bool
+	id     uint32
+	// typ is the segmentation type which ends the break.
+	typ uint8
+	// early reports that the cue ends a break early,
+	// as in a ProgramEarlyTermination.
+	early bool
+	// overlap reports that the break starts without ending
+	// those of the same kind, as in a ProgramOverlapStart.
+	overlap bool
+}
+
+// Update processes splice, received when the stream is at pts,
+// and returns the events occurring up to and including pts.
+// Events are ordered by time.
+func (t *Tracker) Update(splice *Splice, pts uint64) []BreakEvent {
+	events := t.Advance(pts)
+	if splice.Command == nil {
+		return events
+	}
+
+	when, ok := splice.SpliceTime()
+	if !ok {
+		when = pts
+	}
+	switch splice.Command.Type {
+	case SpliceInsert:
+		if splice.Command.Insert != nil {
+			events = append(events, t.insert(splice.Command.Insert, when, pts)...)
+		}
+	case TimeSignal:
+	default:
+		return events
+	}
+
+	for _, d := range splice.Descriptors {
+		seg, ok := d.(SegmentationDescriptor)
+		if !ok {
+			continue
+		}
+		events = append(events, t.segment(seg, when, pts)...)
+	}
+	return append(events, t.Advance(pts)...)
+}
+
+func (t *Tracker) insert(ins *Insert, when, now uint64) []BreakEvent {
+	if ins.Cancel {
+		return t.cancel(true, ins.ID, now)
+	}
+	c := cue{pts: when, insert: true, id: ins.ID}
+	if ins.OutOfNetwork {
+		c.start = true
+		c.brk = &Break{EventID: ins.ID, Start: when}
+		if ins.Duration != nil {
+			d := ins.Duration.Duration
+			c.brk.Duration = &d
+			c.brk.AutoReturn = ins.Duration.AutoReturn
+		}
+	}
+	t.schedule(c)
+	return nil
+}
+
+func (t *Tracker) segment(seg SegmentationDescriptor, when, now uint64) []BreakEvent {
+	if seg.Cancel {
+		return t.cancel(false, seg.EventID, now)
+	}
+	c := cue{pts: when, id: seg.EventID}
+	if end := segmentationEnd(seg.Type); end != 0 {
+		c.start = true
+		c.typ = end
+		c.overlap = seg.Type == ProgramOverlapStart
+		desc := seg
+		c.brk = &Break{
+			EventID:      seg.EventID,
+			Segmentation: &desc,
+			Start:        when,
+			Duration:     seg.Duration,
+		}
+	} else if seg.Type == ProgramEarlyTermination {
+		c.typ = ProgramEnd
+		c.early = true
+	} else if isSegmentationEnd(seg.Type) {
+		c.typ = seg.Type
+	} else {
+		return nil
+	}
+	t.schedule(c)
+	return nil
+}
+
+// segmentationEnd returns the segmentation type which ends a segment
+// started by typ, or 0 if typ does not start a segment.
+func segmentationEnd(typ uint8) uint8 {
+	switch {
+	case typ == ProgramStart, typ == ProgramOverlapStart, typ == ProgramStartInProgress:
+		return ProgramEnd
+	case typ == NetworkStart:
+		return NetworkEnd
+	case typ >= ChapterStart && typ <= DistributorAdBlockStart && typ%2 == 0:
+		return typ + 1
+	}
+	return 0
+}
+
+func isSegmentationEnd(typ uint8) bool {
+	switch {
+	case typ == ProgramEnd, typ == NetworkEnd:
+		return true
+	case typ >= ChapterEnd && typ <= DistributorAdBlockEnd && typ%2 == 1:
+		return true
+	}
+	return false
+}
+
+// schedule adds c to the pending cues,
+// replacing any repetition of the same signal.
+func (t *Tracker) schedule(c cue) {
+	for i := range t.pending {
+		p := &t.pending[i]
+		if p.brk != nil && !p.start {
+			continue // auto return
+		}
+		if p.start == c.start && p.insert == c.insert && p.id == c.id && p.typ == c.typ {
+			*p = c
+			return
+		}
+	}
+	t.pending = append(t.pending, c)
+}
+
+// cancel removes the pending cues and active breaks of the
+// splice_insert or segmentation event id.
+func (t *Tracker) cancel(insert bool, id uint32, now uint64) []BreakEvent {
+	var pending []cue
+	for _, c := range t.pending {
+		if c.insert != insert || c.id != id {
+			pending = append(pending, c)
+		}
+	}
+	t.pending = pending
+
+	var events []BreakEvent
+	for i := 0; i < len(t.active); i++ {
+		b := t.active[i]
+		if (b.Segmentation == nil) == insert && b.EventID == id {
+			events = append(events, BreakEvent{BreakTruncated, now, b})
+			t.remove(i)
+			i--
+		}
+	}
+	return events
+}
+
+// Advance returns the events occurring up to and including pts
+// from signals already received, such as breaks starting at a
+// splice time after the signal or ending by auto return.
+func (t *Tracker) Advance(pts uint64) []BreakEvent {
+	var events []BreakEvent
+	for {
+		next := -1
+		for i, c := range t.pending {
+			if ptsBefore(pts, c.pts) {
+				continue // not yet due
+			}
+			if next < 0 || ptsBefore(c.pts, t.pending[next].pts) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return events
+		}
+		c := t.pending[next]
+		t.pending = append(t.pending[:next], t.pending[next+1:]...)
+		events = append(events, t.apply(c)...)
+	}
+}
+
+// Active returns the breaks which have started but not ended,
+// in the order they started.
+func (t *Tracker) Active() []*Break {
+	return append([]*Break(nil), t.active...)
+}
+
+func (t *Tracker) apply(c cue) []BreakEvent {
+	if c.start {
+		return t.start(c)
+	}
+	i := t.find(c)
+	if i < 0 {
+		return nil
+	}
+	b := t.active[i]
+	t.remove(i)
+	typ := BreakEnded
+	if c.early {
+		typ = BreakTruncated
+	} else if b.Duration != nil && ptsBefore(c.pts, (b.Start+*b.Duration)&maxPTS) {
+		typ = BreakTruncated
+	}
+	return []BreakEvent{{typ, c.pts, b}}
+}
+
+func (t *Tracker) start(c cue) []BreakEvent {
+	var events []BreakEvent
+	for i := 0; i < len(t.active); i++ {
+		b := t.active[i]
+		if !sameKind(b, c) {
+			continue
+		}
+		if b.EventID == c.id {
+			// A repeated signal for a break already started.
+			return nil
+		}
+		if c.overlap {
+			continue
+		}
+		events = append(events, BreakEvent{BreakTruncated, c.pts, b})
+		t.remove(i)
+		i--
+	}
+	t.active = append(t.active, c.brk)
+	events = append(events, BreakEvent{BreakStarted, c.pts, c.brk})
+	if c.brk.AutoReturn && c.brk.Duration != nil {
+		end := (c.brk.Start + *c.brk.Duration) & maxPTS
+		t.pending = append(t.pending, cue{pts: end, brk: c.brk, insert: true, id: c.id})
+	}
+	return events
+}
+
+// find returns the index of the active break ended by c, or -1.
+func (t *Tracker) find(c cue) int {
+	if c.brk != nil {
+		// auto return
+		for i, b := range t.active {
+			if b == c.brk {
+				return i
+			}
+		}
+		return -1
+	}
+	fallback := -1
+	for i, b := range t.active {
+		if !sameKind(b, c) {
+			continue
+		}
+		if b.EventID == c.id {
+			return i
+		}
+		if c.insert {
+			fallback = i
+		}
+	}
+	return fallback
+}
+
+// sameKind reports whether b and c are both splice inserts,
+// or segments ended by the same segmentation type.
+func sameKind(b *Break, c cue) bool {
+	if c.insert {
+		return b.Segmentation == nil
+	}
+	return b.Segmentation != nil && segmentationEnd(b.Segmentation.Type) == c.typ
+}
+
+func (t *Tracker) remove(i int) {
+	t.active = append(t.active[:i], t.active[i+1:]...)
+}
@@ -0,0 +1,151 @@
+package scte35
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func outInsert(id uint32, at uint64, dur *BreakDuration) *Splice {
+	return &Splice{Command: &Command{
+		Type:   SpliceInsert,
+		Insert: &Insert{ID: id, OutOfNetwork: true, SpliceTime: &at, Duration: dur},
+	}}
+}
+
+func inInsert(id uint32, at uint64) *Splice {
+	return &Splice{Command: &Command{
+		Type:   SpliceInsert,
+		Insert: &Insert{ID: id, SpliceTime: &at},
+	}}
+}
+
+func segSignal(at uint64, descs ...SpliceDescriptor) *Splice {
+	return &Splice{
+		Command:     &Command{Type: TimeSignal, TimeSignal: &at},
+		Descriptors: descs,
+	}
+}
+
+// step is a call to Update, or to Advance if splice is nil.
+type step struct {
+	splice *Splice
+	pts    uint64
+	// want lists each event as its type, event ID and time.
+	want []string
+}
+
+func TestTracker(t *testing.T) {
+	const second = 90000
+	var tests = []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "auto return",
+			steps: []step{
+				{outInsert(1, 2*second, &BreakDuration{true, 30 * second}), 0, nil},
+				{nil, 2 * second, []string{"started 1 180000"}},
+				{nil, 10 * second, nil},
+				{nil, 40 * second, []string{"ended 1 2880000"}},
+			},
+		},
+		{
+			name: "repeated and unmatched return",
+			steps: []step{
+				{outInsert(1, second, nil), 0, nil},
+				{outInsert(1, second, nil), second / 2, nil},
+				{outInsert(1, second, nil), 2 * second, []string{"started 1 90000"}},
+				{inInsert(2, 5*second), 3 * second, nil},
+				{nil, 5 * second, []string{"ended 1 450000"}},
+			},
+		},
+		{
+			name: "cancel",
+			steps: []step{
+				{outInsert(1, 10*second, &BreakDuration{true, 30 * second}), 0, nil},
+				{&Splice{Command: &Command{Type: SpliceInsert, Insert: &Insert{ID: 1, Cancel: true}}}, second, nil},
+				{nil, 60 * second, nil},
+			},
+		},
+		{
+			name: "early return",
+			steps: []step{
+				{outInsert(1, 0, &BreakDuration{false, 30 * second}), 0, []string{"started 1 0"}},
+				{inInsert(1, 20*second), 20 * second, []string{"truncated 1 1800000"}},
+			},
+		},
+		{
+			name: "new cue out",
+			steps: []step{
+				{outInsert(1, 0, nil), 0, []string{"started 1 0"}},
+				{outInsert(2, second, nil), second, []string{"truncated 1 90000", "started 2 90000"}},
+			},
+		},
+		{
+			name: "segments",
+			steps: []step{
+				{segSignal(0,
+					SegmentationDescriptor{EventID: 10, Type: ProgramStart},
+					SegmentationDescriptor{EventID: 1, Type: ProviderPlacementOppStart, Duration: newuint64(30 * second)},
+				), 0, []string{"started 10 0", "started 1 0"}},
+				{segSignal(10*second,
+					SegmentationDescriptor{EventID: 2, Type: ProviderPlacementOppStart},
+				), 10 * second, []string{"truncated 1 900000", "started 2 900000"}},
+				{segSignal(20*second,
+					SegmentationDescriptor{EventID: 3, Type: ProviderPlacementOppEnd},
+					SegmentationDescriptor{EventID: 2, Type: ProviderPlacementOppEnd},
+				), 20 * second, []string{"ended 2 1800000"}},
+				{segSignal(30*second,
+					SegmentationDescriptor{EventID: 10, Type: ProgramEarlyTermination},
+				), 30 * second, []string{"truncated 10 2700000"}},
+			},
+		},
+		{
+			name: "program overlap",
+			steps: []step{
+				{segSignal(100, SegmentationDescriptor{EventID: 1, Type: ProgramStart}), 100, []string{"started 1 100"}},
+				{segSignal(200, SegmentationDescriptor{EventID: 2, Type: ProgramOverlapStart}), 200, []string{"started 2 200"}},
+				{segSignal(300, SegmentationDescriptor{EventID: 1, Type: ProgramEnd}), 300, []string{"ended 1 300"}},
+				{segSignal(400, SegmentationDescriptor{EventID: 2, Type: ProgramEnd}), 400, []string{"ended 2 400"}},
+			},
+		},
+		{
+			name: "segment cancel",
+			steps: []step{
+				{segSignal(0, SegmentationDescriptor{EventID: 5, Type: ChapterStart}), 0, []string{"started 5 0"}},
+				{segSignal(0, SegmentationDescriptor{EventID: 5, Cancel: true}), second, []string{"truncated 5 90000"}},
+				{segSignal(2*second, SegmentationDescriptor{EventID: 5, Type: ChapterEnd}), 2 * second, nil},
+			},
+		},
+		{
+			name: "pts wraps",
+			steps: []step{
+				{outInsert(1, maxPTS-second+1, &BreakDuration{true, 2 * second}), maxPTS - 2*second, nil},
+				{nil, maxPTS, []string{"started 1 8589844592"}},
+				{nil, second / 2, nil},
+				{nil, second, []string{"ended 1 90000"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tracker Tracker
+			for i, s := range tt.steps {
+				var events []BreakEvent
+				if s.splice == nil {
+					events = tracker.Advance(s.pts)
+				} else {
+					events = tracker.Update(s.splice, s.pts)
+				}
+				var got []string
+				for _, ev := range events {
+					got = append(got, fmt.Sprintf("%s %d %d", ev.Type, ev.Break.EventID, ev.PTS))
+				}
+				if !reflect.DeepEqual(got, s.want) {
+					t.Errorf("step %d: want events %q, got %q", i, s.want, got)
+				}
+			}
+		})
+	}
+}
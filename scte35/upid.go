@@ -0,0 +1,460 @@
+package scte35
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func (t UPIDType) String() string {
+	switch t {
+	case UPIDNone:
+		return "none"
+	case 0x01:
+		return "user defined"
+	case 0x02:
+		return "ISCI"
+	case UPIDAdID:
+		return "Ad-ID"
+	case UPIDUMID:
+		return "UMID"
+	case 0x05, UPIDISAN:
+		return "ISAN"
+	case UPIDTID:
+		return "TID"
+	case UPIDTI:
+		return "TI"
+	case UPIDADI:
+		return "ADI"
+	case UPIDEIDR:
+		return "EIDR"
+	case UPIDATSCContentID:
+		return "ATSC content identifier"
+	case UPIDMPU:
+		return "MPU"
+	case UPIDMID:
+		return "MID"
+	case UPIDADSInfo:
+		return "ADS information"
+	case UPIDURI:
+		return "URI"
+	case UPIDUUID:
+		return "UUID"
+	case UPIDSCR:
+		return "SCR"
+	}
+	return "reserved"
+}
+
+// upidLengths holds the length in bytes of UPID types of a fixed length,
+// as listed in Table 22 of SCTE 35 section 10.3.3.1.
+var upidLengths = map[UPIDType]int{
+	UPIDNone: 0,
+	0x02:     8, // ISCI
+	UPIDAdID: 12,
+	UPIDUMID: 32,
+	0x05:     8, // deprecated ISAN
+	UPIDISAN: 12,
+	UPIDTID:  12,
+	UPIDTI:   8,
+	UPIDEIDR: 12,
+	UPIDUUID: 16,
+}
+
+// Validate reports whether u's value is well-formed for its type.
+// Values of deprecated, reserved and unrecognised types are only
+// checked for length. The UPIDs held by a MID are validated in turn.
+// Encode validates the UPID of each SegmentationDescriptor.
+func (u UPID) Validate() error {
+	if len(u.Value) > 0xff {
+		return fmt.Errorf("%s: length %d greater than max 255: %w", u.Type, len(u.Value), ErrInvalidUPID)
+	}
+	if n, ok := upidLengths[u.Type]; ok && len(u.Value) != n {
+		return fmt.Errorf("%s: length %d, want %d: %w", u.Type, len(u.Value), n, ErrInvalidUPID)
+	}
+	switch u.Type {
+	case UPIDAdID, UPIDTID:
+		if !isAlnum(u.Value) {
+			return fmt.Errorf("%s: non-alphanumeric characters in %q: %w", u.Type, u.Value, ErrInvalidUPID)
+		}
+	case UPIDADI, UPIDADSInfo:
+		if !isPrint(u.Value) {
+			return fmt.Errorf("%s: non-printable characters in %q: %w", u.Type, u.Value, ErrInvalidUPID)
+		}
+	case UPIDURI:
+		if !isPrint(u.Value) {
+			return fmt.Errorf("%s: non-printable characters in %q: %w", u.Type, u.Value, ErrInvalidUPID)
+		}
+		if _, err := url.Parse(string(u.Value)); err != nil {
+			return fmt.Errorf("%s: %v: %w", u.Type, err, ErrInvalidUPID)
+		}
+	case UPIDMPU:
+		if len(u.Value) < 4 {
+			return fmt.Errorf("%s: missing format identifier: %w", u.Type, ErrInvalidUPID)
+		}
+	case UPIDMID:
+		upids, err := u.MID()
+		if err != nil {
+			return err
+		}
+		for i, upid := range upids {
+			if err := upid.Validate(); err != nil {
+				return fmt.Errorf("%s %d: %w", u.Type, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// String returns the value of u formatted according to its type,
+// such as the canonical form of an EIDR or ISAN, for use in logs.
+// Values which cannot be formatted are written in hexadecimal.
+func (u UPID) String() string {
+	if len(u.Value) == 0 {
+		return u.Type.String()
+	}
+	var s string
+	var err error
+	switch u.Type {
+	case UPIDAdID, UPIDTID, UPIDADI, UPIDADSInfo, UPIDURI, 0x02:
+		if isPrint(u.Value) {
+			s = string(u.Value)
+		} else {
+			err = ErrInvalidUPID
+		}
+	case UPIDUMID:
+		s, err = u.UMID()
+	case UPIDISAN:
+		s, err = u.ISAN()
+	case UPIDTI:
+		var ti uint64
+		ti, err = u.TI()
+		s = fmt.Sprintf("%#x", ti)
+	case UPIDEIDR:
+		s, err = u.EIDR()
+	case UPIDUUID:
+		s, err = u.UUID()
+	case UPIDMPU:
+		var format uint32
+		var data []byte
+		format, data, err = u.MPU()
+		s = fmt.Sprintf("%#08x %x", format, data)
+	case UPIDMID:
+		var upids []UPID
+		upids, err = u.MID()
+		ss := make([]string, len(upids))
+		for i := range upids {
+			ss[i] = upids[i].String()
+		}
+		s = "[" + strings.Join(ss, ", ") + "]"
+	default:
+		err = ErrInvalidUPID
+	}
+	if err != nil {
+		s = fmt.Sprintf("%#x", u.Value)
+	}
+	return u.Type.String() + " " + s
+}
+
+func (u UPID) checkType(t UPIDType) error {
+	if u.Type != t {
+		return fmt.Errorf("upid type is %s, not %s: %w", u.Type, t, ErrInvalidUPID)
+	}
+	if n, ok := upidLengths[t]; ok && len(u.Value) != n {
+		return fmt.Errorf("%s: length %d, want %d: %w", t, len(u.Value), n, ErrInvalidUPID)
+	}
+	return nil
+}
+
+// NewAdID returns a UPID holding the 12 character Ad-ID identifier id.
+func NewAdID(id string) (UPID, error) {
+	u := UPID{Type: UPIDAdID, Value: []byte(id)}
+	return u, u.Validate()
+}
+
+// AdID returns the Ad-ID identifier held by u.
+func (u UPID) AdID() (string, error) {
+	if err := u.checkType(UPIDAdID); err != nil {
+		return "", err
+	}
+	return string(u.Value), nil
+}
+
+// NewADI returns a UPID holding the CableLabs metadata identifier id,
+// such as "PROVIDER.COM/ASSET0001".
+func NewADI(id string) (UPID, error) {
+	u := UPID{Type: UPIDADI, Value: []byte(id)}
+	return u, u.Validate()
+}
+
+// ADI returns the CableLabs metadata identifier held by u.
+func (u UPID) ADI() (string, error) {
+	if err := u.checkType(UPIDADI); err != nil {
+		return "", err
+	}
+	return string(u.Value), nil
+}
+
+// NewTI returns a UPID holding the Turner Identifier ti.
+func NewTI(ti uint64) UPID {
+	return UPID{Type: UPIDTI, Value: binary.BigEndian.AppendUint64(nil, ti)}
+}
+
+// TI returns the Turner Identifier held by u.
+func (u UPID) TI() (uint64, error) {
+	if err := u.checkType(UPIDTI); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(u.Value), nil
+}
+
+// NewUMID returns a UPID holding the SMPTE 330 Unique Material
+// Identifier umid, written as 8 dot-separated groups of 8 hexadecimal
+// digits as in SCTE 35 section 10.3.3.3. For example:
+//
+//	060a2b34.01010105.01010d20.13000000.d2c9036c.8f195343.ab7014d2.d718bfda
+func NewUMID(umid string) (UPID, error) {
+	groups := strings.Split(umid, ".")
+	if len(groups) != 8 {
+		return UPID{}, fmt.Errorf("umid %q: want 8 groups, have %d: %w", umid, len(groups), ErrInvalidUPID)
+	}
+	var b []byte
+	for _, g := range groups {
+		if len(g) != 8 {
+			return UPID{}, fmt.Errorf("umid %q: group %q not 8 digits: %w", umid, g, ErrInvalidUPID)
+		}
+		p, err := hex.DecodeString(g)
+		if err != nil {
+			return UPID{}, fmt.Errorf("umid %q: %v: %w", umid, err, ErrInvalidUPID)
+		}
+		b = append(b, p...)
+	}
+	return UPID{Type: UPIDUMID, Value: b}, nil
+}
+
+// UMID returns the Unique Material Identifier held by u in the form
+// accepted by NewUMID.
+func (u UPID) UMID() (string, error) {
+	if err := u.checkType(UPIDUMID); err != nil {
+		return "", err
+	}
+	groups := make([]string, 0, 8)
+	for i := 0; i < len(u.Value); i += 4 {
+		groups = append(groups, hex.EncodeToString(u.Value[i:i+4]))
+	}
+	return strings.Join(groups, "."), nil
+}
+
+// NewISAN returns a UPID holding the version ISAN isan in its
+// canonical form, such as "0000-0000-3A8D-0000-Z-0000-0000-6".
+// A leading "ISAN " is ignored. Check characters are verified.
+func NewISAN(isan string) (UPID, error) {
+	s := strings.TrimPrefix(strings.ToUpper(isan), "ISAN ")
+	groups := strings.Split(s, "-")
+	if len(groups) != 8 || len(groups[4]) != 1 || len(groups[7]) != 1 {
+		return UPID{}, fmt.Errorf("isan %q: not in form XXXX-XXXX-XXXX-XXXX-C-XXXX-XXXX-C: %w", isan, ErrInvalidUPID)
+	}
+	root := strings.Join(groups[:4], "")
+	version := groups[5] + groups[6]
+	b, err := hex.DecodeString(root + version)
+	if err != nil || len(root) != 16 || len(version) != 8 {
+		return UPID{}, fmt.Errorf("isan %q: malformed hexadecimal groups: %w", isan, ErrInvalidUPID)
+	}
+	if c := mod3736(root); c != groups[4][0] {
+		return UPID{}, fmt.Errorf("isan %q: check character %c, want %c: %w", isan, groups[4][0], c, ErrInvalidUPID)
+	}
+	if c := mod3736(root + version); c != groups[7][0] {
+		return UPID{}, fmt.Errorf("isan %q: check character %c, want %c: %w", isan, groups[7][0], c, ErrInvalidUPID)
+	}
+	return UPID{Type: UPIDISAN, Value: b}, nil
+}
+
+// ISAN returns the version ISAN held by u in its canonical form,
+// without the leading "ISAN ".
+func (u UPID) ISAN() (string, error) {
+	if err := u.checkType(UPIDISAN); err != nil {
+		return "", err
+	}
+	h := strings.ToUpper(hex.EncodeToString(u.Value))
+	root, version := h[:16], h[16:]
+	return fmt.Sprintf("%s-%s-%s-%s-%c-%s-%s-%c",
+		root[0:4], root[4:8], root[8:12], root[12:16], mod3736(root),
+		version[0:4], version[4:8], mod3736(h),
+	), nil
+}
+
+// NewEIDR returns a UPID holding the EIDR identifier eidr in its
+// canonical form, such as "10.5240/7791-8534-2C23-9030-8610-5".
+// The check character is optional but verified if present.
+func NewEIDR(eidr string) (UPID, error) {
+	prefix, suffix, ok := strings.Cut(strings.ToUpper(eidr), "/")
+	if !ok || !strings.HasPrefix(prefix, "10.") {
+		return UPID{}, fmt.Errorf("eidr %q: not in form 10.NNNN/XXXX-XXXX-XXXX-XXXX-XXXX-C: %w", eidr, ErrInvalidUPID)
+	}
+	sub, err := strconv.ParseUint(strings.TrimPrefix(prefix, "10."), 10, 16)
+	if err != nil {
+		return UPID{}, fmt.Errorf("eidr %q: prefix: %v: %w", eidr, err, ErrInvalidUPID)
+	}
+	groups := strings.Split(suffix, "-")
+	var check string
+	if len(groups) == 6 {
+		check = groups[5]
+		groups = groups[:5]
+	}
+	s := strings.Join(groups, "")
+	b, err := hex.DecodeString(s)
+	if err != nil || len(groups) != 5 || len(s) != 20 {
+		return UPID{}, fmt.Errorf("eidr %q: malformed suffix: %w", eidr, ErrInvalidUPID)
+	}
+	if check != "" {
+		if c := mod3736(s); check != string(c) {
+			return UPID{}, fmt.Errorf("eidr %q: check character %s, want %c: %w", eidr, check, c, ErrInvalidUPID)
+		}
+	}
+	v := binary.BigEndian.AppendUint16(nil, uint16(sub))
+	return UPID{Type: UPIDEIDR, Value: append(v, b...)}, nil
+}
+
+// EIDR returns the EIDR identifier held by u in its canonical form.
+func (u UPID) EIDR() (string, error) {
+	if err := u.checkType(UPIDEIDR); err != nil {
+		return "", err
+	}
+	sub := binary.BigEndian.Uint16(u.Value[:2])
+	h := strings.ToUpper(hex.EncodeToString(u.Value[2:]))
+	return fmt.Sprintf("10.%d/%s-%s-%s-%s-%s-%c", sub, h[0:4], h[4:8], h[8:12], h[12:16], h[16:20], mod3736(h)), nil
+}
+
+// NewUUID returns a UPID holding the RFC 4122 UUID uuid in its
+// canonical form, such as "f81d4fae-7dec-11d0-a765-00a0c91e6bf6".
+func NewUUID(uuid string) (UPID, error) {
+	groups := strings.Split(uuid, "-")
+	if len(groups) != 5 || len(groups[0]) != 8 || len(groups[1]) != 4 || len(groups[2]) != 4 || len(groups[3]) != 4 || len(groups[4]) != 12 {
+		return UPID{}, fmt.Errorf("uuid %q: not in form 8-4-4-4-12: %w", uuid, ErrInvalidUPID)
+	}
+	b, err := hex.DecodeString(strings.Join(groups, ""))
+	if err != nil {
+		return UPID{}, fmt.Errorf("uuid %q: %v: %w", uuid, err, ErrInvalidUPID)
+	}
+	return UPID{Type: UPIDUUID, Value: b}, nil
+}
+
+// UUID returns the UUID held by u in its canonical form.
+func (u UPID) UUID() (string, error) {
+	if err := u.checkType(UPIDUUID); err != nil {
+		return "", err
+	}
+	h := hex.EncodeToString(u.Value)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:]), nil
+}
+
+// NewURI returns a UPID holding the URI uri.
+func NewURI(uri *url.URL) (UPID, error) {
+	u := UPID{Type: UPIDURI, Value: []byte(uri.String())}
+	return u, u.Validate()
+}
+
+// URI returns the URI held by u.
+func (u UPID) URI() (*url.URL, error) {
+	if err := u.checkType(UPIDURI); err != nil {
+		return nil, err
+	}
+	uri, err := url.Parse(string(u.Value))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %w", u.Type, err, ErrInvalidUPID)
+	}
+	return uri, nil
+}
+
+// NewMPU returns a UPID holding data in the private format registered
+// with SMPTE Registration Authority as format, as specified in
+// SCTE 35 section 10.3.3.3.
+func NewMPU(format uint32, data []byte) (UPID, error) {
+	v := binary.BigEndian.AppendUint32(nil, format)
+	u := UPID{Type: UPIDMPU, Value: append(v, data...)}
+	return u, u.Validate()
+}
+
+// MPU returns the format identifier and private data held by u.
+func (u UPID) MPU() (format uint32, data []byte, err error) {
+	if err := u.checkType(UPIDMPU); err != nil {
+		return 0, nil, err
+	}
+	if len(u.Value) < 4 {
+		return 0, nil, fmt.Errorf("%s: missing format identifier: %w", u.Type, ErrInvalidUPID)
+	}
+	return binary.BigEndian.Uint32(u.Value[:4]), u.Value[4:], nil
+}
+
+// NewMID returns a UPID holding multiple UPIDs.
+func NewMID(upids ...UPID) (UPID, error) {
+	var b []byte
+	for i, upid := range upids {
+		if err := upid.Validate(); err != nil {
+			return UPID{}, fmt.Errorf("upid %d: %w", i, err)
+		}
+		b = append(b, byte(upid.Type), byte(len(upid.Value)))
+		b = append(b, upid.Value...)
+	}
+	u := UPID{Type: UPIDMID, Value: b}
+	return u, u.Validate()
+}
+
+// MID returns the UPIDs held by u.
+func (u UPID) MID() ([]UPID, error) {
+	if err := u.checkType(UPIDMID); err != nil {
+		return nil, err
+	}
+	var upids []UPID
+	b := u.Value
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return upids, fmt.Errorf("%s: %w", u.Type, errShort("upid", 2, len(b)))
+		}
+		n := int(b[1])
+		if len(b[2:]) < n {
+			return upids, fmt.Errorf("%s: %w", u.Type, errShort("upid", n, len(b[2:])))
+		}
+		upids = append(upids, UPID{Type: UPIDType(b[0]), Value: b[2 : 2+n]})
+		b = b[2+n:]
+	}
+	return upids, nil
+}
+
+// mod3736 returns the ISO/IEC 7064 MOD 37,36 check character of the
+// alphanumeric string s, as used by ISAN and EIDR.
+func mod3736(s string) byte {
+	const chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	p := 36
+	for i := 0; i < len(s); i++ {
+		a := strings.IndexByte(chars, s[i])
+		t := (p + a) % 36
+		if t == 0 {
+			t = 36
+		}
+		p = t * 2 % 37
+	}
+	return chars[(37-p)%36]
+}
+
+func isAlnum(b []byte) bool {
+	for _, c := range b {
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// isPrint reports whether b holds only printable ASCII characters.
+func isPrint(b []byte) bool {
+	for _, c := range b {
+		if c < ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
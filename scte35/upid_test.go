@@ -0,0 +1,122 @@
+package scte35
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestUPIDRoundTrip(t *testing.T) {
+	tests := []struct {
+		s      string
+		new    func(string) (UPID, error)
+		access func(UPID) (string, error)
+	}{
+		{"ABCD0001000H", NewAdID, UPID.AdID},
+		{"SIGNAL.COM/SPOT0001", NewADI, UPID.ADI},
+		{"10.5240/7791-8534-2C23-9030-8610-5", NewEIDR, UPID.EIDR},
+		{"0000-0000-D07A-0090-Q-0000-0000-X", NewISAN, UPID.ISAN},
+		{"060a2b34.01010105.01010d20.13000000.d2c9036c.8f195343.ab7014d2.d718bfda", NewUMID, UPID.UMID},
+		{"f81d4fae-7dec-11d0-a765-00a0c91e6bf6", NewUUID, UPID.UUID},
+	}
+	for _, tt := range tests {
+		upid, err := tt.new(tt.s)
+		if err != nil {
+			t.Errorf("new upid from %s: %v", tt.s, err)
+			continue
+		}
+		if err := upid.Validate(); err != nil {
+			t.Errorf("validate %s: %v", tt.s, err)
+		}
+		got, err := tt.access(upid)
+		if err != nil {
+			t.Errorf("access %s: %v", upid.Type, err)
+		} else if got != tt.s {
+			t.Errorf("%s: got %s, want %s", upid.Type, got, tt.s)
+		}
+	}
+
+	// the EIDR check character is optional when constructing.
+	upid, err := NewEIDR("10.5240/7791-8534-2C23-9030-8610")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := upid.EIDR(); s != tests[2].s {
+		t.Errorf("eidr without check character: got %s, want %s", s, tests[2].s)
+	}
+}
+
+func TestMID(t *testing.T) {
+	adid, err := NewAdID("ABCD0001000H")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, err := NewURI(&url.URL{Scheme: "urn", Opaque: "uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mpu, err := NewMPU(0x43554549, []byte{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner, err := NewMID(NewTI(0x2ca0a18a), mpu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mid, err := NewMID(adid, uri, inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upids, err := mid.MID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []UPID{adid, uri, inner}
+	if !reflect.DeepEqual(upids, want) {
+		t.Errorf("got %v, want %v", upids, want)
+	}
+	const s = "MID [Ad-ID ABCD0001000H, URI urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6, MID [TI 0x2ca0a18a, MPU 0x43554549 010203]]"
+	if mid.String() != s {
+		t.Errorf("got string %q, want %q", mid.String(), s)
+	}
+
+	// a truncated UPID within a MID must not be valid.
+	bad := UPID{Type: UPIDMID, Value: mid.Value[:len(mid.Value)-1]}
+	if err := bad.Validate(); !errors.Is(err, ErrShortBuffer) {
+		t.Errorf("validate truncated mid: want %v, got %v", ErrShortBuffer, err)
+	}
+}
+
+func TestInvalidUPID(t *testing.T) {
+	invalid := []struct {
+		s   string
+		new func(string) (UPID, error)
+	}{
+		{"ABCD0001000", NewAdID},
+		{"ABCD 001000H", NewAdID},
+		{"10.5240/7791-8534-2C23-9030-8610-6", NewEIDR},
+		{"11.5240/7791-8534-2C23-9030-8610-5", NewEIDR},
+		{"0000-0000-D07A-0090-R-0000-0000-X", NewISAN},
+		{"0000-0000-D07A-0090-Q-0000-0000", NewISAN},
+		{"060a2b34.01010105", NewUMID},
+		{"f81d4fae7dec11d0a76500a0c91e6bf6", NewUUID},
+	}
+	for _, tt := range invalid {
+		if _, err := tt.new(tt.s); !errors.Is(err, ErrInvalidUPID) {
+			t.Errorf("new upid from %q: want %v, got %v", tt.s, ErrInvalidUPID, err)
+		}
+	}
+
+	if _, err := NewTI(1).AdID(); !errors.Is(err, ErrInvalidUPID) {
+		t.Errorf("access TI as Ad-ID: want %v, got %v", ErrInvalidUPID, err)
+	}
+
+	splice := samples[0].want
+	seg := splice.Descriptors[0].(SegmentationDescriptor)
+	seg.UPID.Value = seg.UPID.Value[:4]
+	splice.Descriptors = []SpliceDescriptor{seg}
+	if _, err := Encode(&splice); !errors.Is(err, ErrInvalidUPID) {
+		t.Errorf("encode splice with short TI: want %v, got %v", ErrInvalidUPID, err)
+	}
+}